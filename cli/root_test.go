@@ -153,3 +153,24 @@ func TestGetRootPreRunEFn_FailedConfigValidator(t *testing.T) {
 	assert.Contains(t, err.Error(), "configuration file is not valid")
 	assert.Contains(t, b.String(), "Key: 'Config.ListenAddr' Error:Field validation for 'ListenAddr' failed on the 'required' tag")
 }
+
+func TestGetRootPreRunEFn_FailedTransferConfigValidator(t *testing.T) {
+	b := bytes.NewBufferString("")
+	ctx := context.TestContext(b)
+	cmd := GetRootCmd(ctx)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	fsFake := afero.NewMemMapFs()
+	viper.Reset()
+	viper.SetFs(fsFake)
+	_ = fsFake.Mkdir(defaultConfigPath, 0775)
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/config.yml", defaultConfigPath), []byte("{tsig: [{name: transfer, secret: c2VjcmV0, secret_file: /etc/godnsd/transfer.key}], transfer: {zones: [{name: local, allow: [wrong]}]}}"), 0644)
+
+	cmd.SetArgs([]string{})
+	_ = cmd.Execute()
+
+	err := GetRootPreRunEFn(ctx)(cmd, []string{})
+	assert.Error(t, err)
+	assert.Contains(t, b.String(), "Key: 'Config.Tsig[0].Secret' Error:Field validation for 'Secret' failed on the 'excluded_with' tag")
+	assert.Contains(t, b.String(), "Key: 'Config.Transfer.Zones[0].Allow[0]' Error:Field validation for 'Allow[0]' failed on the 'cidr|ip' tag")
+}
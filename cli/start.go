@@ -8,7 +8,12 @@ import (
 	"github.com/alexandreh2ag/go-dns-discover/http"
 	"github.com/alexandreh2ag/go-dns-discover/http/controller"
 	"github.com/alexandreh2ag/go-dns-discover/http/middleware"
+	"github.com/alexandreh2ag/go-dns-discover/metrics"
 	"github.com/alexandreh2ag/go-dns-discover/provider"
+	"github.com/alexandreh2ag/go-dns-discover/querylog"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/labstack/echo/v4"
 	echoMiddleware "github.com/labstack/echo/v4/middleware"
 	"github.com/miekg/dns"
 	"github.com/spf13/cobra"
@@ -19,7 +24,8 @@ const (
 )
 
 var (
-	server *dns.Server
+	server    *dns.Server
+	serverTcp *dns.Server
 )
 
 func GetStartCmd(ctx *context.Context) *cobra.Command {
@@ -38,7 +44,27 @@ func GetStartRunFn(ctx *context.Context) func(*cobra.Command, []string) error {
 			return err
 		}
 
+		tsigKeys, err := tsig.LoadKeys(ctx.FS, ctx.Config.Tsig)
+		if err != nil {
+			return err
+		}
+
+		dnssecZones, err := appDns.LoadDnssecZones(ctx.FS, ctx.Config.Dnssec)
+		if err != nil {
+			return err
+		}
+
+		queryLogger, err := querylog.NewLogger(ctx.Config.QueryLog)
+		if err != nil {
+			return err
+		}
+
 		manager := appDns.CreateManager(ctx, providers)
+		manager.SetTsigKeys(tsigKeys)
+		manager.SetDnssecZones(dnssecZones)
+		manager.SetQueryLogger(queryLogger)
+		appMetrics := metrics.NewMetrics()
+		manager.SetMetrics(appMetrics)
 
 		if ctx.Config.Http.Enable {
 			e := http.CreateEcho()
@@ -47,16 +73,33 @@ func GetStartRunFn(ctx *context.Context) func(*cobra.Command, []string) error {
 					Level: 5,
 				}),
 				middleware.HandlerContext(ctx),
+				middleware.HandlerMetrics(appMetrics),
 			)
+			e.GET("/metrics", echo.WrapHandler(appMetrics.Handler()))
+			e.GET("/healthz", controller.GetHealthz(manager))
+			e.GET("/readyz", controller.GetReadyz(manager))
 			apiGroup := e.Group("/api")
 			apiRecordsGroup := apiGroup.Group("/records")
 			apiRecordsGroup.GET("", controller.GetRecords(manager))
+			apiRecordsGroup.GET("/conflicts", controller.GetRecordsConflicts(manager))
+			apiGroup.GET("/query-log", controller.GetQueryLog(queryLogger))
+			apiGroup.GET("/providers", controller.GetProviders(manager))
+			apiGroup.GET("/providers/:id/records", controller.GetProviderRecords(manager))
+			apiGroup.PUT("/query-log", controller.UpdateQueryLog(queryLogger))
 
 			if ctx.Config.Http.Enable && ctx.Config.Http.EnableApiProvider {
 				apiId := "api"
 				p, _ := provider.CreateProvider(ctx, apiId, config.Provider{Type: provider.ApiKeyType})
-				providers[apiId] = p
 				providerApi := p.(*provider.API)
+				if storeId := ctx.Config.Http.ApiProviderStore; storeId != "" {
+					store, ok := providers[storeId].(types.StoreProvider)
+					if !ok {
+						return fmt.Errorf("provider %s can not be used as api provider store", storeId)
+					}
+					providerApi.SetStore(store)
+				} else {
+					providers[apiId] = p
+				}
 				apiRecordsGroup.POST("", providerApi.HandlerAddRecord)
 				apiRecordsGroup.DELETE("", providerApi.HandlerDeleteRecord)
 				apiRecordsGroup.POST("/present", providerApi.HandlerPresent)
@@ -70,10 +113,31 @@ func GetStartRunFn(ctx *context.Context) func(*cobra.Command, []string) error {
 			}()
 		}
 
+		if updateId := ctx.Config.Update.Provider; updateId != "" {
+			store, ok := providers[updateId].(types.StoreProvider)
+			if !ok {
+				return fmt.Errorf("provider %s can not be used as update provider", updateId)
+			}
+			manager.SetUpdateStore(store)
+		}
+
 		go manager.Start()
 
-		server = &dns.Server{Addr: ctx.Config.ListenAddr, Net: "udp"}
+		server = &dns.Server{Addr: ctx.Config.ListenAddr, Net: "udp", UDPSize: dns.DefaultMsgSize, TsigProvider: tsigKeys, MsgAcceptFunc: appDns.AcceptMsgFunc}
+		serverTcp = &dns.Server{Addr: ctx.Config.ListenAddr, Net: "tcp", TsigProvider: tsigKeys, MsgAcceptFunc: appDns.AcceptMsgFunc}
+		server.NotifyStartedFunc = func() {
+			manager.SetListenerReady("udp", server.PacketConn.LocalAddr())
+		}
+		serverTcp.NotifyStartedFunc = func() {
+			manager.SetListenerReady("tcp", serverTcp.Listener.Addr())
+		}
 		dns.HandleFunc(".", manager.HandleDnsRequest())
+		go func() {
+			errTcp := serverTcp.ListenAndServe()
+			if errTcp != nil {
+				ctx.Logger.Error(fmt.Sprintf("Failed to start tcp server: %s", errTcp.Error()))
+			}
+		}()
 		go func() {
 			for {
 				select {
@@ -84,6 +148,14 @@ func GetStartRunFn(ctx *context.Context) func(*cobra.Command, []string) error {
 					if err != nil {
 						ctx.Logger.Error(fmt.Sprintf("Failed to shutdown server: %s", err.Error()))
 					}
+					err = serverTcp.Shutdown()
+					if err != nil {
+						ctx.Logger.Error(fmt.Sprintf("Failed to shutdown tcp server: %s", err.Error()))
+					}
+					err = queryLogger.Close()
+					if err != nil {
+						ctx.Logger.Error(fmt.Sprintf("Failed to close query log: %s", err.Error()))
+					}
 				}
 			}
 		}()
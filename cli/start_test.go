@@ -26,7 +26,7 @@ func TestGetStartRunFn_Success(t *testing.T) {
 	path := "/app"
 	_ = fsFake.Mkdir(path, 0775)
 
-	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/config.yml", path), []byte("{listen_addr: '127.0.0.1:0', http: {enable: true, listen: 127.0.0.1:0, enable_provider: true}, providers: {file: {type: fs, config: {path: /app/dns.yml}}}}"), 0644)
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/config.yml", path), []byte("{listen_addr: '127.0.0.1:0', http: {enable: true, listen: 127.0.0.1:0, enable_provider: true}, providers: {file: {type: fs, config: {path: /app/dns.yml}}}, tsig: [{name: transfer, secret: c2VjcmV0}], transfer: {zones: [{name: local, tsig_keys: [transfer]}]}, update: {provider: api, zones: [{name: local, tsig_keys: [transfer]}]}}"), 0644)
 	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/dns.yml", path), []byte("[{name: foo.local, type: A, value: 127.0.0.1}]"), 0644)
 	cmd.SetArgs([]string{CmdNameStart, "--" + Config, fmt.Sprintf("%s/config.yml", path)})
 	go func() {
@@ -36,15 +36,47 @@ func TestGetStartRunFn_Success(t *testing.T) {
 	for server == nil || (server != nil && server.PacketConn.LocalAddr().String() == "") {
 		time.Sleep(100 * time.Millisecond)
 	}
+	for serverTcp == nil || serverTcp.Listener == nil {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.NotNil(t, server.TsigProvider)
+
+	reqTransfer := &dns.Msg{}
+	reqTransfer.SetAxfr("local.")
+	reqTransfer.SetTsig("transfer.", dns.HmacSHA256, 300, time.Now().Unix())
+	transfer := &dns.Transfer{TsigSecret: map[string]string{"transfer.": "c2VjcmV0"}}
+	envelopes, err := transfer.In(reqTransfer, serverTcp.Listener.Addr().String())
+	assert.NoError(t, err)
+	rrs := []dns.RR{}
+	for envelope := range envelopes {
+		assert.NoError(t, envelope.Error)
+		rrs = append(rrs, envelope.RR...)
+	}
+	assert.Len(t, rrs, 3)
 	dnsClient := &dns.Client{Net: "udp", Timeout: 100 * time.Millisecond}
 	req := &dns.Msg{
 		MsgHdr:   dns.MsgHdr{Opcode: dns.OpcodeQuery},
 		Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}},
 	}
-	res, _, err := dnsClient.Exchange(req, server.PacketConn.LocalAddr().String())
-	assert.NoError(t, err)
+	res, _, errExchange := dnsClient.Exchange(req, server.PacketConn.LocalAddr().String())
+	assert.NoError(t, errExchange)
 	assert.Contains(t, res.String(), "ANSWER SECTION:\nfoo.local.\t3600\tIN\tA\t127.0.0.1\n")
 
+	reqUpdate := &dns.Msg{}
+	reqUpdate.SetUpdate("local.")
+	rrUpdate, _ := dns.NewRR("bar.local. 60 IN A 127.0.0.2")
+	reqUpdate.Insert([]dns.RR{rrUpdate})
+	reqUpdate.SetTsig("transfer.", dns.HmacSHA256, 300, time.Now().Unix())
+	dnsClientTsig := &dns.Client{Net: "udp", Timeout: 100 * time.Millisecond, TsigSecret: map[string]string{"transfer.": "c2VjcmV0"}}
+	res, _, errExchange = dnsClientTsig.Exchange(reqUpdate, server.PacketConn.LocalAddr().String())
+	assert.NoError(t, errExchange)
+	assert.Equal(t, dns.RcodeSuccess, res.Rcode)
+	time.Sleep(100 * time.Millisecond)
+	req.Question[0].Name = "bar.local."
+	res, _, errExchange = dnsClient.Exchange(req, server.PacketConn.LocalAddr().String())
+	assert.NoError(t, errExchange)
+	assert.Contains(t, res.String(), "ANSWER SECTION:\nbar.local.\t60\tIN\tA\t127.0.0.2\n")
+
 	ctx.Signal() <- syscall.SIGTERM
 	time.Sleep(100 * time.Millisecond)
 	assert.Contains(t, buffer.String(), "signal received, exiting...")
@@ -68,6 +100,98 @@ func TestGetStartRunFn_FailCreateProviders(t *testing.T) {
 	assert.Contains(t, err.Error(), "provider type 'wrong' for file does not exist")
 }
 
+func TestGetStartRunFn_FailApiProviderStore(t *testing.T) {
+	ctx := context.TestContext(nil)
+	cmd := GetRootCmd(ctx)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	fsFake := ctx.FS
+	viper.Reset()
+	viper.SetFs(fsFake)
+	path := "/app"
+	_ = fsFake.Mkdir(path, 0775)
+
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/config.yml", path), []byte("{listen_addr: '127.0.0.1:0', http: {enable: true, listen: 127.0.0.1:0, enable_provider: true, provider_store: file}, providers: {file: {type: fs, config: {path: /app/dns.yml}}}}"), 0644)
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/dns.yml", path), []byte("[{name: foo.local, type: A, value: 127.0.0.1}]"), 0644)
+	cmd.SetArgs([]string{CmdNameStart, "--" + Config, fmt.Sprintf("%s/config.yml", path)})
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "provider file can not be used as api provider store")
+}
+
+func TestGetStartRunFn_FailLoadTsigKeys(t *testing.T) {
+	ctx := context.TestContext(nil)
+	cmd := GetRootCmd(ctx)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	fsFake := ctx.FS
+	viper.Reset()
+	viper.SetFs(fsFake)
+	path := "/app"
+	_ = fsFake.Mkdir(path, 0775)
+
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/config.yml", path), []byte("{listen_addr: '127.0.0.1:0', providers: {}, tsig: [{name: update, secret_file: /app/update.key}]}"), 0644)
+	cmd.SetArgs([]string{CmdNameStart, "--" + Config, fmt.Sprintf("%s/config.yml", path)})
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tsig key update: open /app/update.key")
+}
+
+func TestGetStartRunFn_FailLoadDnssecZones(t *testing.T) {
+	ctx := context.TestContext(nil)
+	cmd := GetRootCmd(ctx)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	fsFake := ctx.FS
+	viper.Reset()
+	viper.SetFs(fsFake)
+	path := "/app"
+	_ = fsFake.Mkdir(path, 0775)
+
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/config.yml", path), []byte("{listen_addr: '127.0.0.1:0', providers: {}, dnssec: {zones: [{name: local, ksk: /app/ksk, zsk: /app/zsk}]}}"), 0644)
+	cmd.SetArgs([]string{CmdNameStart, "--" + Config, fmt.Sprintf("%s/config.yml", path)})
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dnssec zone local.: open /app/ksk.key")
+}
+
+func TestGetStartRunFn_FailQueryLog(t *testing.T) {
+	ctx := context.TestContext(nil)
+	cmd := GetRootCmd(ctx)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	fsFake := ctx.FS
+	viper.Reset()
+	viper.SetFs(fsFake)
+	path := "/app"
+	_ = fsFake.Mkdir(path, 0775)
+
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/config.yml", path), []byte(fmt.Sprintf("{listen_addr: '127.0.0.1:0', providers: {}, query_log: {output: syslog, syslog_network: unix, syslog_address: %s/missing.sock}}", t.TempDir())), 0644)
+	cmd.SetArgs([]string{CmdNameStart, "--" + Config, fmt.Sprintf("%s/config.yml", path)})
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "query log:")
+}
+
+func TestGetStartRunFn_FailUpdateProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+	cmd := GetRootCmd(ctx)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	fsFake := ctx.FS
+	viper.Reset()
+	viper.SetFs(fsFake)
+	path := "/app"
+	_ = fsFake.Mkdir(path, 0775)
+
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/config.yml", path), []byte("{listen_addr: '127.0.0.1:0', providers: {file: {type: fs, config: {path: /app/dns.yml}}}, tsig: [{name: update, secret: c2VjcmV0}], update: {provider: file, zones: [{name: local, tsig_keys: [update]}]}}"), 0644)
+	_ = afero.WriteFile(fsFake, fmt.Sprintf("%s/dns.yml", path), []byte("[{name: foo.local, type: A, value: 127.0.0.1}]"), 0644)
+	cmd.SetArgs([]string{CmdNameStart, "--" + Config, fmt.Sprintf("%s/config.yml", path)})
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "provider file can not be used as update provider")
+}
+
 func TestGetStartRunFn_FailListen(t *testing.T) {
 	buffer := &bytes.Buffer{}
 	ctx := context.TestContext(buffer)
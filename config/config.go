@@ -1,27 +1,108 @@
 package config
 
 type Config struct {
-	ListenAddr string              `mapstructure:"listen_addr" validate:"required"`
-	Providers  map[string]Provider `mapstructure:"providers" validate:"omitempty,required,dive"`
-	Fallback   FallbackConfig      `mapstructure:"fallback" validate:"omitempty,required"`
-	Http       HttpConfig          `mapstructure:"http" validate:"omitempty,required"`
+	ListenAddr  string              `mapstructure:"listen_addr" validate:"required"`
+	Providers   map[string]Provider `mapstructure:"providers" validate:"omitempty,required,dive"`
+	MergePolicy string              `mapstructure:"merge_policy" validate:"required,oneof=append priority first error"`
+	Fallback    FallbackConfig      `mapstructure:"fallback" validate:"omitempty,required"`
+	Http        HttpConfig          `mapstructure:"http" validate:"omitempty,required"`
+	Tsig        []TsigConfig        `mapstructure:"tsig" validate:"omitempty,dive"`
+	Transfer    TransferConfig      `mapstructure:"transfer"`
+	Update      UpdateConfig        `mapstructure:"update"`
+	Dnssec      DnssecConfig        `mapstructure:"dnssec"`
+	QueryLog    QueryLogConfig      `mapstructure:"query_log"`
 }
 
 type Provider struct {
-	Type   string                 `mapstructure:"type" validate:"required"`
-	Config map[string]interface{} `mapstructure:"config"`
+	Type     string                 `mapstructure:"type" validate:"required"`
+	Config   map[string]interface{} `mapstructure:"config"`
+	Priority int                    `mapstructure:"priority"`
+	Restart  RestartConfig          `mapstructure:"restart"`
+}
+
+type RestartConfig struct {
+	Disable         bool     `mapstructure:"disable"`
+	InitialInterval int64    `mapstructure:"initial_interval" validate:"gte=0"`
+	MaxInterval     int64    `mapstructure:"max_interval" validate:"gte=0"`
+	Multiplier      float64  `mapstructure:"multiplier" validate:"omitempty,gte=1"`
+	Jitter          *float64 `mapstructure:"jitter" validate:"omitempty,gte=0,lte=1"`
+	MaxRetries      int      `mapstructure:"max_retries" validate:"gte=0"`
+	MaxStaleness    int64    `mapstructure:"max_staleness" validate:"gte=0"`
 }
 
 type FallbackConfig struct {
-	Enable      bool     `mapstructure:"enable"`
-	Nameservers []string `mapstructure:"nameservers" validate:"required_if=Enable true,dive,required"`
-	Timeout     int64    `mapstructure:"timeout" validate:"omitempty,required"`
+	Enable       bool               `mapstructure:"enable"`
+	Nameservers  []string           `mapstructure:"nameservers" validate:"required_if=Enable true,dive,required"`
+	Timeout      int64              `mapstructure:"timeout" validate:"omitempty,required"`
+	ClientSubnet ClientSubnetConfig `mapstructure:"client_subnet"`
+}
+
+type ClientSubnetConfig struct {
+	Enable     bool  `mapstructure:"enable"`
+	Ipv4Prefix uint8 `mapstructure:"ipv4_prefix" validate:"max=32"`
+	Ipv6Prefix uint8 `mapstructure:"ipv6_prefix" validate:"max=128"`
 }
 
 type HttpConfig struct {
 	Enable            bool   `mapstructure:"enable"`
 	Listen            string `mapstructure:"listen" validate:"required_if=Enable true"`
 	EnableApiProvider bool   `mapstructure:"enable_provider"`
+	ApiProviderStore  string `mapstructure:"provider_store"`
+	ReadyQuery        string `mapstructure:"ready_query"`
+}
+
+type TsigConfig struct {
+	Name       string `mapstructure:"name" validate:"required"`
+	Algorithm  string `mapstructure:"algorithm" validate:"omitempty,oneof=hmac-sha1 hmac-sha224 hmac-sha256 hmac-sha384 hmac-sha512"`
+	Secret     string `mapstructure:"secret" validate:"required_without_all=SecretFile SecretEnv,excluded_with=SecretFile SecretEnv"`
+	SecretFile string `mapstructure:"secret_file" validate:"excluded_with=SecretEnv"`
+	SecretEnv  string `mapstructure:"secret_env"`
+}
+
+type TransferConfig struct {
+	Zones []TransferZoneConfig `mapstructure:"zones" validate:"omitempty,dive"`
+}
+
+type TransferZoneConfig struct {
+	Name          string   `mapstructure:"name" validate:"required"`
+	Allow         []string `mapstructure:"allow" validate:"omitempty,dive,cidr|ip"`
+	TsigKeys      []string `mapstructure:"tsig_keys" validate:"omitempty,dive,required"`
+	Notify        []string `mapstructure:"notify" validate:"omitempty,dive,required"`
+	NotifyTsigKey string   `mapstructure:"notify_tsig_key"`
+}
+
+type UpdateConfig struct {
+	Provider string             `mapstructure:"provider" validate:"required_with=Zones"`
+	Zones    []UpdateZoneConfig `mapstructure:"zones" validate:"omitempty,dive"`
+}
+
+type UpdateZoneConfig struct {
+	Name     string   `mapstructure:"name" validate:"required"`
+	TsigKeys []string `mapstructure:"tsig_keys" validate:"required,min=1,dive,required"`
+}
+
+type DnssecConfig struct {
+	Zones []DnssecZoneConfig `mapstructure:"zones" validate:"omitempty,dive"`
+}
+
+type DnssecZoneConfig struct {
+	Name string `mapstructure:"name" validate:"required"`
+	Ksk  string `mapstructure:"ksk" validate:"required"`
+	Zsk  string `mapstructure:"zsk" validate:"required"`
+}
+
+type QueryLogConfig struct {
+	Enable        bool    `mapstructure:"enable"`
+	Output        string  `mapstructure:"output" validate:"oneof=stdout file syslog"`
+	Format        string  `mapstructure:"format" validate:"oneof=json text"`
+	Path          string  `mapstructure:"path" validate:"required_if=Output file"`
+	SampleRate    float64 `mapstructure:"sample_rate" validate:"min=0,max=1"`
+	MaxSize       int     `mapstructure:"max_size" validate:"min=0"`
+	MaxBackups    int     `mapstructure:"max_backups" validate:"min=0"`
+	MaxAge        int     `mapstructure:"max_age" validate:"min=0"`
+	Compress      bool    `mapstructure:"compress"`
+	SyslogNetwork string  `mapstructure:"syslog_network" validate:"omitempty,oneof=udp tcp unix unixgram"`
+	SyslogAddress string  `mapstructure:"syslog_address" validate:"required_with=SyslogNetwork"`
 }
 
 func NewConfig() Config {
@@ -32,6 +113,13 @@ func DefaultConfig() Config {
 	cfg := NewConfig()
 	cfg.ListenAddr = "0.0.0.0:53"
 	cfg.Providers = map[string]Provider{}
+	cfg.MergePolicy = "append"
 	cfg.Fallback.Timeout = 4
+	cfg.Fallback.ClientSubnet.Ipv4Prefix = 24
+	cfg.Fallback.ClientSubnet.Ipv6Prefix = 56
+	cfg.QueryLog.Output = "stdout"
+	cfg.QueryLog.Format = "json"
+	cfg.QueryLog.SampleRate = 1
+	cfg.QueryLog.MaxSize = 100
 	return cfg
 }
@@ -13,6 +13,6 @@ func TestNewConfig(t *testing.T) {
 
 func TestDefaultConfig(t *testing.T) {
 	got := DefaultConfig()
-	want := Config{ListenAddr: "0.0.0.0:53", Providers: map[string]Provider{}, Fallback: FallbackConfig{Timeout: 4}}
+	want := Config{ListenAddr: "0.0.0.0:53", Providers: map[string]Provider{}, MergePolicy: "append", Fallback: FallbackConfig{Timeout: 4, ClientSubnet: ClientSubnetConfig{Ipv4Prefix: 24, Ipv6Prefix: 56}}, QueryLog: QueryLogConfig{Output: "stdout", Format: "json", SampleRate: 1, MaxSize: 100}}
 	assert.Equal(t, want, got)
 }
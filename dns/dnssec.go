@@ -0,0 +1,259 @@
+package dns
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/miekg/dns"
+	"github.com/spf13/afero"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	typeNXNAME uint16 = 128
+
+	dnssecSignatureValidity  = 7 * 24 * time.Hour
+	dnssecSignatureInception = 3 * time.Hour
+	dnssecSignatureCacheSize = 10000
+)
+
+type DnssecZone struct {
+	name       string
+	ksk        *dns.DNSKEY
+	zsk        *dns.DNSKEY
+	kskSigner  crypto.Signer
+	zskSigner  crypto.Signer
+	signatures map[string]*dns.RRSIG
+	mtx        sync.Mutex
+}
+
+type DnssecZones map[string]*DnssecZone
+
+func LoadDnssecZones(fs afero.Fs, cfg config.DnssecConfig) (DnssecZones, error) {
+	zones := DnssecZones{}
+	for _, zoneCfg := range cfg.Zones {
+		zoneName := strings.ToLower(dns.Fqdn(zoneCfg.Name))
+		ksk, kskSigner, err := loadDnssecKey(fs, zoneName, zoneCfg.Ksk)
+		if err != nil {
+			return nil, err
+		}
+		zsk, zskSigner, err := loadDnssecKey(fs, zoneName, zoneCfg.Zsk)
+		if err != nil {
+			return nil, err
+		}
+		zones[zoneName] = &DnssecZone{
+			name:       zoneName,
+			ksk:        ksk,
+			zsk:        zsk,
+			kskSigner:  kskSigner,
+			zskSigner:  zskSigner,
+			signatures: map[string]*dns.RRSIG{},
+		}
+	}
+	return zones, nil
+}
+
+func loadDnssecKey(fs afero.Fs, zoneName string, path string) (*dns.DNSKEY, crypto.Signer, error) {
+	prefix := strings.TrimSuffix(strings.TrimSuffix(path, ".key"), ".private")
+	content, err := afero.ReadFile(fs, prefix+".key")
+	if err != nil {
+		return nil, nil, fmt.Errorf("dnssec zone %s: %w", zoneName, err)
+	}
+	rr, err := dns.ReadRR(bytes.NewReader(content), prefix+".key")
+	if err != nil {
+		return nil, nil, fmt.Errorf("dnssec zone %s: %w", zoneName, err)
+	}
+	key, ok := rr.(*dns.DNSKEY)
+	if !ok || !strings.EqualFold(key.Hdr.Name, zoneName) {
+		return nil, nil, fmt.Errorf("dnssec zone %s: %s.key is not a DNSKEY of the zone", zoneName, prefix)
+	}
+
+	file, err := fs.Open(prefix + ".private")
+	if err != nil {
+		return nil, nil, fmt.Errorf("dnssec zone %s: %w", zoneName, err)
+	}
+	defer file.Close()
+	privateKey, err := key.ReadPrivateKey(file, prefix+".private")
+	if err != nil {
+		return nil, nil, fmt.Errorf("dnssec zone %s: %w", zoneName, err)
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("dnssec zone %s: %s.private can not be used to sign", zoneName, prefix)
+	}
+	return key, signer, nil
+}
+
+func (z *DnssecZone) dnskeys() []dns.RR {
+	if z.ksk.KeyTag() == z.zsk.KeyTag() {
+		return []dns.RR{z.ksk}
+	}
+	return []dns.RR{z.ksk, z.zsk}
+}
+
+func (z *DnssecZone) sign(rrset []dns.RR) (*dns.RRSIG, bool, error) {
+	key, signer := z.zsk, z.zskSigner
+	if rrset[0].Header().Rrtype == dns.TypeDNSKEY {
+		key, signer = z.ksk, z.kskSigner
+	}
+
+	lines := make([]string, 0, len(rrset)+1)
+	lines = append(lines, fmt.Sprintf("%d", key.KeyTag()))
+	for _, rr := range rrset {
+		lines = append(lines, strings.ToLower(rr.String()))
+	}
+	slices.Sort(lines[1:])
+	cacheKey := strings.Join(lines, "\n")
+
+	z.mtx.Lock()
+	defer z.mtx.Unlock()
+	if rrsig, ok := z.signatures[cacheKey]; ok && time.Until(time.Unix(int64(rrsig.Expiration), 0)) > dnssecSignatureValidity/2 {
+		return dns.Copy(rrsig).(*dns.RRSIG), true, nil
+	}
+
+	now := time.Now()
+	rrsig := &dns.RRSIG{
+		KeyTag:     key.KeyTag(),
+		SignerName: z.name,
+		Algorithm:  key.Algorithm,
+		Inception:  uint32(now.Add(-dnssecSignatureInception).Unix()),
+		Expiration: uint32(now.Add(dnssecSignatureValidity).Unix()),
+	}
+	if err := rrsig.Sign(signer, rrset); err != nil {
+		return nil, false, err
+	}
+	rrsig.Hdr.Ttl = rrsig.OrigTtl
+
+	if len(z.signatures) >= dnssecSignatureCacheSize {
+		z.signatures = map[string]*dns.RRSIG{}
+	}
+	z.signatures[cacheKey] = rrsig
+	return dns.Copy(rrsig).(*dns.RRSIG), false, nil
+}
+
+func (m *Manager) SetDnssecZones(zones DnssecZones) {
+	m.dnssecZones = zones
+}
+
+func (m *Manager) findDnssecZone(name string) *DnssecZone {
+	var found *DnssecZone
+	for zoneName, zone := range m.dnssecZones {
+		if isInZone(name, zoneName) && (found == nil || len(zoneName) > len(found.name)) {
+			found = zone
+		}
+	}
+	return found
+}
+
+func (m *Manager) dnssecSOA(zone *DnssecZone) dns.RR {
+	if soa := m.zoneSOA(zone.name); soa != nil {
+		return soa
+	}
+	if soa := m.findSOARecord(zone.name); soa != nil {
+		return soa
+	}
+	return synthesizeSOA(zone.name, 1)
+}
+
+func (m *Manager) answerDnssecZone(message *dns.Msg, question dns.Question, zone *DnssecZone) {
+	message.Authoritative = true
+	isApex := strings.EqualFold(dns.Fqdn(question.Name), zone.name)
+	switch {
+	case isApex && question.Qtype == dns.TypeDNSKEY:
+		message.Answer = append(message.Answer, zone.dnskeys()...)
+	case isApex && question.Qtype == dns.TypeSOA:
+		message.Answer = append(message.Answer, m.dnssecSOA(zone))
+	default:
+		if !isApex && !m.nameExists(question.Name) && m.wildcardSource(question.Name) == "" {
+			message.Rcode = dns.RcodeNameError
+		}
+		message.Ns = append(message.Ns, m.dnssecSOA(zone))
+	}
+}
+
+func (m *Manager) typesAtName(name string, zone *DnssecZone) []uint16 {
+	rrtypes := []uint16{}
+	for _, record := range m.findRecordsByName(name) {
+		if rrtype, ok := dns.StringToType[strings.ToUpper(record.Type)]; ok {
+			rrtypes = append(rrtypes, rrtype)
+		}
+	}
+	if strings.EqualFold(dns.Fqdn(name), zone.name) {
+		rrtypes = append(rrtypes, dns.TypeSOA, dns.TypeDNSKEY)
+	}
+	return rrtypes
+}
+
+func (m *Manager) secureMessage(message *dns.Msg, r *dns.Msg) {
+	if len(message.Question) == 0 {
+		return
+	}
+	question := message.Question[0]
+	zone := m.findDnssecZone(question.Name)
+	if zone == nil {
+		return
+	}
+	message.Authoritative = true
+	opt := r.IsEdns0()
+	if opt == nil || !opt.Do() {
+		return
+	}
+
+	if len(message.Answer) == 0 {
+		bitmap := []uint16{dns.TypeRRSIG, dns.TypeNSEC}
+		if message.Rcode == dns.RcodeNameError {
+			message.Rcode = dns.RcodeSuccess
+			bitmap = append(bitmap, typeNXNAME)
+		} else {
+			bitmap = append(bitmap, m.typesAtName(question.Name, zone)...)
+		}
+		slices.Sort(bitmap)
+		soa := m.dnssecSOA(zone).(*dns.SOA)
+		message.Ns = append(message.Ns, &dns.NSEC{
+			Hdr:        dns.RR_Header{Name: strings.ToLower(dns.Fqdn(question.Name)), Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: min(soa.Hdr.Ttl, soa.Minttl)},
+			NextDomain: "\\000." + strings.ToLower(dns.Fqdn(question.Name)),
+			TypeBitMap: slices.Compact(bitmap),
+		})
+	}
+
+	message.Answer = append(message.Answer, m.signRRsets(message.Answer)...)
+	message.Ns = append(message.Ns, m.signRRsets(message.Ns)...)
+}
+
+func (m *Manager) signRRsets(rrs []dns.RR) []dns.RR {
+	rrsets := [][]dns.RR{}
+	for _, rr := range rrs {
+		header := rr.Header()
+		if header.Rrtype == dns.TypeRRSIG || header.Rrtype == dns.TypeOPT {
+			continue
+		}
+		index := slices.IndexFunc(rrsets, func(rrset []dns.RR) bool {
+			return strings.EqualFold(rrset[0].Header().Name, header.Name) && rrset[0].Header().Rrtype == header.Rrtype && rrset[0].Header().Class == header.Class
+		})
+		if index < 0 {
+			rrsets = append(rrsets, []dns.RR{rr})
+			continue
+		}
+		rrsets[index] = append(rrsets[index], rr)
+	}
+
+	rrsigs := []dns.RR{}
+	for _, rrset := range rrsets {
+		zone := m.findDnssecZone(rrset[0].Header().Name)
+		if zone == nil {
+			continue
+		}
+		rrsig, cached, err := zone.sign(rrset)
+		m.metrics.ObserveSignatureCache(cached)
+		if err != nil {
+			m.logger.Error(fmt.Sprintf("error when sign %s %s: %v", rrset[0].Header().Name, dns.TypeToString[rrset[0].Header().Rrtype], err))
+			continue
+		}
+		rrsigs = append(rrsigs, rrsig)
+	}
+	return rrsigs
+}
@@ -0,0 +1,312 @@
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	mockMiekgDns "github.com/alexandreh2ag/go-dns-discover/mocks/miekg"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"testing"
+	"time"
+)
+
+func writeTestDnssecKey(t *testing.T, fs afero.Fs, zone string, flags uint16, prefix string) *dns.DNSKEY {
+	key := &dns.DNSKEY{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600}, Flags: flags, Protocol: 3, Algorithm: dns.ECDSAP256SHA256}
+	privateKey, err := key.Generate(256)
+	assert.NoError(t, err)
+	_ = afero.WriteFile(fs, prefix+".key", []byte("; comment\n"+key.String()+"\n"), 0644)
+	_ = afero.WriteFile(fs, prefix+".private", []byte(key.PrivateKeyString(privateKey)), 0600)
+	return key
+}
+
+func createTestDnssecZones(t *testing.T) DnssecZones {
+	fs := afero.NewMemMapFs()
+	writeTestDnssecKey(t, fs, "local.", 257, "/keys/ksk")
+	writeTestDnssecKey(t, fs, "local.", 256, "/keys/zsk")
+	zones, err := LoadDnssecZones(fs, config.DnssecConfig{Zones: []config.DnssecZoneConfig{{Name: "local", Ksk: "/keys/ksk", Zsk: "/keys/zsk.key"}}})
+	assert.NoError(t, err)
+	return zones
+}
+
+func TestLoadDnssecZones(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.DnssecConfig
+		mockFn  func(t *testing.T, fs afero.Fs)
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Success",
+			cfg:  config.DnssecConfig{Zones: []config.DnssecZoneConfig{{Name: "Local", Ksk: "/keys/ksk.private", Zsk: "/keys/zsk"}}},
+			mockFn: func(t *testing.T, fs afero.Fs) {
+				writeTestDnssecKey(t, fs, "local.", 257, "/keys/ksk")
+				writeTestDnssecKey(t, fs, "local.", 256, "/keys/zsk")
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailKeyNotFound",
+			cfg:     config.DnssecConfig{Zones: []config.DnssecZoneConfig{{Name: "local", Ksk: "/keys/ksk", Zsk: "/keys/zsk"}}},
+			mockFn:  func(t *testing.T, fs afero.Fs) {},
+			wantErr: assert.Error,
+		},
+		{
+			name: "FailZskNotFound",
+			cfg:  config.DnssecConfig{Zones: []config.DnssecZoneConfig{{Name: "local", Ksk: "/keys/ksk", Zsk: "/keys/zsk"}}},
+			mockFn: func(t *testing.T, fs afero.Fs) {
+				writeTestDnssecKey(t, fs, "local.", 257, "/keys/ksk")
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "FailKeyParse",
+			cfg:  config.DnssecConfig{Zones: []config.DnssecZoneConfig{{Name: "local", Ksk: "/keys/ksk", Zsk: "/keys/zsk"}}},
+			mockFn: func(t *testing.T, fs afero.Fs) {
+				_ = afero.WriteFile(fs, "/keys/ksk.key", []byte("local. IN DNSKEY wrong"), 0644)
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "FailKeyOtherZone",
+			cfg:  config.DnssecConfig{Zones: []config.DnssecZoneConfig{{Name: "local", Ksk: "/keys/ksk", Zsk: "/keys/zsk"}}},
+			mockFn: func(t *testing.T, fs afero.Fs) {
+				writeTestDnssecKey(t, fs, "example.", 257, "/keys/ksk")
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, "dnssec zone local.: /keys/ksk.key is not a DNSKEY of the zone", i...)
+			},
+		},
+		{
+			name: "FailPrivateNotFound",
+			cfg:  config.DnssecConfig{Zones: []config.DnssecZoneConfig{{Name: "local", Ksk: "/keys/ksk", Zsk: "/keys/zsk"}}},
+			mockFn: func(t *testing.T, fs afero.Fs) {
+				writeTestDnssecKey(t, fs, "local.", 257, "/keys/ksk")
+				_ = fs.Remove("/keys/ksk.private")
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "FailPrivateParse",
+			cfg:  config.DnssecConfig{Zones: []config.DnssecZoneConfig{{Name: "local", Ksk: "/keys/ksk", Zsk: "/keys/zsk"}}},
+			mockFn: func(t *testing.T, fs afero.Fs) {
+				writeTestDnssecKey(t, fs, "local.", 257, "/keys/ksk")
+				_ = afero.WriteFile(fs, "/keys/ksk.private", []byte("wrong"), 0600)
+			},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			tt.mockFn(t, fs)
+			got, err := LoadDnssecZones(fs, tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("LoadDnssecZones(fs, %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Len(t, got, 1)
+				assert.Equal(t, "local.", got["local."].name)
+				assert.Equal(t, uint16(257), got["local."].ksk.Flags)
+				assert.Equal(t, uint16(256), got["local."].zsk.Flags)
+			}
+		})
+	}
+}
+
+func TestDnssecZone_sign(t *testing.T) {
+	zone := createTestDnssecZones(t)["local."]
+	rrset := []dns.RR{newTestRR("foo.local. 60 IN A 127.0.0.1"), newTestRR("foo.local. 60 IN A 127.0.0.2")}
+
+	rrsig, cached, err := zone.sign(rrset)
+	assert.NoError(t, err)
+	assert.False(t, cached)
+	assert.Equal(t, zone.zsk.KeyTag(), rrsig.KeyTag)
+	assert.Equal(t, "local.", rrsig.SignerName)
+	assert.Equal(t, uint32(60), rrsig.Hdr.Ttl)
+	assert.NoError(t, rrsig.Verify(zone.zsk, rrset))
+	assert.True(t, rrsig.ValidityPeriod(time.Now()))
+
+	rrsigCached, cached, err := zone.sign([]dns.RR{rrset[1], rrset[0]})
+	assert.NoError(t, err)
+	assert.True(t, cached)
+	assert.Equal(t, rrsig.Signature, rrsigCached.Signature)
+	assert.Len(t, zone.signatures, 1)
+
+	rrsig, _, err = zone.sign(zone.dnskeys())
+	assert.NoError(t, err)
+	assert.Equal(t, zone.ksk.KeyTag(), rrsig.KeyTag)
+	assert.NoError(t, rrsig.Verify(zone.ksk, zone.dnskeys()))
+}
+
+func TestManager_findDnssecZone(t *testing.T) {
+	m := &Manager{dnssecZones: DnssecZones{"local.": {name: "local."}, "sub.local.": {name: "sub.local."}}}
+	assert.Equal(t, "local.", m.findDnssecZone("foo.local.").name)
+	assert.Equal(t, "sub.local.", m.findDnssecZone("foo.Sub.local").name)
+	assert.Nil(t, m.findDnssecZone("foo.example."))
+}
+
+func TestManager_HandleDnsRequest_Dnssec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	zones := createTestDnssecZones(t)
+	zone := zones["local."]
+
+	records := types.Records{
+		"foo.local._A":     {{Name: "foo.local", Type: "A", Value: "127.0.0.1", TTL: 60}},
+		"*.wild.local._A":  {{Name: "*.wild.local", Type: "A", Value: "127.0.0.2"}},
+		"foo.example._A":   {{Name: "foo.example", Type: "A", Value: "127.0.0.3"}},
+		"bar.local._CNAME": {{Name: "bar.local", Type: "CNAME", Value: "foo.local."}},
+	}
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     uint16
+		do        bool
+		checkFn   func(t *testing.T, msg *dns.Msg)
+		wantRcode int
+	}{
+		{
+			name:  "SuccessSignedAnswer",
+			qname: "foo.local.",
+			qtype: dns.TypeA,
+			do:    true,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.True(t, msg.Authoritative)
+				assert.Len(t, msg.Answer, 2)
+				rrsig := msg.Answer[1].(*dns.RRSIG)
+				assert.NoError(t, rrsig.Verify(zone.zsk, msg.Answer[:1]))
+				assert.True(t, msg.IsEdns0().Do())
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessSignedCNAME",
+			qname: "bar.local.",
+			qtype: dns.TypeA,
+			do:    true,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Len(t, msg.Answer, 4)
+				assert.Equal(t, dns.TypeCNAME, msg.Answer[2].(*dns.RRSIG).TypeCovered)
+				assert.Equal(t, dns.TypeA, msg.Answer[3].(*dns.RRSIG).TypeCovered)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessUnsignedWithoutDo",
+			qname: "foo.local.",
+			qtype: dns.TypeA,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.True(t, msg.Authoritative)
+				assert.Len(t, msg.Answer, 1)
+				assert.Nil(t, msg.IsEdns0())
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessDNSKEY",
+			qname: "local.",
+			qtype: dns.TypeDNSKEY,
+			do:    true,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Len(t, msg.Answer, 3)
+				rrsig := msg.Answer[2].(*dns.RRSIG)
+				assert.Equal(t, zone.ksk.KeyTag(), rrsig.KeyTag)
+				assert.NoError(t, rrsig.Verify(zone.ksk, msg.Answer[:2]))
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessSOA",
+			qname: "local.",
+			qtype: dns.TypeSOA,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Equal(t, "local.\t300\tIN\tSOA\tns.local. hostmaster.local. 1 3600 600 86400 300", msg.Answer[0].String())
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessNoData",
+			qname: "foo.local.",
+			qtype: dns.TypeAAAA,
+			do:    true,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Empty(t, msg.Answer)
+				assert.Len(t, msg.Ns, 4)
+				nsec := msg.Ns[1].(*dns.NSEC)
+				assert.Equal(t, "\\000.foo.local.", nsec.NextDomain)
+				assert.Equal(t, []uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC}, nsec.TypeBitMap)
+				assert.NoError(t, msg.Ns[3].(*dns.RRSIG).Verify(zone.zsk, []dns.RR{nsec}))
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessNXDomainBlackLies",
+			qname: "missing.local.",
+			qtype: dns.TypeA,
+			do:    true,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				nsec := msg.Ns[1].(*dns.NSEC)
+				assert.Equal(t, []uint16{dns.TypeRRSIG, dns.TypeNSEC, typeNXNAME}, nsec.TypeBitMap)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessNXDomainWithoutDo",
+			qname: "missing.local.",
+			qtype: dns.TypeA,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Len(t, msg.Ns, 1)
+				assert.Equal(t, dns.TypeSOA, msg.Ns[0].Header().Rrtype)
+			},
+			wantRcode: dns.RcodeNameError,
+		},
+		{
+			name:  "SuccessNoDataWildcard",
+			qname: "foo.wild.local.",
+			qtype: dns.TypeTXT,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Empty(t, msg.Answer)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessOutOfZone",
+			qname: "foo.example.",
+			qtype: dns.TypeA,
+			do:    true,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.False(t, msg.Authoritative)
+				assert.Len(t, msg.Answer, 1)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			ctx := context.TestContext(buffer)
+			m := &Manager{logger: ctx.Logger, records: records, recordsNames: createRecordsNames(records), dnssecZones: zones}
+			message := &dns.Msg{}
+			message.SetQuestion(tt.qname, tt.qtype)
+			if tt.do {
+				message.SetEdns0(1232, true)
+			}
+			responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+			responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+			responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).DoAndReturn(func(msg *dns.Msg) error {
+				assert.Equal(t, dns.RcodeToString[tt.wantRcode], dns.RcodeToString[msg.Rcode])
+				tt.checkFn(t, msg)
+				return nil
+			})
+
+			m.HandleDnsRequest()(responseWriter, message)
+			assert.NotContains(t, buffer.String(), "error")
+		})
+	}
+}
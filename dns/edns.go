@@ -0,0 +1,91 @@
+package dns
+
+import (
+	"github.com/miekg/dns"
+	"net"
+)
+
+const ednsUDPSize uint16 = 1232
+
+func findClientSubnet(msg *dns.Msg) *dns.EDNS0_SUBNET {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, option := range opt.Option {
+		if subnet, ok := option.(*dns.EDNS0_SUBNET); ok {
+			return subnet
+		}
+	}
+	return nil
+}
+
+func remoteIP(addr net.Addr) net.IP {
+	switch remoteAddr := addr.(type) {
+	case *net.UDPAddr:
+		return remoteAddr.IP
+	case *net.TCPAddr:
+		return remoteAddr.IP
+	}
+	return nil
+}
+
+func (m *Manager) clientSubnet(r *dns.Msg, remoteAddr net.Addr) *dns.EDNS0_SUBNET {
+	cfg := m.fallbackCfg.ClientSubnet
+	if !m.fallbackCfg.Enable || !cfg.Enable {
+		return nil
+	}
+
+	ip, netmask := remoteIP(remoteAddr), uint8(128)
+	if requestSubnet := findClientSubnet(r); requestSubnet != nil {
+		ip, netmask = requestSubnet.Address, requestSubnet.SourceNetmask
+	} else if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return nil
+	}
+
+	subnet := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: min(netmask, cfg.Ipv4Prefix)}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	} else {
+		subnet.Family = 2
+		subnet.SourceNetmask = min(netmask, cfg.Ipv6Prefix)
+	}
+	subnet.Address = ip.Mask(net.CIDRMask(int(subnet.SourceNetmask), len(ip)*8))
+	return subnet
+}
+
+func (m *Manager) setEdns0(message *dns.Msg, r *dns.Msg, subnet *dns.EDNS0_SUBNET) {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return
+	}
+	reply := &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+	reply.SetUDPSize(ednsUDPSize)
+	if opt.Do() {
+		reply.SetDo()
+	}
+	if requestSubnet := findClientSubnet(r); requestSubnet != nil {
+		replySubnet := *requestSubnet
+		replySubnet.SourceScope = 0
+		if subnet != nil {
+			replySubnet.SourceScope = subnet.SourceScope
+		}
+		reply.Option = append(reply.Option, &replySubnet)
+	}
+	message.Extra = append(message.Extra, reply)
+}
+
+func (m *Manager) truncateMessage(message *dns.Msg, r *dns.Msg, remoteAddr net.Addr, keyName string) {
+	size := dns.MaxMsgSize
+	if _, ok := remoteAddr.(*net.TCPAddr); !ok {
+		size = dns.MinMsgSize
+		if opt := r.IsEdns0(); opt != nil {
+			size = int(max(min(opt.UDPSize(), dns.DefaultMsgSize), dns.MinMsgSize))
+		}
+	}
+	if tsig := r.IsTsig(); tsig != nil && keyName != "" {
+		size -= dns.Len(tsig)
+	}
+	message.Truncate(size)
+	message.Compress = true
+}
@@ -0,0 +1,276 @@
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	mockMiekgDns "github.com/alexandreh2ag/go-dns-discover/mocks/miekg"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestSubnetMsg(udpSize uint16, subnet *dns.EDNS0_SUBNET) *dns.Msg {
+	msg := &dns.Msg{}
+	msg.SetQuestion("example.com.", dns.TypeA)
+	msg.SetEdns0(udpSize, false)
+	if subnet != nil {
+		msg.IsEdns0().Option = append(msg.IsEdns0().Option, subnet)
+	}
+	return msg
+}
+
+func TestManager_clientSubnet(t *testing.T) {
+	enabledCfg := config.FallbackConfig{Enable: true, ClientSubnet: config.ClientSubnetConfig{Enable: true, Ipv4Prefix: 24, Ipv6Prefix: 56}}
+	tests := []struct {
+		name        string
+		fallbackCfg config.FallbackConfig
+		r           *dns.Msg
+		remoteAddr  net.Addr
+		want        *dns.EDNS0_SUBNET
+	}{
+		{
+			name:        "SuccessDisabled",
+			fallbackCfg: config.FallbackConfig{Enable: true},
+			r:           newTestSubnetMsg(1232, nil),
+			remoteAddr:  &net.UDPAddr{IP: net.ParseIP("203.0.113.10"), Port: 4000},
+		},
+		{
+			name:        "SuccessFallbackDisabled",
+			fallbackCfg: config.FallbackConfig{ClientSubnet: config.ClientSubnetConfig{Enable: true, Ipv4Prefix: 24}},
+			r:           newTestSubnetMsg(1232, nil),
+			remoteAddr:  &net.UDPAddr{IP: net.ParseIP("203.0.113.10"), Port: 4000},
+		},
+		{
+			name:        "SuccessFromRemoteIPv4",
+			fallbackCfg: enabledCfg,
+			r:           newTestSubnetMsg(1232, nil),
+			remoteAddr:  &net.UDPAddr{IP: net.ParseIP("203.0.113.10"), Port: 4000},
+			want:        &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("203.0.113.0").To4()},
+		},
+		{
+			name:        "SuccessFromRemoteIPv6",
+			fallbackCfg: enabledCfg,
+			r:           newTestSubnetMsg(1232, nil),
+			remoteAddr:  &net.TCPAddr{IP: net.ParseIP("2001:db8:1:2:3::1"), Port: 4000},
+			want:        &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 2, SourceNetmask: 56, Address: net.ParseIP("2001:db8:1::")},
+		},
+		{
+			name:        "SuccessPrivateRemote",
+			fallbackCfg: enabledCfg,
+			r:           newTestSubnetMsg(1232, nil),
+			remoteAddr:  &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000},
+		},
+		{
+			name:        "SuccessFromRequest",
+			fallbackCfg: enabledCfg,
+			r:           newTestSubnetMsg(1232, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 32, Address: net.ParseIP("198.51.100.7").To4()}),
+			remoteAddr:  &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000},
+			want:        &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("198.51.100.0").To4()},
+		},
+		{
+			name:        "SuccessFromRequestOptOut",
+			fallbackCfg: enabledCfg,
+			r:           newTestSubnetMsg(1232, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 0, Address: net.ParseIP("0.0.0.0").To4()}),
+			remoteAddr:  &net.UDPAddr{IP: net.ParseIP("203.0.113.10"), Port: 4000},
+			want:        &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 0, Address: net.ParseIP("0.0.0.0").To4()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{fallbackCfg: tt.fallbackCfg}
+			assert.Equalf(t, tt.want, m.clientSubnet(tt.r, tt.remoteAddr), "clientSubnet(%v, %v)", tt.r, tt.remoteAddr)
+		})
+	}
+}
+
+func TestManager_setEdns0(t *testing.T) {
+	m := &Manager{}
+
+	message := &dns.Msg{}
+	m.setEdns0(message, &dns.Msg{}, nil)
+	assert.Nil(t, message.IsEdns0())
+
+	r := newTestSubnetMsg(4096, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("198.51.100.0").To4()})
+	r.IsEdns0().SetDo()
+	message = &dns.Msg{}
+	m.setEdns0(message, r, &dns.EDNS0_SUBNET{SourceScope: 20})
+	opt := message.IsEdns0()
+	assert.Equal(t, ednsUDPSize, opt.UDPSize())
+	assert.True(t, opt.Do())
+	assert.Equal(t, []dns.EDNS0{&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, SourceScope: 20, Address: net.ParseIP("198.51.100.0").To4()}}, opt.Option)
+	assert.Equal(t, uint8(0), findClientSubnet(r).SourceScope)
+}
+
+func TestManager_HandleDnsRequest_Edns0(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	records := types.Records{}
+	for i := 1; i <= 60; i++ {
+		records["big.local._A"] = append(records["big.local._A"], &types.Record{Name: "big.local", Type: "A", Value: fmt.Sprintf("127.0.0.%d", i)})
+	}
+	records["foo.local._A"] = []*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}
+	udpAddr := &net.UDPAddr{IP: net.ParseIP("203.0.113.10"), Port: 4000}
+	tcpAddr := &net.TCPAddr{IP: net.ParseIP("203.0.113.10"), Port: 4000}
+
+	tests := []struct {
+		name        string
+		qname       string
+		udpSize     uint16
+		version     uint8
+		remoteAddr  net.Addr
+		fallbackCfg config.FallbackConfig
+		mockFn      func(clientDns *mockTypes.MockClientDNS)
+		checkFn     func(t *testing.T, msg *dns.Msg)
+	}{
+		{
+			name:       "SuccessWithoutEdns0",
+			qname:      "foo.local.",
+			remoteAddr: udpAddr,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Nil(t, msg.IsEdns0())
+				assert.Len(t, msg.Answer, 1)
+				assert.True(t, msg.Compress)
+			},
+		},
+		{
+			name:       "SuccessTruncatedWithoutEdns0",
+			qname:      "big.local.",
+			remoteAddr: udpAddr,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.True(t, msg.Truncated)
+				assert.Less(t, len(msg.Answer), 60)
+				assert.LessOrEqual(t, msg.Len(), dns.MinMsgSize)
+			},
+		},
+		{
+			name:       "SuccessTruncatedWithEdns0",
+			qname:      "big.local.",
+			udpSize:    600,
+			remoteAddr: udpAddr,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.True(t, msg.Truncated)
+				assert.NotNil(t, msg.IsEdns0())
+				assert.LessOrEqual(t, msg.Len(), 600)
+			},
+		},
+		{
+			name:       "SuccessNotTruncatedWithEdns0",
+			qname:      "big.local.",
+			udpSize:    4096,
+			remoteAddr: udpAddr,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.False(t, msg.Truncated)
+				assert.Len(t, msg.Answer, 60)
+				assert.Equal(t, ednsUDPSize, msg.IsEdns0().UDPSize())
+			},
+		},
+		{
+			name:       "SuccessNotTruncatedTcp",
+			qname:      "big.local.",
+			remoteAddr: tcpAddr,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.False(t, msg.Truncated)
+				assert.Len(t, msg.Answer, 60)
+			},
+		},
+		{
+			name:       "FailBadVersion",
+			qname:      "foo.local.",
+			udpSize:    1232,
+			version:    1,
+			remoteAddr: udpAddr,
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Equal(t, dns.RcodeBadVers, msg.Rcode)
+				assert.Empty(t, msg.Answer)
+				_, err := msg.Pack()
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:        "SuccessFallbackClientSubnet",
+			qname:       "example.com.",
+			udpSize:     1232,
+			remoteAddr:  udpAddr,
+			fallbackCfg: config.FallbackConfig{Enable: true, Nameservers: []string{"1.1.1.1"}, ClientSubnet: config.ClientSubnetConfig{Enable: true, Ipv4Prefix: 24, Ipv6Prefix: 56}},
+			mockFn: func(clientDns *mockTypes.MockClientDNS) {
+				clientDns.EXPECT().Exchange(gomock.Any(), gomock.Eq("1.1.1.1:53")).Times(1).DoAndReturn(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+					subnet := findClientSubnet(msg)
+					assert.Equal(t, uint8(24), subnet.SourceNetmask)
+					assert.Equal(t, "203.0.113.0", subnet.Address.String())
+					response := &dns.Msg{Answer: []dns.RR{newTestRR("example.com. 60 IN A 127.0.0.1")}}
+					response.SetEdns0(dns.DefaultMsgSize, false)
+					response.IsEdns0().Option = append(response.IsEdns0().Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, SourceScope: 24, Address: subnet.Address})
+					return response, time.Duration(1), nil
+				})
+			},
+			checkFn: func(t *testing.T, msg *dns.Msg) {
+				assert.Len(t, msg.Answer, 1)
+				assert.Nil(t, findClientSubnet(msg))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			ctx := context.TestContext(buffer)
+			client := mockTypes.NewMockClientDNS(ctrl)
+			if tt.mockFn != nil {
+				tt.mockFn(client)
+			}
+			m := &Manager{logger: ctx.Logger, records: records, fallbackCfg: tt.fallbackCfg, clientDNS: client}
+			message := &dns.Msg{}
+			message.SetQuestion(tt.qname, dns.TypeA)
+			if tt.udpSize > 0 {
+				message.SetEdns0(tt.udpSize, false)
+				message.IsEdns0().SetVersion(tt.version)
+			}
+			responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+			responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(tt.remoteAddr)
+			responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).DoAndReturn(func(msg *dns.Msg) error {
+				tt.checkFn(t, msg)
+				return nil
+			})
+
+			m.HandleDnsRequest()(responseWriter, message)
+			assert.Empty(t, buffer.String())
+		})
+	}
+}
+
+func TestManager_HandleDnsRequest_Edns0ClientSubnetEcho(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := mockTypes.NewMockClientDNS(ctrl)
+	client.EXPECT().Exchange(gomock.Any(), gomock.Eq("1.1.1.1:53")).Times(1).DoAndReturn(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+		response := &dns.Msg{Answer: []dns.RR{newTestRR("example.com. 60 IN A 127.0.0.1")}}
+		response.SetEdns0(dns.DefaultMsgSize, false)
+		response.IsEdns0().Option = append(response.IsEdns0().Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, SourceScope: 16, Address: findClientSubnet(msg).Address})
+		return response, time.Duration(1), nil
+	})
+	m := &Manager{
+		logger:      context.TestContext(nil).Logger,
+		records:     types.Records{},
+		fallbackCfg: config.FallbackConfig{Enable: true, Nameservers: []string{"1.1.1.1"}, ClientSubnet: config.ClientSubnetConfig{Enable: true, Ipv4Prefix: 24, Ipv6Prefix: 56}},
+		clientDNS:   client,
+	}
+	message := newTestSubnetMsg(1232, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("198.51.100.0").To4()})
+	responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+	responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+	responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).DoAndReturn(func(msg *dns.Msg) error {
+		subnet := findClientSubnet(msg)
+		assert.Equal(t, uint8(24), subnet.SourceNetmask)
+		assert.Equal(t, uint8(16), subnet.SourceScope)
+		return nil
+	})
+
+	m.HandleDnsRequest()(responseWriter, message)
+}
@@ -0,0 +1,92 @@
+package dns
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+)
+
+type SelfQueryStatus struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+type HealthStatus struct {
+	Healthy   bool            `json:"healthy"`
+	Listeners map[string]bool `json:"listeners"`
+}
+
+type ReadyStatus struct {
+	Ready     bool                      `json:"ready"`
+	Listeners map[string]bool           `json:"listeners"`
+	Providers map[string]ProviderStatus `json:"providers"`
+	SelfQuery *SelfQueryStatus          `json:"self_query,omitempty"`
+}
+
+func (m *Manager) SetListenerReady(network string, addr net.Addr) {
+	m.statusMtx.Lock()
+	defer m.statusMtx.Unlock()
+	if m.listeners == nil {
+		m.listeners = map[string]net.Addr{}
+	}
+	m.listeners[network] = addr
+}
+
+func (m *Manager) Health() HealthStatus {
+	m.statusMtx.RLock()
+	defer m.statusMtx.RUnlock()
+	status := HealthStatus{Healthy: true, Listeners: map[string]bool{}}
+	for _, network := range []string{"udp", "tcp"} {
+		_, ok := m.listeners[network]
+		status.Listeners[network] = ok
+		status.Healthy = status.Healthy && ok
+	}
+	return status
+}
+
+func (m *Manager) Readiness() ReadyStatus {
+	health := m.Health()
+	status := ReadyStatus{Ready: health.Healthy, Listeners: health.Listeners, Providers: map[string]ProviderStatus{}}
+
+	m.statusMtx.RLock()
+	for providerId := range m.providers {
+		providerStatus := m.providerStatus(providerId)
+		status.Providers[providerId] = providerStatus
+		status.Ready = status.Ready && providerStatus.Ready
+	}
+	udpAddr := m.listeners["udp"]
+	m.statusMtx.RUnlock()
+
+	if m.readyQuery != "" {
+		status.SelfQuery = m.selfQuery(udpAddr)
+		status.Ready = status.Ready && status.SelfQuery.Ready
+	}
+	return status
+}
+
+func (m *Manager) selfQuery(addr net.Addr) *SelfQueryStatus {
+	status := &SelfQueryStatus{Name: dns.Fqdn(m.readyQuery)}
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		status.Error = "dns listener is not ready"
+		return status
+	}
+	ip := udpAddr.IP
+	if ip == nil || ip.IsUnspecified() {
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+
+	message := new(dns.Msg)
+	message.SetQuestion(status.Name, dns.TypeA)
+	response, _, err := m.readyClientDNS.Exchange(message, net.JoinHostPort(ip.String(), fmt.Sprintf("%d", udpAddr.Port)))
+	switch {
+	case err != nil:
+		status.Error = err.Error()
+	case response.Rcode != dns.RcodeSuccess:
+		status.Error = fmt.Sprintf("unexpected rcode %s", dns.RcodeToString[response.Rcode])
+	default:
+		status.Ready = true
+	}
+	return status
+}
@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"errors"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestManager_Health(t *testing.T) {
+	m := &Manager{}
+	assert.Equal(t, HealthStatus{Listeners: map[string]bool{"udp": false, "tcp": false}}, m.Health())
+
+	m.SetListenerReady("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53})
+	assert.Equal(t, HealthStatus{Listeners: map[string]bool{"udp": true, "tcp": false}}, m.Health())
+
+	m.SetListenerReady("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53})
+	assert.Equal(t, HealthStatus{Healthy: true, Listeners: map[string]bool{"udp": true, "tcp": true}}, m.Health())
+}
+
+func TestManager_Readiness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	listeners := map[string]net.Addr{
+		"udp": &net.UDPAddr{IP: net.IPv4zero, Port: 5353},
+		"tcp": &net.TCPAddr{IP: net.IPv4zero, Port: 5353},
+	}
+	tests := []struct {
+		name       string
+		listeners  map[string]net.Addr
+		statuses   map[string]ProviderStatus
+		readyQuery string
+		mockFn     func(client *mockTypes.MockClientDNS)
+		wantReady  bool
+		wantQuery  *SelfQueryStatus
+	}{
+		{
+			name:      "SuccessReady",
+			listeners: listeners,
+			statuses:  map[string]ProviderStatus{"fs": {Ready: true, Records: 1}},
+			wantReady: true,
+		},
+		{
+			name:      "FailListenerNotBound",
+			listeners: map[string]net.Addr{"udp": listeners["udp"]},
+			statuses:  map[string]ProviderStatus{"fs": {Ready: true, Records: 1}},
+		},
+		{
+			name:      "FailProviderNotLoaded",
+			listeners: listeners,
+			statuses:  map[string]ProviderStatus{},
+		},
+		{
+			name:       "SuccessSelfQuery",
+			listeners:  listeners,
+			statuses:   map[string]ProviderStatus{"fs": {Ready: true, Records: 1}},
+			readyQuery: "foo.local",
+			mockFn: func(client *mockTypes.MockClientDNS) {
+				client.EXPECT().Exchange(gomock.Any(), "127.0.0.1:5353").Times(1).Return(&dns.Msg{}, time.Duration(0), nil)
+			},
+			wantReady: true,
+			wantQuery: &SelfQueryStatus{Name: "foo.local.", Ready: true},
+		},
+		{
+			name:       "FailSelfQueryRcode",
+			listeners:  listeners,
+			statuses:   map[string]ProviderStatus{"fs": {Ready: true, Records: 1}},
+			readyQuery: "foo.local.",
+			mockFn: func(client *mockTypes.MockClientDNS) {
+				client.EXPECT().Exchange(gomock.Any(), "127.0.0.1:5353").Times(1).Return(&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}}, time.Duration(0), nil)
+			},
+			wantQuery: &SelfQueryStatus{Name: "foo.local.", Error: "unexpected rcode SERVFAIL"},
+		},
+		{
+			name:       "FailSelfQueryExchange",
+			listeners:  listeners,
+			statuses:   map[string]ProviderStatus{"fs": {Ready: true, Records: 1}},
+			readyQuery: "foo.local.",
+			mockFn: func(client *mockTypes.MockClientDNS) {
+				client.EXPECT().Exchange(gomock.Any(), "127.0.0.1:5353").Times(1).Return(nil, time.Duration(0), errors.New("timeout"))
+			},
+			wantQuery: &SelfQueryStatus{Name: "foo.local.", Error: "timeout"},
+		},
+		{
+			name:       "FailSelfQueryListenerNotBound",
+			listeners:  map[string]net.Addr{},
+			statuses:   map[string]ProviderStatus{"fs": {Ready: true, Records: 1}},
+			readyQuery: "foo.local.",
+			wantQuery:  &SelfQueryStatus{Name: "foo.local.", Error: "dns listener is not ready"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mockTypes.NewMockClientDNS(ctrl)
+			if tt.mockFn != nil {
+				tt.mockFn(client)
+			}
+			provider := mockTypes.NewMockProvider(ctrl)
+			provider.EXPECT().GetType().AnyTimes().Return("fs")
+			m := &Manager{
+				providers:       types.Providers{"fs": provider},
+				listeners:       tt.listeners,
+				providersStatus: tt.statuses,
+				readyQuery:      tt.readyQuery,
+				readyClientDNS:  client,
+			}
+			got := m.Readiness()
+			assert.Equal(t, tt.wantReady, got.Ready)
+			assert.Equal(t, tt.statuses["fs"].Ready, got.Providers["fs"].Ready)
+			assert.Equal(t, "fs", got.Providers["fs"].Type)
+			assert.Equal(t, tt.wantQuery, got.SelfQuery)
+		})
+	}
+}
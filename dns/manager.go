@@ -1,22 +1,34 @@
 package dns
 
 import (
-	"dario.cat/mergo"
 	"fmt"
 	"github.com/alexandreh2ag/go-dns-discover/config"
 	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/metrics"
+	"github.com/alexandreh2ag/go-dns-discover/querylog"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
 	"github.com/alexandreh2ag/go-dns-discover/types"
 	"github.com/miekg/dns"
 	"log/slog"
+	"net"
 	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	cnameMaxDepth = 8
+
+	sourceZone     = "zone"
+	sourceFallback = "fallback"
+
+	readyQueryTimeout = 500 * time.Millisecond
+)
+
 func CreateManager(ctx *context.Context, providers types.Providers) *Manager {
 	clientDNS := &dns.Client{Net: "udp", Timeout: time.Duration(ctx.Config.Fallback.Timeout) * time.Second}
-	return &Manager{logger: ctx.Logger, providers: providers, done: ctx.Done(), fallbackCfg: ctx.Config.Fallback, clientDNS: clientDNS}
+	return &Manager{logger: ctx.Logger, providers: providers, done: ctx.Done(), fallbackCfg: ctx.Config.Fallback, clientDNS: clientDNS, readyClientDNS: &dns.Client{Net: "udp", Timeout: readyQueryTimeout}, zones: createZoneTransfers(ctx.Config.Transfer), dynamicZones: createUpdateZones(ctx.Config.Update), readyQuery: ctx.Config.Http.ReadyQuery, restartPolicies: createRestartPolicies(ctx.Config.Providers), mergePolicy: ctx.Config.MergePolicy, providersPriority: createProvidersPriority(ctx.Config.Providers)}
 }
 
 type Manager struct {
@@ -25,26 +37,51 @@ type Manager struct {
 	providers             types.Providers
 	records               types.Records
 	cacheProvidersRecords map[string]types.Records
+	zones                 map[string]*zoneTransfer
+	zonesMtx              sync.RWMutex
+	dynamicZones          map[string]config.UpdateZoneConfig
+	updateStore           types.StoreProvider
+	tsigKeys              tsig.Keys
+	dnssecZones           DnssecZones
+	recordsSource         map[string]string
+	recordsNames          map[string]struct{}
+	recordsMtx            sync.RWMutex
+	queryLogger           *querylog.Logger
+	metrics               *metrics.Metrics
+	readyQuery            string
+	listeners             map[string]net.Addr
+	providersStatus       map[string]ProviderStatus
+	statusMtx             sync.RWMutex
+	restartPolicies       map[string]restartPolicy
+	mergePolicy           string
+	providersPriority     map[string]int
+	conflicts             map[string]RecordsConflict
 
 	clientDNS         types.ClientDNS
+	readyClientDNS    types.ClientDNS
 	configurationChan chan types.Message
+	staleChan         chan string
+	stop              chan struct{}
 	done              chan bool
 }
 
 func (m *Manager) Start() {
+	m.statusMtx.Lock()
 	m.cacheProvidersRecords = make(map[string]types.Records)
+	for _, provider := range m.providers {
+		m.cacheProvidersRecords[provider.GetId()] = types.Records{}
+	}
+	m.statusMtx.Unlock()
 	m.configurationChan = make(chan types.Message, 40)
+	m.staleChan = make(chan string)
+	m.stop = make(chan struct{})
 	wg := sync.WaitGroup{}
 	go m.listen()
 	for _, provider := range m.providers {
 		wg.Add(1)
-		m.cacheProvidersRecords[provider.GetId()] = types.Records{}
 		go func(prd types.Provider) {
 			defer wg.Done()
-			err := prd.Provide(m.configurationChan)
-			if err != nil {
-				m.logger.Error(fmt.Sprintf("error when provide %s: %v", prd.GetId(), err))
-			}
+			m.superviseProvider(prd)
 		}(provider)
 	}
 	wg.Wait()
@@ -59,36 +96,90 @@ func (m *Manager) listen() {
 				continue
 			}
 			m.logger.Debug(fmt.Sprintf("notification update config from %s with %d records", message.GetProviderId(), len(message.Records)))
-			m.cacheProvidersRecords[message.GetProviderId()] = message.Records
-			tmpRecords := types.Records{}
-
-			for providerKey, providerRecords := range m.cacheProvidersRecords {
-				err := mergo.Merge(&tmpRecords, providerRecords, mergo.WithAppendSlice)
-				if err != nil {
-					m.logger.Error(fmt.Sprintf("error when merging provider (%s) records: %v", providerKey, err))
-				}
+			m.setProviderRecords(message.GetProviderId(), message.Records)
+			m.metrics.ObserveProviderUpdate(message.GetProviderId(), countRecords(message.Records))
+			m.mergeRecords()
+		case providerId := <-m.staleChan:
+			if !m.expireProviderRecords(providerId, m.restartPolicy(providerId).maxStaleness) {
+				continue
 			}
-			m.records = tmpRecords
+			m.metrics.ObserveProviderExpire(providerId)
+			m.mergeRecords()
 		case <-m.done:
 			close(m.configurationChan)
+			if m.stop != nil {
+				close(m.stop)
+			}
 			return
 		}
 	}
 }
 
 func (m *Manager) GetRecords() types.Records {
+	m.recordsMtx.RLock()
+	defer m.recordsMtx.RUnlock()
 	return m.records
 }
 
+func (m *Manager) getRecordsSource() map[string]string {
+	m.recordsMtx.RLock()
+	defer m.recordsMtx.RUnlock()
+	return m.recordsSource
+}
+
+func (m *Manager) getRecordsNames() map[string]struct{} {
+	m.recordsMtx.RLock()
+	defer m.recordsMtx.RUnlock()
+	return m.recordsNames
+}
+
 func (m *Manager) HandleDnsRequest() func(w dns.ResponseWriter, r *dns.Msg) {
 	return func(w dns.ResponseWriter, r *dns.Msg) {
+		start := time.Now()
+		remoteAddr := w.RemoteAddr()
 		message := new(dns.Msg)
 		message.SetReply(r)
-		message.Compress = false
+		source := ""
+		defer func() {
+			latency := time.Since(start)
+			m.logQuery(message, remoteAddr, source, latency)
+			m.observeQuery(message, source, latency)
+		}()
+
+		keyName, errTsig := m.verifyTsig(w, r)
+		if errTsig != nil {
+			m.replyTsigError(w, r, message, errTsig)
+			return
+		}
+
+		if r.Opcode == dns.OpcodeQuery && len(r.Question) == 1 && slices.Contains([]uint16{dns.TypeAXFR, dns.TypeIXFR}, r.Question[0].Qtype) {
+			if m.handleTransfer(w, r, message, keyName) {
+				source = sourceZone
+			}
+			return
+		}
+
+		subnet := m.clientSubnet(r, remoteAddr)
+		message.Compress = true
 		m.logger.Debug(fmt.Sprintf("received a DNS message %s", message.String()))
-		switch r.Opcode {
-		case dns.OpcodeQuery:
-			m.parseQuestions(message)
+		if opt := r.IsEdns0(); opt != nil && opt.Version() != 0 {
+			message.Rcode = dns.RcodeBadVers
+		} else {
+			switch r.Opcode {
+			case dns.OpcodeQuery:
+				source = m.parseQuestions(message, subnet)
+				m.secureMessage(message, r)
+			case dns.OpcodeNotify:
+				m.handleNotify(message, remoteAddr, keyName)
+			case dns.OpcodeUpdate:
+				m.handleUpdate(message, r, keyName, remoteAddr)
+			}
+		}
+		m.setEdns0(message, r, subnet)
+		m.truncateMessage(message, r, remoteAddr, keyName)
+
+		if rrTsig := r.IsTsig(); rrTsig != nil && keyName != "" {
+			message.SetTsig(rrTsig.Hdr.Name, rrTsig.Algorithm, rrTsig.Fudge, time.Now().Unix())
 		}
 
 		err := w.WriteMsg(message)
@@ -98,100 +189,134 @@ func (m *Manager) HandleDnsRequest() func(w dns.ResponseWriter, r *dns.Msg) {
 	}
 }
 
-func (m *Manager) parseQuestions(message *dns.Msg) {
+func (m *Manager) handleNotify(message *dns.Msg, remoteAddr net.Addr, keyName string) {
+	message.Authoritative = true
+	message.Rcode = dns.RcodeRefused
+	for _, question := range message.Question {
+		for _, provider := range m.providers {
+			notifyProvider, ok := provider.(types.NotifyProvider)
+			if ok && notifyProvider.Notify(question.Name, remoteAddr, keyName) {
+				m.logger.Info(fmt.Sprintf("notify received for zone %s from %s", question.Name, remoteAddr.String()))
+				message.Rcode = dns.RcodeSuccess
+			}
+		}
+	}
+}
+
+func (m *Manager) parseQuestions(message *dns.Msg, subnet *dns.EDNS0_SUBNET) string {
+	source := ""
 	for _, question := range message.Question {
-		m.answerQuestion(message, question)
+		if questionSource := m.answerQuestion(message, question, subnet); source == "" {
+			source = questionSource
+		}
 	}
+	return source
 }
-func (m *Manager) answerQuestion(message *dns.Msg, question dns.Question) {
+func (m *Manager) answerQuestion(message *dns.Msg, question dns.Question, subnet *dns.EDNS0_SUBNET) string {
 	records := m.findRecords(question)
 
 	if len(records) > 0 {
 		for _, record := range records {
-			rr, err := dns.NewRR(
-				fmt.Sprintf("%s %s %s", record.Name, record.Type, record.Value),
-			)
+			rr, err := types.ConvertRecordToRR(record)
 			if err == nil {
 				message.Answer = append(message.Answer, rr)
 			}
 		}
-	} else {
-		if m.fallbackCfg.Enable {
-			msg := &dns.Msg{
-				MsgHdr:   dns.MsgHdr{Id: message.Id, Opcode: dns.OpcodeQuery, RecursionDesired: true, RecursionAvailable: true},
-				Question: []dns.Question{{Name: question.Name, Qtype: question.Qtype, Qclass: question.Qclass}},
+		last := records[len(records)-1]
+		if question.Qtype != dns.TypeCNAME && strings.EqualFold(last.Type, "CNAME") && !m.nameExists(last.Value) && m.wildcardSource(last.Value) == "" {
+			if answers, ok := m.queryFallback(message, dns.Question{Name: dns.Fqdn(last.Value), Qtype: question.Qtype, Qclass: question.Qclass}, subnet); ok {
+				message.Answer = append(message.Answer, answers...)
 			}
-			for _, nameserver := range m.fallbackCfg.Nameservers {
-				res, err := m.answerWithFallback(nameserver, msg)
-				if err == nil {
-					message.Answer = res.Answer
-					return
-				}
+		}
+		return m.findRecordsSource(question, records)
+	} else if soa := m.zoneSOA(question.Name); soa != nil && question.Qtype == dns.TypeSOA {
+		message.Authoritative = true
+		message.Answer = append(message.Answer, soa)
+		return sourceZone
+	} else if zone := m.findDnssecZone(question.Name); zone != nil {
+		m.answerDnssecZone(message, question, zone)
+		return sourceZone
+	} else if answers, ok := m.queryFallback(message, question, subnet); ok {
+		message.Answer = answers
+		return sourceFallback
+	}
+	return ""
+}
+
+func (m *Manager) queryFallback(message *dns.Msg, question dns.Question, subnet *dns.EDNS0_SUBNET) ([]dns.RR, bool) {
+	if !m.fallbackCfg.Enable {
+		return nil, false
+	}
+	msg := &dns.Msg{
+		MsgHdr:   dns.MsgHdr{Id: message.Id, Opcode: dns.OpcodeQuery, RecursionDesired: true, RecursionAvailable: true},
+		Question: []dns.Question{{Name: question.Name, Qtype: question.Qtype, Qclass: question.Qclass}},
+	}
+	msg.SetEdns0(dns.DefaultMsgSize, false)
+	if subnet != nil {
+		msg.IsEdns0().Option = append(msg.IsEdns0().Option, subnet)
+	}
+	for _, nameserver := range m.fallbackCfg.Nameservers {
+		res, err := m.answerWithFallback(nameserver, msg)
+		if err == nil {
+			if responseSubnet := findClientSubnet(res); subnet != nil && responseSubnet != nil {
+				subnet.SourceScope = responseSubnet.SourceScope
 			}
+			return res.Answer, true
 		}
 	}
+	return nil, false
 }
 
 func (m *Manager) findRecords(question dns.Question) []*types.Record {
+	return m.findRecordsChain(question, []string{})
+}
+
+func (m *Manager) findRecordsChain(question dns.Question, chain []string) []*types.Record {
+	servedRecords := m.GetRecords()
 	key := types.FormatRecordKey(question.Name, types.ConvertTypeDNSUintToStr(question.Qtype))
-	if entriesDns, ok := m.records[key]; ok {
+	if entriesDns, ok := servedRecords[key]; ok {
 		return entriesDns
 	}
 
-	if slices.Contains([]uint16{dns.TypeNS, dns.TypeSOA}, question.Qtype) {
-		domainSplit := strings.Split(question.Name, ".")
-		if len(domainSplit) > 0 && question.Name != "*." {
-			i := 1
-			if domainSplit[0] == "*" {
-				i = 2
-			}
-			recordsFound := m.findRecords(dns.Question{Name: "*." + strings.Join(domainSplit[i:len(domainSplit)], "."), Qtype: question.Qtype})
-
-			for _, record := range recordsFound {
-				record.Name = question.Name[:len(question.Name)-1]
-			}
-			return recordsFound
-		}
-	}
-
-	if question.Qtype == dns.TypeA {
+	if question.Qtype != dns.TypeCNAME {
 		keyCNAME := types.FormatRecordKey(question.Name, types.ConvertTypeDNSUintToStr(dns.TypeCNAME))
-		if entriesDns, ok := m.records[keyCNAME]; ok {
+		if entriesDns, ok := servedRecords[keyCNAME]; ok {
 			if len(entriesDns) == 0 {
 				m.logger.Error(fmt.Sprintf("no DNS records for %s type CNAME", question.Name))
 				return []*types.Record{}
 			}
+			if len(entriesDns) > 1 {
+				m.logger.Warn(fmt.Sprintf("multiple CNAME records for %s, only the first one is used", question.Name))
+			}
 			record := entriesDns[0]
-			records := m.findRecords(dns.Question{Name: record.Value, Qtype: dns.TypeA})
+			chain = append(chain, strings.ToLower(dns.Fqdn(question.Name)))
+			if slices.Contains(chain, strings.ToLower(dns.Fqdn(record.Value))) {
+				m.logger.Warn(fmt.Sprintf("CNAME loop detected for %s", chain[0]))
+				return []*types.Record{record}
+			}
+			if len(chain) > cnameMaxDepth {
+				m.logger.Warn(fmt.Sprintf("CNAME chain too long for %s", chain[0]))
+				return []*types.Record{record}
+			}
+			records := m.findRecordsChain(dns.Question{Name: dns.Fqdn(record.Value), Qtype: question.Qtype}, chain)
 			return append([]*types.Record{record}, records...)
 		}
 	}
 
-	if slices.Contains([]uint16{dns.TypeA, dns.TypeCNAME}, question.Qtype) && question.Name != "*." {
-		domainSplit := strings.Split(question.Name, ".")
-		if len(domainSplit) > 0 {
-			i := 1
-			if domainSplit[0] == "*" {
-				i = 2
-			}
-			if question.Qtype == dns.TypeA {
-				records := []*types.Record{}
-				recordsFound := m.findRecords(dns.Question{Name: "*." + strings.Join(domainSplit[i:len(domainSplit)], "."), Qtype: dns.TypeA})
-
-				for index, record := range recordsFound {
-					name := record.Name
-					typeDns := record.Type
-					if index == 0 {
-						name = question.Name[:len(question.Name)-1]
-					}
-
-					records = append(records, &types.Record{Name: name, Type: typeDns, Value: record.Value})
-				}
-				return records
-			}
+	source := m.wildcardSource(question.Name)
+	if source == "" {
+		return []*types.Record{}
+	}
+	records := []*types.Record{}
+	recordsFound := m.findRecordsChain(dns.Question{Name: source, Qtype: question.Qtype}, chain)
+	for index, record := range recordsFound {
+		name := record.Name
+		if index == 0 || name == recordsFound[0].Name {
+			name = strings.TrimSuffix(question.Name, ".")
 		}
+		records = append(records, &types.Record{Name: name, Type: record.Type, Value: record.Value, TTL: record.TTL})
 	}
-	return []*types.Record{}
+	return records
 }
 
 func (m *Manager) answerWithFallback(nameserver string, message *dns.Msg) (*dns.Msg, error) {
@@ -199,6 +324,8 @@ func (m *Manager) answerWithFallback(nameserver string, message *dns.Msg) (*dns.
 		nameserver += ":53"
 	}
 
+	start := time.Now()
 	response, _, err := m.clientDNS.Exchange(message, nameserver)
+	m.metrics.ObserveFallback(nameserver, err, time.Since(start))
 	return response, err
 }
@@ -8,10 +8,12 @@ import (
 	"github.com/alexandreh2ag/go-dns-discover/context"
 	mockMiekgDns "github.com/alexandreh2ag/go-dns-discover/mocks/miekg"
 	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
 	"github.com/alexandreh2ag/go-dns-discover/types"
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
+	"net"
 	"testing"
 	"time"
 )
@@ -21,6 +23,7 @@ func TestCreateManager(t *testing.T) {
 	providers := types.Providers{}
 	got := CreateManager(ctx, providers)
 	assert.NotNil(t, got)
+	assert.Equal(t, readyQueryTimeout, got.readyClientDNS.(*dns.Client).Timeout)
 }
 
 func TestManager_listen(t *testing.T) {
@@ -44,7 +47,7 @@ func TestManager_listen(t *testing.T) {
 	recordsPrd1 := types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}
 	m.configurationChan <- types.Message{Provider: provider, Records: recordsPrd1}
 	time.Sleep(100 * time.Millisecond)
-	assert.Equal(t, recordsPrd1, m.records)
+	assert.Equal(t, recordsPrd1, m.GetRecords())
 	assert.Equal(t, map[string]types.Records{"provider": recordsPrd1, "provider2": {}}, m.cacheProvidersRecords)
 
 	recordsPrd2 := types.Records{
@@ -54,20 +57,23 @@ func TestManager_listen(t *testing.T) {
 	m.configurationChan <- types.Message{Provider: provider2, Records: recordsPrd2}
 	time.Sleep(100 * time.Millisecond)
 
-	assert.ElementsMatch(t, []*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.1"}, {Name: "foo.local", Type: "A", Value: "127.0.0.2"}}, m.records["foo.local._A"])
-	assert.ElementsMatch(t, []*types.Record{{Name: "bar.local", Type: "CNAME", Value: "bar.local."}}, m.records["bar.local._CNAME"])
+	assert.ElementsMatch(t, []*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.1"}, {Name: "foo.local", Type: "A", Value: "127.0.0.2"}}, m.GetRecords()["foo.local._A"])
+	assert.ElementsMatch(t, []*types.Record{{Name: "bar.local", Type: "CNAME", Value: "bar.local."}}, m.GetRecords()["bar.local._CNAME"])
 	assert.Equal(t, map[string]types.Records{"provider": recordsPrd1, "provider2": recordsPrd2}, m.cacheProvidersRecords)
+	assert.Equal(t, map[string]string{"foo.local._A": "provider", "bar.local._CNAME": "provider2"}, m.getRecordsSource())
+	assert.True(t, m.providersStatus["provider2"].Ready)
+	assert.Equal(t, 2, m.providersStatus["provider2"].Records)
 
 	m.configurationChan <- types.Message{Provider: provider2, Records: types.Records{}}
 	time.Sleep(100 * time.Millisecond)
-	assert.Equal(t, recordsPrd1, m.records)
+	assert.Equal(t, recordsPrd1, m.GetRecords())
 	assert.Equal(t, map[string]types.Records{"provider": recordsPrd1, "provider2": {}}, m.cacheProvidersRecords)
 
 	m.configurationChan <- types.Message{Provider: provider3, Records: types.Records{}}
 	time.Sleep(100 * time.Millisecond)
-	assert.Contains(t, buffer.String(), "routine received a message that does not belong to any provider")
 	ctx.Cancel()
 	<-m.configurationChan
+	assert.Contains(t, buffer.String(), "routine received a message that does not belong to any provider")
 }
 
 func TestManager_Start_Success(t *testing.T) {
@@ -84,6 +90,7 @@ func TestManager_Start_Success(t *testing.T) {
 	}
 	m.Start()
 	assert.Equal(t, map[string]types.Records{"provider": {}}, m.cacheProvidersRecords)
+	assert.Equal(t, ProviderStatus{State: ProviderStateStopped}, m.providersStatus["provider"])
 }
 func TestManager_Start_Fail(t *testing.T) {
 	buffer := &bytes.Buffer{}
@@ -94,14 +101,16 @@ func TestManager_Start_Fail(t *testing.T) {
 	provider.EXPECT().GetId().AnyTimes().Return("provider")
 	provider.EXPECT().Provide(gomock.Any()).Times(1).Return(errors.New("fail"))
 	m := &Manager{
-		logger:    ctx.Logger,
-		providers: types.Providers{"provider": provider},
-		done:      make(chan bool),
+		logger:          ctx.Logger,
+		providers:       types.Providers{"provider": provider},
+		restartPolicies: map[string]restartPolicy{"provider": {disable: true}},
+		done:            make(chan bool),
 	}
 	m.Start()
 	time.Sleep(100 * time.Millisecond)
 	assert.Contains(t, buffer.String(), "fail")
 	assert.Equal(t, map[string]types.Records{"provider": {}}, m.cacheProvidersRecords)
+	assert.Equal(t, ProviderStatus{State: ProviderStateFailed, Error: "fail"}, m.providersStatus["provider"])
 }
 
 func TestManager_answerQuestion(t *testing.T) {
@@ -121,6 +130,12 @@ func TestManager_answerQuestion(t *testing.T) {
 			message: &dns.Msg{Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}},
 			want:    "ANSWER SECTION:\nfoo.local.\t3600\tIN\tA\t127.0.0.1",
 		},
+		{
+			name:    "SuccessSimpleEntryAWithTTL",
+			records: types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1", TTL: 60}}},
+			message: &dns.Msg{Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}},
+			want:    "ANSWER SECTION:\nfoo.local.\t60\tIN\tA\t127.0.0.1",
+		},
 		{
 			name: "SuccessSimpleEntryCNAME",
 			records: types.Records{
@@ -159,6 +174,36 @@ func TestManager_answerQuestion(t *testing.T) {
 			},
 			want: "ANSWER SECTION:\nexample.com.\t3600\tIN\tA\t127.0.0.1",
 		},
+		{
+			name:        "SuccessCNAMEExternalWithFallback",
+			records:     types.Records{"foo.local._CNAME": {{Name: "foo.local", Type: "CNAME", Value: "example.com."}}},
+			message:     &dns.Msg{Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}}},
+			fallbackCfg: config.FallbackConfig{Enable: true, Nameservers: []string{"1.1.1.1"}},
+			mockFn: func(clientDns *mockTypes.MockClientDNS) {
+				clientDns.EXPECT().Exchange(gomock.Any(), gomock.Eq("1.1.1.1:53")).Times(1).DoAndReturn(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+					assert.Equal(t, dns.Question{Name: "example.com.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}, msg.Question[0])
+					rr, _ := dns.NewRR("example.com. 60 IN AAAA ::1")
+					return &dns.Msg{Answer: []dns.RR{rr}}, time.Duration(1), nil
+				})
+			},
+			want: "ANSWER SECTION:\nfoo.local.\t3600\tIN\tCNAME\texample.com.\nexample.com.\t60\tIN\tAAAA\t::1",
+		},
+		{
+			name: "SuccessCNAMEInternalNoData",
+			records: types.Records{
+				"foo.local._CNAME": {{Name: "foo.local", Type: "CNAME", Value: "bar.local."}},
+				"bar.local._A":     {{Name: "bar.local", Type: "A", Value: "127.0.0.1"}},
+			},
+			message:     &dns.Msg{Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}}},
+			fallbackCfg: config.FallbackConfig{Enable: true, Nameservers: []string{"1.1.1.1"}},
+			want:        "ANSWER SECTION:\nfoo.local.\t3600\tIN\tCNAME\tbar.local.\n",
+		},
+		{
+			name:    "SuccessCNAMEExternalWithoutFallback",
+			records: types.Records{"foo.local._CNAME": {{Name: "foo.local", Type: "CNAME", Value: "example.com."}}},
+			message: &dns.Msg{Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}},
+			want:    "ANSWER SECTION:\nfoo.local.\t3600\tIN\tCNAME\texample.com.\n",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -170,12 +215,13 @@ func TestManager_answerQuestion(t *testing.T) {
 			}
 
 			m := &Manager{
-				logger:      ctx.Logger,
-				records:     tt.records,
-				fallbackCfg: tt.fallbackCfg,
-				clientDNS:   client,
+				logger:       ctx.Logger,
+				records:      tt.records,
+				recordsNames: createRecordsNames(tt.records),
+				fallbackCfg:  tt.fallbackCfg,
+				clientDNS:    client,
 			}
-			m.answerQuestion(tt.message, tt.message.Question[0])
+			m.answerQuestion(tt.message, tt.message.Question[0], nil)
 			assert.Contains(t, tt.message.String(), tt.want)
 		})
 	}
@@ -188,10 +234,11 @@ func TestManager_parseQuestions(t *testing.T) {
 	message := &dns.Msg{Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}, {Name: "bar.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}}
 	want := "ANSWER SECTION:\nfoo.local.\t3600\tIN\tA\t127.0.0.1\nbar.local.\t3600\tIN\tA\t127.0.0.1\n"
 	m := &Manager{
-		logger:  ctx.Logger,
-		records: records,
+		logger:       ctx.Logger,
+		records:      records,
+		recordsNames: createRecordsNames(records),
 	}
-	m.parseQuestions(message)
+	m.parseQuestions(message, nil)
 	assert.Contains(t, message.String(), want)
 }
 
@@ -205,10 +252,12 @@ func TestManager_HandleDnsRequest_Success(t *testing.T) {
 	message := &dns.Msg{MsgHdr: dns.MsgHdr{Opcode: dns.OpcodeQuery}, Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}}
 	want := "ANSWER SECTION:\nfoo.local.\t3600\tIN\tA\t127.0.0.1\n"
 	m := &Manager{
-		logger:  ctx.Logger,
-		records: records,
+		logger:       ctx.Logger,
+		records:      records,
+		recordsNames: createRecordsNames(records),
 	}
 	responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+	responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
 	responseWriter.EXPECT().WriteMsg(gomock.Any()).DoAndReturn(func(msg *dns.Msg) error {
 		assert.Contains(t, msg.String(), want)
 		return nil
@@ -227,10 +276,12 @@ func TestManager_HandleDnsRequest_Fail(t *testing.T) {
 	records := types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}
 	message := &dns.Msg{MsgHdr: dns.MsgHdr{Opcode: dns.OpcodeQuery}, Question: []dns.Question{{Name: "foo.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}}
 	m := &Manager{
-		logger:  ctx.Logger,
-		records: records,
+		logger:       ctx.Logger,
+		records:      records,
+		recordsNames: createRecordsNames(records),
 	}
 	responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+	responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
 	responseWriter.EXPECT().WriteMsg(gomock.Any()).DoAndReturn(func(msg *dns.Msg) error {
 		return errors.New("fail")
 	})
@@ -239,6 +290,64 @@ func TestManager_HandleDnsRequest_Fail(t *testing.T) {
 	assert.Contains(t, buffer.String(), "fail")
 }
 
+func TestManager_HandleDnsRequest_Notify(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	remoteAddr := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 53}
+	tests := []struct {
+		name      string
+		keyName   string
+		accept    bool
+		wantRcode int
+	}{
+		{
+			name:      "SuccessAccepted",
+			accept:    true,
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:      "SuccessAcceptedWithTsig",
+			keyName:   "transfer.",
+			accept:    true,
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:      "SuccessRefused",
+			accept:    false,
+			wantRcode: dns.RcodeRefused,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := mockTypes.NewMockProvider(ctrl)
+			notifyProvider := mockTypes.NewMockNotifyProvider(ctrl)
+			notifyProvider.EXPECT().Notify("example.com.", remoteAddr, tt.keyName).Times(1).Return(tt.accept)
+			m := &Manager{
+				logger:    ctx.Logger,
+				records:   types.Records{},
+				providers: types.Providers{"provider": provider, "secondary": notifyProvider},
+				tsigKeys:  tsig.Keys{"transfer.": {Algorithm: dns.HmacSHA256, Secret: []byte("secret")}},
+			}
+			message := &dns.Msg{MsgHdr: dns.MsgHdr{Opcode: dns.OpcodeNotify}, Question: []dns.Question{{Name: "example.com.", Qtype: dns.TypeSOA, Qclass: dns.ClassINET}}}
+			responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+			responseWriter.EXPECT().RemoteAddr().Times(1).Return(remoteAddr)
+			if tt.keyName != "" {
+				message.SetTsig(tt.keyName, dns.HmacSHA256, 300, time.Now().Unix())
+				responseWriter.EXPECT().TsigStatus().Times(1).Return(nil)
+			}
+			responseWriter.EXPECT().WriteMsg(gomock.Any()).DoAndReturn(func(msg *dns.Msg) error {
+				assert.Equal(t, tt.wantRcode, msg.Rcode)
+				assert.True(t, msg.Authoritative)
+				return nil
+			})
+
+			m.HandleDnsRequest()(responseWriter, message)
+		})
+	}
+}
+
 func TestManager_findRecords(t *testing.T) {
 	ctx := context.TestContext(nil)
 
@@ -254,6 +363,19 @@ func TestManager_findRecords(t *testing.T) {
 		"*.local._SOA":             {{Name: "*.local", Type: "SOA", Value: "ns.local mail.local 1000 10800 60 300 60"}},
 		"foo.local._NS":            {{Name: "foo.local", Type: "NS", Value: "ns.foo.local"}},
 		"*.local._NS":              {{Name: "*.local", Type: "NS", Value: "ns.local"}},
+		"foo.local._AAAA":          {{Name: "foo.local", Type: "AAAA", Value: "::1"}},
+		"foo.local._TXT":           {{Name: "foo.local", Type: "TXT", Value: "foo"}},
+		"alias.foo.local._CNAME":   {{Name: "alias.foo.local", Type: "CNAME", Value: "bar.foo.local."}},
+		"loop1.local._CNAME":       {{Name: "loop1.local", Type: "CNAME", Value: "loop2.local."}},
+		"loop2.local._CNAME":       {{Name: "loop2.local", Type: "CNAME", Value: "Loop1.local."}},
+		"external.local._CNAME":    {{Name: "external.local", Type: "CNAME", Value: "example.com."}},
+	}
+	maxDepthChain := []*types.Record{}
+	for i := 0; i < 10; i++ {
+		records[fmt.Sprintf("chain%d.local._CNAME", i)] = []*types.Record{{Name: fmt.Sprintf("chain%d.local", i), Type: "CNAME", Value: fmt.Sprintf("chain%d.local.", i+1)}}
+		if i <= cnameMaxDepth {
+			maxDepthChain = append(maxDepthChain, records[fmt.Sprintf("chain%d.local._CNAME", i)][0])
+		}
 	}
 
 	tests := []struct {
@@ -319,8 +441,14 @@ func TestManager_findRecords(t *testing.T) {
 		{
 			name:     "SuccessSOAWildcard",
 			records:  records,
+			question: dns.Question{Name: "new.local.", Qtype: dns.TypeSOA, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "new.local", Type: "SOA", Value: "ns.local mail.local 1000 10800 60 300 60"}},
+		},
+		{
+			name:     "SuccessSOAWildcardNameExists",
+			records:  records,
 			question: dns.Question{Name: "foo.local.", Qtype: dns.TypeSOA, Qclass: dns.ClassINET},
-			want:     []*types.Record{{Name: "foo.local", Type: "SOA", Value: "ns.local mail.local 1000 10800 60 300 60"}},
+			want:     []*types.Record{},
 		},
 		{
 			name:     "SuccessNS",
@@ -346,12 +474,55 @@ func TestManager_findRecords(t *testing.T) {
 			question: dns.Question{Name: "wrong.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
 			want:     []*types.Record{},
 		},
+		{
+			name:     "SuccessCNAMEChainAAAA",
+			records:  records,
+			question: dns.Question{Name: "alias.foo.local.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "alias.foo.local", Type: "CNAME", Value: "bar.foo.local."}, {Name: "bar.foo.local", Type: "CNAME", Value: "foo.local."}, {Name: "foo.local", Type: "AAAA", Value: "::1"}},
+		},
+		{
+			name:     "SuccessCNAMEChainTXT",
+			records:  records,
+			question: dns.Question{Name: "bar.foo.local.", Qtype: dns.TypeTXT, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "bar.foo.local", Type: "CNAME", Value: "foo.local."}, {Name: "foo.local", Type: "TXT", Value: "foo"}},
+		},
+		{
+			name:     "SuccessCNAMENoData",
+			records:  records,
+			question: dns.Question{Name: "bar.foo.local.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "bar.foo.local", Type: "CNAME", Value: "foo.local."}},
+		},
+		{
+			name:     "SuccessCNAMENotFollowedForCNAME",
+			records:  records,
+			question: dns.Question{Name: "alias.foo.local.", Qtype: dns.TypeCNAME, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "alias.foo.local", Type: "CNAME", Value: "bar.foo.local."}},
+		},
+		{
+			name:     "SuccessCNAMELoop",
+			records:  records,
+			question: dns.Question{Name: "loop1.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "loop1.local", Type: "CNAME", Value: "loop2.local."}, {Name: "loop2.local", Type: "CNAME", Value: "Loop1.local."}},
+		},
+		{
+			name:     "SuccessCNAMEMaxDepth",
+			records:  records,
+			question: dns.Question{Name: "chain0.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+			want:     maxDepthChain,
+		},
+		{
+			name:     "SuccessCNAMEExternal",
+			records:  records,
+			question: dns.Question{Name: "external.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "external.local", Type: "CNAME", Value: "example.com."}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &Manager{
-				logger:  ctx.Logger,
-				records: tt.records,
+				logger:       ctx.Logger,
+				records:      tt.records,
+				recordsNames: createRecordsNames(tt.records),
 			}
 			got := m.findRecords(tt.question)
 			assert.Equal(t, tt.want, got)
@@ -0,0 +1,169 @@
+package dns
+
+import (
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	MergePolicyAppend   = "append"
+	MergePolicyPriority = "priority"
+	MergePolicyFirst    = "first"
+	MergePolicyError    = "error"
+)
+
+type RecordsConflict struct {
+	Key       string   `json:"key"`
+	Policy    string   `json:"policy"`
+	Providers []string `json:"providers"`
+	Winner    string   `json:"winner,omitempty"`
+	Shadowed  []string `json:"shadowed,omitempty"`
+}
+
+func createProvidersPriority(providers map[string]config.Provider) map[string]int {
+	priorities := map[string]int{}
+	for providerId, providerCfg := range providers {
+		priorities[providerId] = providerCfg.Priority
+	}
+	return priorities
+}
+
+func (m *Manager) sortedProviderIds() []string {
+	providerIds := make([]string, 0, len(m.cacheProvidersRecords))
+	for providerId := range m.cacheProvidersRecords {
+		providerIds = append(providerIds, providerId)
+	}
+	sort.Slice(providerIds, func(i, j int) bool {
+		priorityI, priorityJ := m.providersPriority[providerIds[i]], m.providersPriority[providerIds[j]]
+		if priorityI != priorityJ {
+			return priorityI > priorityJ
+		}
+		return providerIds[i] < providerIds[j]
+	})
+	return providerIds
+}
+
+func (m *Manager) mergeRecords() {
+	policy := m.mergePolicy
+	if policy == "" {
+		policy = MergePolicyAppend
+	}
+
+	contributors := map[string][]string{}
+	for _, providerId := range m.sortedProviderIds() {
+		for key := range m.cacheProvidersRecords[providerId] {
+			contributors[key] = append(contributors[key], providerId)
+		}
+	}
+
+	tmpRecords := types.Records{}
+	tmpRecordsSource := map[string]string{}
+	conflicts := map[string]RecordsConflict{}
+	for key, providerIds := range contributors {
+		if len(providerIds) == 1 {
+			tmpRecords[key] = m.cacheProvidersRecords[providerIds[0]][key]
+			tmpRecordsSource[key] = providerIds[0]
+			continue
+		}
+
+		conflict := RecordsConflict{Key: key, Policy: policy, Providers: providerIds}
+		switch policy {
+		case MergePolicyAppend:
+			for _, providerId := range providerIds {
+				tmpRecords[key] = appendUniqueRecords(tmpRecords[key], m.cacheProvidersRecords[providerId][key])
+			}
+			tmpRecordsSource[key] = providerIds[0]
+		case MergePolicyError:
+		default:
+			conflict.Winner = providerIds[0]
+			if policy == MergePolicyFirst {
+				conflict.Winner = m.firstProvider(key, providerIds)
+			}
+			for _, providerId := range providerIds {
+				if providerId != conflict.Winner {
+					conflict.Shadowed = append(conflict.Shadowed, providerId)
+				}
+			}
+			tmpRecords[key] = m.cacheProvidersRecords[conflict.Winner][key]
+			tmpRecordsSource[key] = conflict.Winner
+		}
+		conflicts[key] = conflict
+	}
+
+	m.logConflicts(conflicts)
+	m.statusMtx.Lock()
+	m.conflicts = conflicts
+	m.statusMtx.Unlock()
+	recordsNames := createRecordsNames(tmpRecords)
+	m.recordsMtx.Lock()
+	m.records = tmpRecords
+	m.recordsSource = tmpRecordsSource
+	m.recordsNames = recordsNames
+	m.recordsMtx.Unlock()
+	m.updateZones()
+}
+
+func (m *Manager) firstProvider(key string, providerIds []string) string {
+	if source := m.recordsSource[key]; slices.Contains(providerIds, source) {
+		return source
+	}
+	return providerIds[0]
+}
+
+func appendUniqueRecords(records []*types.Record, recordsToAdd []*types.Record) []*types.Record {
+	for _, record := range recordsToAdd {
+		if !containsRecord(records, record) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func containsRecord(records []*types.Record, record *types.Record) bool {
+	rr, err := types.ConvertRecordToRR(record)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(records, func(existing *types.Record) bool {
+		existingRR, errConvert := types.ConvertRecordToRR(existing)
+		return errConvert == nil && dns.IsDuplicate(rr, existingRR)
+	})
+}
+
+func (m *Manager) logConflicts(conflicts map[string]RecordsConflict) {
+	m.statusMtx.RLock()
+	previous := m.conflicts
+	m.statusMtx.RUnlock()
+	for key, conflict := range conflicts {
+		if previousConflict, ok := previous[key]; ok && previousConflict.Winner == conflict.Winner && slices.Equal(previousConflict.Providers, conflict.Providers) {
+			continue
+		}
+		providers := strings.Join(conflict.Providers, ", ")
+		switch conflict.Policy {
+		case MergePolicyAppend:
+			m.logger.Warn(fmt.Sprintf("records %s provided by multiple providers (%s), answers are appended", key, providers))
+		case MergePolicyError:
+			m.logger.Error(fmt.Sprintf("records %s provided by multiple providers (%s), records are not served", key, providers))
+		default:
+			m.logger.Warn(fmt.Sprintf("records %s of %s shadowed by %s", key, strings.Join(conflict.Shadowed, ", "), conflict.Winner))
+		}
+	}
+}
+
+func (m *Manager) GetConflicts() []RecordsConflict {
+	m.statusMtx.RLock()
+	defer m.statusMtx.RUnlock()
+	conflicts := make([]RecordsConflict, 0, len(m.conflicts))
+	for _, conflict := range m.conflicts {
+		conflicts = append(conflicts, conflict)
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Key < conflicts[j].Key
+	})
+	return conflicts
+}
@@ -0,0 +1,140 @@
+package dns
+
+import (
+	"bytes"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCreateProvidersPriority(t *testing.T) {
+	got := createProvidersPriority(map[string]config.Provider{"fs": {Type: "fs"}, "docker": {Type: "docker", Priority: 10}})
+	assert.Equal(t, map[string]int{"fs": 0, "docker": 10}, got)
+}
+
+func TestManager_mergeRecords(t *testing.T) {
+	fsRecord := &types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.1"}
+	dockerRecord := &types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.2"}
+	barRecord := &types.Record{Name: "bar.local", Type: "A", Value: "127.0.0.3"}
+	cacheProvidersRecords := map[string]types.Records{
+		"fs":     {"foo.local._A": {fsRecord}, "bar.local._A": {barRecord}},
+		"docker": {"foo.local._A": {dockerRecord}},
+	}
+	tests := []struct {
+		name          string
+		policy        string
+		recordsSource map[string]string
+		wantRecords   types.Records
+		wantSource    map[string]string
+		wantConflicts []RecordsConflict
+		wantLog       string
+	}{
+		{
+			name:          "SuccessAppend",
+			policy:        MergePolicyAppend,
+			wantRecords:   types.Records{"foo.local._A": {dockerRecord, fsRecord}, "bar.local._A": {barRecord}},
+			wantSource:    map[string]string{"foo.local._A": "docker", "bar.local._A": "fs"},
+			wantConflicts: []RecordsConflict{{Key: "foo.local._A", Policy: MergePolicyAppend, Providers: []string{"docker", "fs"}}},
+			wantLog:       "records foo.local._A provided by multiple providers (docker, fs), answers are appended",
+		},
+		{
+			name:          "SuccessDefaultAppend",
+			wantRecords:   types.Records{"foo.local._A": {dockerRecord, fsRecord}, "bar.local._A": {barRecord}},
+			wantSource:    map[string]string{"foo.local._A": "docker", "bar.local._A": "fs"},
+			wantConflicts: []RecordsConflict{{Key: "foo.local._A", Policy: MergePolicyAppend, Providers: []string{"docker", "fs"}}},
+			wantLog:       "answers are appended",
+		},
+		{
+			name:          "SuccessPriority",
+			policy:        MergePolicyPriority,
+			recordsSource: map[string]string{"foo.local._A": "fs"},
+			wantRecords:   types.Records{"foo.local._A": {dockerRecord}, "bar.local._A": {barRecord}},
+			wantSource:    map[string]string{"foo.local._A": "docker", "bar.local._A": "fs"},
+			wantConflicts: []RecordsConflict{{Key: "foo.local._A", Policy: MergePolicyPriority, Providers: []string{"docker", "fs"}, Winner: "docker", Shadowed: []string{"fs"}}},
+			wantLog:       "records foo.local._A of fs shadowed by docker",
+		},
+		{
+			name:          "SuccessFirstKeepPreviousSource",
+			policy:        MergePolicyFirst,
+			recordsSource: map[string]string{"foo.local._A": "fs"},
+			wantRecords:   types.Records{"foo.local._A": {fsRecord}, "bar.local._A": {barRecord}},
+			wantSource:    map[string]string{"foo.local._A": "fs", "bar.local._A": "fs"},
+			wantConflicts: []RecordsConflict{{Key: "foo.local._A", Policy: MergePolicyFirst, Providers: []string{"docker", "fs"}, Winner: "fs", Shadowed: []string{"docker"}}},
+			wantLog:       "records foo.local._A of docker shadowed by fs",
+		},
+		{
+			name:          "SuccessFirstWithoutPreviousSource",
+			policy:        MergePolicyFirst,
+			wantRecords:   types.Records{"foo.local._A": {dockerRecord}, "bar.local._A": {barRecord}},
+			wantSource:    map[string]string{"foo.local._A": "docker", "bar.local._A": "fs"},
+			wantConflicts: []RecordsConflict{{Key: "foo.local._A", Policy: MergePolicyFirst, Providers: []string{"docker", "fs"}, Winner: "docker", Shadowed: []string{"fs"}}},
+			wantLog:       "records foo.local._A of fs shadowed by docker",
+		},
+		{
+			name:          "SuccessFirstPreviousSourceGone",
+			policy:        MergePolicyFirst,
+			recordsSource: map[string]string{"foo.local._A": "consul"},
+			wantRecords:   types.Records{"foo.local._A": {dockerRecord}, "bar.local._A": {barRecord}},
+			wantSource:    map[string]string{"foo.local._A": "docker", "bar.local._A": "fs"},
+			wantConflicts: []RecordsConflict{{Key: "foo.local._A", Policy: MergePolicyFirst, Providers: []string{"docker", "fs"}, Winner: "docker", Shadowed: []string{"fs"}}},
+			wantLog:       "records foo.local._A of fs shadowed by docker",
+		},
+		{
+			name:          "SuccessError",
+			policy:        MergePolicyError,
+			wantRecords:   types.Records{"bar.local._A": {barRecord}},
+			wantSource:    map[string]string{"bar.local._A": "fs"},
+			wantConflicts: []RecordsConflict{{Key: "foo.local._A", Policy: MergePolicyError, Providers: []string{"docker", "fs"}}},
+			wantLog:       "level=ERROR msg=\"records foo.local._A provided by multiple providers (docker, fs), records are not served\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			ctx := context.TestContext(buffer)
+			m := &Manager{
+				logger:                ctx.Logger,
+				cacheProvidersRecords: cacheProvidersRecords,
+				mergePolicy:           tt.policy,
+				providersPriority:     map[string]int{"docker": 10},
+				recordsSource:         tt.recordsSource,
+			}
+			m.mergeRecords()
+			assert.Equal(t, tt.wantRecords, m.records)
+			assert.Equal(t, tt.wantSource, m.recordsSource)
+			assert.Equal(t, createRecordsNames(tt.wantRecords), m.recordsNames)
+			assert.Equal(t, tt.wantConflicts, m.GetConflicts())
+			assert.Contains(t, buffer.String(), tt.wantLog)
+
+			buffer.Reset()
+			m.mergeRecords()
+			assert.Empty(t, buffer.String())
+		})
+	}
+}
+
+func TestManager_mergeRecords_AppendDuplicates(t *testing.T) {
+	m := &Manager{
+		logger: context.TestContext(nil).Logger,
+		cacheProvidersRecords: map[string]types.Records{
+			"fs":     {"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}},
+			"docker": {"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1", TTL: 60}, {Name: "foo.local", Type: "A", Value: "127.0.0.2"}}},
+		},
+		mergePolicy: MergePolicyAppend,
+	}
+	m.mergeRecords()
+	assert.Equal(t, types.Records{"foo.local._A": {
+		{Name: "foo.local", Type: "A", Value: "127.0.0.1", TTL: 60},
+		{Name: "foo.local", Type: "A", Value: "127.0.0.2"},
+	}}, m.records)
+}
+
+func TestManager_sortedProviderIds(t *testing.T) {
+	m := &Manager{
+		cacheProvidersRecords: map[string]types.Records{"b": {}, "a": {}, "c": {}, "d": {}},
+		providersPriority:     map[string]int{"c": 5, "d": -1},
+	}
+	assert.Equal(t, []string{"c", "a", "b", "d"}, m.sortedProviderIds())
+}
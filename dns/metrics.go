@@ -0,0 +1,27 @@
+package dns
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/metrics"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"time"
+)
+
+func (m *Manager) SetMetrics(metrics *metrics.Metrics) {
+	m.metrics = metrics
+}
+
+func (m *Manager) observeQuery(message *dns.Msg, source string, latency time.Duration) {
+	if len(message.Question) == 0 || message.Opcode != dns.OpcodeQuery {
+		return
+	}
+	m.metrics.ObserveQuery(dns.TypeToString[message.Question[0].Qtype], dns.RcodeToString[message.Rcode], source, latency)
+}
+
+func countRecords(records types.Records) int {
+	count := 0
+	for _, keyRecords := range records {
+		count += len(keyRecords)
+	}
+	return count
+}
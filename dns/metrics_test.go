@@ -0,0 +1,104 @@
+package dns
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/metrics"
+	mockMiekgDns "github.com/alexandreh2ag/go-dns-discover/mocks/miekg"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func scrapeTestMetrics(appMetrics *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	appMetrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestManager_HandleDnsRequest_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	appMetrics := metrics.NewMetrics()
+	client := mockTypes.NewMockClientDNS(ctrl)
+	client.EXPECT().Exchange(gomock.Any(), gomock.Eq("1.1.1.1:53")).Times(1).Return(&dns.Msg{Answer: []dns.RR{newTestRR("example.com. 60 IN A 127.0.0.1")}}, time.Duration(1), nil)
+	m := &Manager{
+		logger:        context.TestContext(nil).Logger,
+		records:       types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}},
+		recordsSource: map[string]string{"foo.local._A": "fs"},
+		fallbackCfg:   config.FallbackConfig{Enable: true, Nameservers: []string{"1.1.1.1"}},
+		clientDNS:     client,
+	}
+	m.SetMetrics(appMetrics)
+
+	for _, qname := range []string{"foo.local.", "foo.local.", "example.com."} {
+		message := &dns.Msg{}
+		message.SetQuestion(qname, dns.TypeA)
+		responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+		responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+		responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).Return(nil)
+		m.HandleDnsRequest()(responseWriter, message)
+	}
+
+	notify := &dns.Msg{}
+	notify.SetNotify("local.")
+	responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+	responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+	responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).Return(nil)
+	m.HandleDnsRequest()(responseWriter, notify)
+
+	content := scrapeTestMetrics(appMetrics)
+	assert.Contains(t, content, `godnsd_dns_queries_total{qtype="A",rcode="NOERROR",source="fs"} 2`)
+	assert.Contains(t, content, `godnsd_dns_queries_total{qtype="A",rcode="NOERROR",source="fallback"} 1`)
+	assert.NotContains(t, content, `qtype="SOA"`)
+	assert.Contains(t, content, `godnsd_dns_query_duration_seconds_count{source="fs"} 2`)
+	assert.Contains(t, content, `godnsd_fallback_request_duration_seconds_count{result="success",upstream="1.1.1.1:53"} 1`)
+}
+
+func TestManager_listen_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.TestContext(nil)
+	provider := mockTypes.NewMockProvider(ctrl)
+	provider.EXPECT().GetId().AnyTimes().Return("provider")
+	appMetrics := metrics.NewMetrics()
+	m := &Manager{
+		logger:                ctx.Logger,
+		cacheProvidersRecords: map[string]types.Records{"provider": {}},
+		configurationChan:     make(chan types.Message, 40),
+		done:                  ctx.Done(),
+		metrics:               appMetrics,
+	}
+	go m.listen()
+	m.configurationChan <- types.Message{Provider: provider, Records: types.Records{
+		"foo.local._A":    {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}, {Name: "foo.local", Type: "A", Value: "127.0.0.2"}},
+		"foo.local._AAAA": {{Name: "foo.local", Type: "AAAA", Value: "::1"}},
+	}}
+	time.Sleep(100 * time.Millisecond)
+	ctx.Cancel()
+
+	content := scrapeTestMetrics(appMetrics)
+	assert.Contains(t, content, `godnsd_provider_records{provider="provider"} 3`)
+	assert.Contains(t, content, `godnsd_provider_updates_total{provider="provider"} 1`)
+	assert.Contains(t, content, `godnsd_provider_last_update_timestamp_seconds{provider="provider"}`)
+}
+
+func TestManager_signRRsets_Metrics(t *testing.T) {
+	appMetrics := metrics.NewMetrics()
+	m := &Manager{logger: context.TestContext(nil).Logger, dnssecZones: createTestDnssecZones(t), metrics: appMetrics}
+	rrs := []dns.RR{newTestRR("foo.local. 60 IN A 127.0.0.1")}
+	assert.Len(t, m.signRRsets(rrs), 1)
+	assert.Len(t, m.signRRsets(rrs), 1)
+
+	content := scrapeTestMetrics(appMetrics)
+	assert.Contains(t, content, `godnsd_dnssec_signature_cache_total{result="hit"} 1`)
+	assert.Contains(t, content, `godnsd_dnssec_signature_cache_total{result="miss"} 1`)
+}
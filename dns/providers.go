@@ -0,0 +1,103 @@
+package dns
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"time"
+)
+
+const (
+	ProviderStatePending    = "pending"
+	ProviderStateRunning    = "running"
+	ProviderStateRestarting = "restarting"
+	ProviderStateStopped    = "stopped"
+	ProviderStateFailed     = "failed"
+)
+
+type ProviderStatus struct {
+	Type       string     `json:"type"`
+	State      string     `json:"state"`
+	Ready      bool       `json:"ready"`
+	Records    int        `json:"records"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Stale      bool       `json:"stale,omitempty"`
+}
+
+func (m *Manager) setProviderState(providerId string, state string, err error) {
+	m.statusMtx.Lock()
+	defer m.statusMtx.Unlock()
+	if m.providersStatus == nil {
+		m.providersStatus = map[string]ProviderStatus{}
+	}
+	status := m.providersStatus[providerId]
+	status.State = state
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+	}
+	m.providersStatus[providerId] = status
+}
+
+func (m *Manager) setProviderRecords(providerId string, records types.Records) {
+	m.statusMtx.Lock()
+	defer m.statusMtx.Unlock()
+	if m.providersStatus == nil {
+		m.providersStatus = map[string]ProviderStatus{}
+	}
+	m.cacheProvidersRecords[providerId] = records
+	now := time.Now()
+	status := m.providersStatus[providerId]
+	status.Ready = true
+	status.Records = countRecords(records)
+	status.LastUpdate = &now
+	status.Stale = false
+	m.providersStatus[providerId] = status
+}
+
+func (m *Manager) expireProviderRecords(providerId string, maxStaleness time.Duration) bool {
+	m.statusMtx.Lock()
+	defer m.statusMtx.Unlock()
+	status := m.providersStatus[providerId]
+	if status.Stale || status.LastUpdate == nil || time.Since(*status.LastUpdate) < maxStaleness {
+		return false
+	}
+	m.cacheProvidersRecords[providerId] = types.Records{}
+	status.Records = 0
+	status.Stale = true
+	m.providersStatus[providerId] = status
+	return true
+}
+
+func (m *Manager) providerStatus(providerId string) ProviderStatus {
+	status := m.providersStatus[providerId]
+	if provider := m.providers[providerId]; provider != nil {
+		status.Type = provider.GetType()
+	}
+	if status.State == "" {
+		status.State = ProviderStatePending
+	}
+	return status
+}
+
+func (m *Manager) GetProvidersStatus() map[string]ProviderStatus {
+	m.statusMtx.RLock()
+	defer m.statusMtx.RUnlock()
+	providersStatus := map[string]ProviderStatus{}
+	for providerId := range m.providers {
+		providersStatus[providerId] = m.providerStatus(providerId)
+	}
+	return providersStatus
+}
+
+func (m *Manager) GetProviderRecords(providerId string) (types.Records, bool) {
+	m.statusMtx.RLock()
+	defer m.statusMtx.RUnlock()
+	if _, ok := m.providers[providerId]; !ok {
+		return nil, false
+	}
+	records, ok := m.cacheProvidersRecords[providerId]
+	if !ok {
+		records = types.Records{}
+	}
+	return records, true
+}
@@ -0,0 +1,66 @@
+package dns
+
+import (
+	"errors"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+func TestManager_GetProvidersStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	provider := mockTypes.NewMockProvider(ctrl)
+	provider.EXPECT().GetType().AnyTimes().Return("fs")
+	provider2 := mockTypes.NewMockProvider(ctrl)
+	provider2.EXPECT().GetType().AnyTimes().Return("redis")
+	provider3 := mockTypes.NewMockProvider(ctrl)
+	provider3.EXPECT().GetType().AnyTimes().Return("consul")
+	m := &Manager{
+		providers:             types.Providers{"provider": provider, "provider2": provider2, "provider3": provider3},
+		cacheProvidersRecords: map[string]types.Records{},
+	}
+	m.setProviderState("provider", ProviderStateRunning, nil)
+	m.setProviderRecords("provider", types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}, {Name: "foo.local", Type: "A", Value: "127.0.0.2"}}})
+	m.setProviderState("provider2", ProviderStateFailed, errors.New("fail"))
+
+	got := m.GetProvidersStatus()
+	assert.Len(t, got, 3)
+	assert.Equal(t, "fs", got["provider"].Type)
+	assert.Equal(t, ProviderStateRunning, got["provider"].State)
+	assert.True(t, got["provider"].Ready)
+	assert.Equal(t, 2, got["provider"].Records)
+	assert.NotNil(t, got["provider"].LastUpdate)
+	assert.Equal(t, ProviderStatus{Type: "redis", State: ProviderStateFailed, Error: "fail"}, got["provider2"])
+	assert.Equal(t, ProviderStatus{Type: "consul", State: ProviderStatePending}, got["provider3"])
+
+	m.setProviderState("provider2", ProviderStateRunning, nil)
+	assert.Equal(t, ProviderStatus{Type: "redis", State: ProviderStateRunning}, m.GetProvidersStatus()["provider2"])
+}
+
+func TestManager_GetProviderRecords(t *testing.T) {
+	records := types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}
+	m := &Manager{
+		providers:             types.Providers{"provider": nil, "provider2": nil},
+		cacheProvidersRecords: map[string]types.Records{"provider": records},
+	}
+	tests := []struct {
+		name       string
+		providerId string
+		want       types.Records
+		wantOk     bool
+	}{
+		{name: "SuccessRecords", providerId: "provider", want: records, wantOk: true},
+		{name: "SuccessNotStarted", providerId: "provider2", want: types.Records{}, wantOk: true},
+		{name: "FailUnknownProvider", providerId: "wrong"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.GetProviderRecords(tt.providerId)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
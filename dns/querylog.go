@@ -0,0 +1,44 @@
+package dns
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/querylog"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"net"
+	"time"
+)
+
+func (m *Manager) SetQueryLogger(queryLogger *querylog.Logger) {
+	m.queryLogger = queryLogger
+}
+
+func (m *Manager) findRecordsSource(question dns.Question, records []*types.Record) string {
+	recordsSource := m.getRecordsSource()
+	for _, record := range records {
+		if source, ok := recordsSource[types.FormatRecordKey(record.Name, record.Type)]; ok {
+			return source
+		}
+	}
+	if wildcard := m.wildcardSource(question.Name); wildcard != "" {
+		return recordsSource[types.FormatRecordKey(wildcard, records[0].Type)]
+	}
+	return ""
+}
+
+func (m *Manager) logQuery(message *dns.Msg, remoteAddr net.Addr, source string, latency time.Duration) {
+	if m.queryLogger == nil || !m.queryLogger.Enabled() {
+		return
+	}
+	entry := querylog.Entry{Protocol: "udp", Rcode: dns.RcodeToString[message.Rcode], Source: source, Latency: latency}
+	if ip := remoteIP(remoteAddr); ip != nil {
+		entry.ClientIP = ip.String()
+	}
+	if _, ok := remoteAddr.(*net.TCPAddr); ok {
+		entry.Protocol = "tcp"
+	}
+	if len(message.Question) > 0 {
+		entry.Qname = message.Question[0].Name
+		entry.Qtype = dns.TypeToString[message.Question[0].Qtype]
+	}
+	m.queryLogger.Log(entry)
+}
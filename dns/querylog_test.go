@@ -0,0 +1,166 @@
+package dns
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	mockMiekgDns "github.com/alexandreh2ag/go-dns-discover/mocks/miekg"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/querylog"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManager_findRecordsSource(t *testing.T) {
+	records := types.Records{
+		"foo.local._A":    {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
+		"*.wild.local._A": {{Name: "*.wild.local", Type: "A", Value: "127.0.0.2"}},
+	}
+	m := &Manager{
+		records:       records,
+		recordsNames:  createRecordsNames(records),
+		recordsSource: map[string]string{"foo.local._A": "fs", "*.wild.local._A": "docker"},
+	}
+	question := dns.Question{Name: "foo.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	assert.Equal(t, "fs", m.findRecordsSource(question, m.findRecords(question)))
+	question = dns.Question{Name: "bar.wild.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	assert.Equal(t, "docker", m.findRecordsSource(question, m.findRecords(question)))
+	question = dns.Question{Name: "bar.local.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	assert.Equal(t, "", m.findRecordsSource(question, []*types.Record{{Name: "other.local", Type: "A", Value: "127.0.0.1"}}))
+}
+
+func TestManager_HandleDnsRequest_QueryLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name       string
+		qname      string
+		remoteAddr net.Addr
+		mockFn     func(clientDns *mockTypes.MockClientDNS)
+		want       string
+	}{
+		{
+			name:       "SuccessProvider",
+			qname:      "foo.local.",
+			remoteAddr: &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000},
+			want:       "msg=query client_ip=10.0.0.1 protocol=udp qname=foo.local. qtype=A rcode=NOERROR source=fs latency_ms=",
+		},
+		{
+			name:       "SuccessFallback",
+			qname:      "example.com.",
+			remoteAddr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000},
+			mockFn: func(clientDns *mockTypes.MockClientDNS) {
+				clientDns.EXPECT().Exchange(gomock.Any(), gomock.Eq("1.1.1.1:53")).Times(1).Return(&dns.Msg{Answer: []dns.RR{newTestRR("example.com. 60 IN A 127.0.0.1")}}, time.Duration(1), nil)
+			},
+			want: "msg=query client_ip=10.0.0.2 protocol=tcp qname=example.com. qtype=A rcode=NOERROR source=fallback latency_ms=",
+		},
+		{
+			name:       "SuccessNotFound",
+			qname:      "example.com.",
+			remoteAddr: &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000},
+			mockFn: func(clientDns *mockTypes.MockClientDNS) {
+				clientDns.EXPECT().Exchange(gomock.Any(), gomock.Eq("1.1.1.1:53")).Times(1).Return(nil, time.Duration(1), os.ErrDeadlineExceeded)
+			},
+			want: "msg=query client_ip=10.0.0.1 protocol=udp qname=example.com. qtype=A rcode=NOERROR source=\"\" latency_ms=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "query.log")
+			queryLogger, err := querylog.NewLogger(config.QueryLogConfig{Enable: true, Output: "file", Format: "text", Path: path, SampleRate: 1})
+			assert.NoError(t, err)
+			client := mockTypes.NewMockClientDNS(ctrl)
+			if tt.mockFn != nil {
+				tt.mockFn(client)
+			}
+			m := &Manager{
+				logger:        context.TestContext(nil).Logger,
+				records:       types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}},
+				recordsSource: map[string]string{"foo.local._A": "fs"},
+				fallbackCfg:   config.FallbackConfig{Enable: true, Nameservers: []string{"1.1.1.1"}},
+				clientDNS:     client,
+				queryLogger:   queryLogger,
+			}
+			message := &dns.Msg{}
+			message.SetQuestion(tt.qname, dns.TypeA)
+			responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+			responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(tt.remoteAddr)
+			responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).Return(nil)
+
+			m.HandleDnsRequest()(responseWriter, message)
+			assert.NoError(t, queryLogger.Close())
+			content, _ := os.ReadFile(path)
+			assert.Contains(t, string(content), tt.want)
+		})
+	}
+}
+
+func TestManager_HandleDnsRequest_QueryLogEarlyReply(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name    string
+		message func() *dns.Msg
+		want    string
+	}{
+		{
+			name: "SuccessTsigError",
+			message: func() *dns.Msg {
+				message := &dns.Msg{}
+				message.SetQuestion("foo.local.", dns.TypeA)
+				message.SetTsig("unknown.", dns.HmacSHA256, 300, time.Now().Unix())
+				return message
+			},
+			want: "msg=query client_ip=10.0.0.1 protocol=tcp qname=foo.local. qtype=A rcode=NOTAUTH source=\"\" latency_ms=",
+		},
+		{
+			name: "SuccessTransferRefused",
+			message: func() *dns.Msg {
+				message := &dns.Msg{}
+				message.SetAxfr("local.")
+				return message
+			},
+			want: "msg=query client_ip=10.0.0.1 protocol=tcp qname=local. qtype=AXFR rcode=REFUSED source=\"\" latency_ms=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "query.log")
+			queryLogger, err := querylog.NewLogger(config.QueryLogConfig{Enable: true, Output: "file", Format: "text", Path: path, SampleRate: 1})
+			assert.NoError(t, err)
+			m := &Manager{
+				logger:      context.TestContext(nil).Logger,
+				records:     types.Records{},
+				queryLogger: queryLogger,
+			}
+			responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+			responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+			responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).Return(nil)
+
+			m.HandleDnsRequest()(responseWriter, tt.message())
+			assert.NoError(t, queryLogger.Close())
+			content, _ := os.ReadFile(path)
+			assert.Contains(t, string(content), tt.want)
+		})
+	}
+}
+
+func TestManager_logQuery_Disabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log")
+	queryLogger, err := querylog.NewLogger(config.QueryLogConfig{Output: "file", Format: "json", Path: path, SampleRate: 1})
+	assert.NoError(t, err)
+	m := &Manager{queryLogger: queryLogger}
+	m.logQuery(&dns.Msg{}, &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}, "", time.Millisecond)
+	(&Manager{}).logQuery(&dns.Msg{}, &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}, "", time.Millisecond)
+	assert.NoError(t, queryLogger.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
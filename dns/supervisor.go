@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRestartInitialInterval = 1
+	defaultRestartMaxInterval     = 300
+	defaultRestartMultiplier      = 2
+	defaultRestartJitter          = 0.2
+)
+
+type restartPolicy struct {
+	disable         bool
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	multiplier      float64
+	jitter          float64
+	maxRetries      int
+	maxStaleness    time.Duration
+}
+
+func createRestartPolicies(providers map[string]config.Provider) map[string]restartPolicy {
+	policies := map[string]restartPolicy{}
+	for providerId, providerCfg := range providers {
+		policies[providerId] = createRestartPolicy(providerCfg.Restart)
+	}
+	return policies
+}
+
+func createRestartPolicy(cfg config.RestartConfig) restartPolicy {
+	policy := restartPolicy{
+		disable:         cfg.Disable,
+		initialInterval: time.Duration(cfg.InitialInterval) * time.Second,
+		maxInterval:     time.Duration(cfg.MaxInterval) * time.Second,
+		multiplier:      cfg.Multiplier,
+		jitter:          defaultRestartJitter,
+		maxRetries:      cfg.MaxRetries,
+		maxStaleness:    time.Duration(cfg.MaxStaleness) * time.Second,
+	}
+	if policy.initialInterval == 0 {
+		policy.initialInterval = defaultRestartInitialInterval * time.Second
+	}
+	if policy.maxInterval == 0 {
+		policy.maxInterval = defaultRestartMaxInterval * time.Second
+	}
+	if policy.multiplier == 0 {
+		policy.multiplier = defaultRestartMultiplier
+	}
+	if cfg.Jitter != nil {
+		policy.jitter = *cfg.Jitter
+	}
+	return policy
+}
+
+func (p restartPolicy) delay(attempt int) time.Duration {
+	delay := float64(p.initialInterval) * math.Pow(p.multiplier, float64(attempt))
+	delay = math.Min(delay, float64(p.maxInterval))
+	delay = delay * (1 + p.jitter*(2*rand.Float64()-1))
+	return time.Duration(delay)
+}
+
+func (m *Manager) restartPolicy(providerId string) restartPolicy {
+	if policy, ok := m.restartPolicies[providerId]; ok {
+		return policy
+	}
+	return createRestartPolicy(config.RestartConfig{})
+}
+
+func (m *Manager) superviseProvider(prd types.Provider) {
+	policy := m.restartPolicy(prd.GetId())
+	stopWatch := m.watchStaleness(prd.GetId(), policy.maxStaleness)
+	attempt := 0
+	for {
+		m.setProviderState(prd.GetId(), ProviderStateRunning, nil)
+		startedAt := time.Now()
+		err := prd.Provide(m.configurationChan)
+		if err == nil {
+			stopWatch()
+			m.setProviderState(prd.GetId(), ProviderStateStopped, nil)
+			return
+		}
+		m.logger.Error(fmt.Sprintf("error when provide %s: %v", prd.GetId(), err))
+		if time.Since(startedAt) > policy.maxInterval {
+			attempt = 0
+		}
+		if policy.disable || (policy.maxRetries > 0 && attempt >= policy.maxRetries) {
+			m.setProviderState(prd.GetId(), ProviderStateFailed, err)
+			return
+		}
+
+		delay := policy.delay(attempt)
+		attempt++
+		m.setProviderState(prd.GetId(), ProviderStateRestarting, err)
+		m.logger.Info(fmt.Sprintf("restarting provider %s in %s (attempt %d)", prd.GetId(), delay, attempt))
+		if !m.waitRestart(delay) {
+			return
+		}
+	}
+}
+
+func (m *Manager) waitRestart(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-m.stop:
+		return false
+	}
+}
+
+func (m *Manager) watchStaleness(providerId string, maxStaleness time.Duration) func() {
+	if maxStaleness <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		timer := time.NewTimer(maxStaleness)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+			case <-done:
+				return
+			case <-m.stop:
+				return
+			}
+
+			wait := maxStaleness
+			if lastUpdate := m.providerLastUpdate(providerId); lastUpdate != nil {
+				if wait = time.Until(lastUpdate.Add(maxStaleness)); wait <= 0 {
+					m.logger.Warn(fmt.Sprintf("records of provider %s are stale, stop serving them", providerId))
+					select {
+					case m.staleChan <- providerId:
+					case <-done:
+						return
+					case <-m.stop:
+						return
+					}
+					wait = maxStaleness
+				}
+			}
+			timer.Reset(wait)
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (m *Manager) providerLastUpdate(providerId string) *time.Time {
+	m.statusMtx.RLock()
+	defer m.statusMtx.RUnlock()
+	if m.providersStatus[providerId].Stale {
+		return nil
+	}
+	return m.providersStatus[providerId].LastUpdate
+}
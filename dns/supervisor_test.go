@@ -0,0 +1,253 @@
+package dns
+
+import (
+	"errors"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/metrics"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestCreateRestartPolicies(t *testing.T) {
+	jitter, noJitter := 0.5, 0.0
+	got := createRestartPolicies(map[string]config.Provider{
+		"default":  {Type: "fs"},
+		"custom":   {Type: "docker", Restart: config.RestartConfig{Disable: true, InitialInterval: 5, MaxInterval: 60, Multiplier: 1.5, Jitter: &jitter, MaxRetries: 3, MaxStaleness: 600}},
+		"noJitter": {Type: "fs", Restart: config.RestartConfig{Jitter: &noJitter}},
+	})
+	assert.Equal(t, map[string]restartPolicy{
+		"default":  {initialInterval: time.Second, maxInterval: 300 * time.Second, multiplier: 2, jitter: 0.2},
+		"custom":   {disable: true, initialInterval: 5 * time.Second, maxInterval: 60 * time.Second, multiplier: 1.5, jitter: 0.5, maxRetries: 3, maxStaleness: 600 * time.Second},
+		"noJitter": {initialInterval: time.Second, maxInterval: 300 * time.Second, multiplier: 2, jitter: 0},
+	}, got)
+	assert.Equal(t, got["default"], (&Manager{}).restartPolicy("api"))
+}
+
+func TestRestartPolicy_delay(t *testing.T) {
+	policy := restartPolicy{initialInterval: time.Second, maxInterval: 10 * time.Second, multiplier: 2, jitter: 0.1}
+	tests := []struct {
+		name    string
+		attempt int
+		want    time.Duration
+	}{
+		{name: "SuccessFirstAttempt", attempt: 0, want: time.Second},
+		{name: "SuccessExponential", attempt: 2, want: 4 * time.Second},
+		{name: "SuccessMaxInterval", attempt: 10, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				got := policy.delay(tt.attempt)
+				assert.GreaterOrEqual(t, got, tt.want*9/10)
+				assert.LessOrEqual(t, got, tt.want*11/10)
+			}
+		})
+	}
+}
+
+func TestManager_superviseProvider(t *testing.T) {
+	records := types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}
+	policy := restartPolicy{initialInterval: 10 * time.Millisecond, maxInterval: time.Second, multiplier: 2}
+	tests := []struct {
+		name        string
+		policy      restartPolicy
+		mockFn      func(provider *mockTypes.MockProvider)
+		wantState   string
+		wantError   string
+		wantRecords types.Records
+	}{
+		{
+			name:   "SuccessRestartAfterFailure",
+			policy: policy,
+			mockFn: func(provider *mockTypes.MockProvider) {
+				gomock.InOrder(
+					provider.EXPECT().Provide(gomock.Any()).Times(1).DoAndReturn(func(configurationChan chan<- types.Message) error {
+						configurationChan <- types.Message{Provider: provider, Records: records}
+						return errors.New("fail")
+					}),
+					provider.EXPECT().Provide(gomock.Any()).Times(1).Return(nil),
+				)
+			},
+			wantState:   ProviderStateStopped,
+			wantRecords: records,
+		},
+		{
+			name:   "SuccessExpireRecordsWhileRunning",
+			policy: restartPolicy{initialInterval: 10 * time.Millisecond, maxInterval: time.Second, multiplier: 2, maxStaleness: 10 * time.Millisecond},
+			mockFn: func(provider *mockTypes.MockProvider) {
+				provider.EXPECT().Provide(gomock.Any()).Times(1).DoAndReturn(func(configurationChan chan<- types.Message) error {
+					configurationChan <- types.Message{Provider: provider, Records: records}
+					time.Sleep(50 * time.Millisecond)
+					return nil
+				})
+			},
+			wantState:   ProviderStateStopped,
+			wantRecords: types.Records{},
+		},
+		{
+			name:   "FailMaxRetries",
+			policy: restartPolicy{initialInterval: 10 * time.Millisecond, maxInterval: time.Second, multiplier: 2, maxRetries: 2},
+			mockFn: func(provider *mockTypes.MockProvider) {
+				gomock.InOrder(
+					provider.EXPECT().Provide(gomock.Any()).Times(1).DoAndReturn(func(configurationChan chan<- types.Message) error {
+						configurationChan <- types.Message{Provider: provider, Records: records}
+						return errors.New("fail")
+					}),
+					provider.EXPECT().Provide(gomock.Any()).Times(2).Return(errors.New("fail")),
+				)
+			},
+			wantState:   ProviderStateFailed,
+			wantError:   "fail",
+			wantRecords: records,
+		},
+		{
+			name:   "FailDisabled",
+			policy: restartPolicy{disable: true},
+			mockFn: func(provider *mockTypes.MockProvider) {
+				provider.EXPECT().Provide(gomock.Any()).Times(1).Return(errors.New("fail"))
+			},
+			wantState: ProviderStateFailed,
+			wantError: "fail",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TestContext(nil)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			provider := mockTypes.NewMockProvider(ctrl)
+			provider.EXPECT().GetId().AnyTimes().Return("provider")
+			tt.mockFn(provider)
+			m := &Manager{
+				logger:                ctx.Logger,
+				providers:             types.Providers{"provider": provider},
+				cacheProvidersRecords: map[string]types.Records{"provider": {}},
+				restartPolicies:       map[string]restartPolicy{"provider": tt.policy},
+				configurationChan:     make(chan types.Message, 40),
+				staleChan:             make(chan string),
+				stop:                  make(chan struct{}),
+				done:                  ctx.Done(),
+			}
+			go m.listen()
+			m.superviseProvider(provider)
+			time.Sleep(50 * time.Millisecond)
+			m.statusMtx.RLock()
+			assert.Equal(t, tt.wantState, m.providersStatus["provider"].State)
+			assert.Equal(t, tt.wantError, m.providersStatus["provider"].Error)
+			m.statusMtx.RUnlock()
+			assert.Equal(t, tt.wantRecords, m.GetRecords())
+			ctx.Cancel()
+		})
+	}
+}
+
+func TestManager_waitRestart(t *testing.T) {
+	m := &Manager{stop: make(chan struct{})}
+	assert.True(t, m.waitRestart(10*time.Millisecond))
+	close(m.stop)
+	assert.False(t, m.waitRestart(time.Hour))
+}
+
+func TestManager_watchStaleness(t *testing.T) {
+	records := types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}
+	tests := []struct {
+		name         string
+		maxStaleness time.Duration
+		update       bool
+		wantRecords  types.Records
+		wantStale    bool
+		wantMetric   string
+	}{
+		{
+			name:         "SuccessStaleRecords",
+			maxStaleness: 10 * time.Millisecond,
+			wantRecords:  types.Records{},
+			wantStale:    true,
+			wantMetric:   `godnsd_provider_records{provider="provider"} 0`,
+		},
+		{
+			name:         "SuccessKeepUpdatedRecords",
+			maxStaleness: 40 * time.Millisecond,
+			update:       true,
+			wantRecords:  records,
+			wantMetric:   `godnsd_provider_records{provider="provider"} 1`,
+		},
+		{
+			name:        "SuccessKeepRecords",
+			wantRecords: records,
+			wantMetric:  `godnsd_provider_records{provider="provider"} 1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TestContext(nil)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			provider := mockTypes.NewMockProvider(ctrl)
+			provider.EXPECT().GetId().AnyTimes().Return("provider")
+			appMetrics := metrics.NewMetrics()
+			m := &Manager{
+				logger:                ctx.Logger,
+				providers:             types.Providers{"provider": provider},
+				cacheProvidersRecords: map[string]types.Records{"provider": {}},
+				restartPolicies:       map[string]restartPolicy{"provider": {maxStaleness: tt.maxStaleness}},
+				metrics:               appMetrics,
+				configurationChan:     make(chan types.Message, 40),
+				staleChan:             make(chan string),
+				stop:                  make(chan struct{}),
+				done:                  ctx.Done(),
+			}
+			go m.listen()
+			m.configurationChan <- types.Message{Provider: provider, Records: records}
+
+			stopWatch := m.watchStaleness("provider", tt.maxStaleness)
+			defer stopWatch()
+			if tt.update {
+				time.Sleep(25 * time.Millisecond)
+				m.configurationChan <- types.Message{Provider: provider, Records: records}
+				time.Sleep(25 * time.Millisecond)
+			} else {
+				time.Sleep(50 * time.Millisecond)
+			}
+			assert.Equal(t, tt.wantRecords, m.GetRecords())
+			m.statusMtx.RLock()
+			assert.Equal(t, tt.wantStale, m.providersStatus["provider"].Stale)
+			m.statusMtx.RUnlock()
+			assert.Contains(t, scrapeTestMetrics(appMetrics), tt.wantMetric)
+			ctx.Cancel()
+		})
+	}
+}
+
+func TestManager_expireProviderRecords(t *testing.T) {
+	records := types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}
+	old, recent := time.Now().Add(-time.Hour), time.Now()
+	tests := []struct {
+		name        string
+		status      ProviderStatus
+		wantExpired bool
+		wantStale   bool
+		wantRecords types.Records
+	}{
+		{name: "SuccessExpire", status: ProviderStatus{Records: 1, LastUpdate: &old}, wantExpired: true, wantStale: true, wantRecords: types.Records{}},
+		{name: "SuccessSkipRecentUpdate", status: ProviderStatus{Records: 1, LastUpdate: &recent}, wantRecords: records},
+		{name: "SuccessSkipAlreadyStale", status: ProviderStatus{LastUpdate: &old, Stale: true}, wantStale: true, wantRecords: records},
+		{name: "SuccessSkipNeverUpdated", wantRecords: records},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{
+				cacheProvidersRecords: map[string]types.Records{"provider": records},
+				providersStatus:       map[string]ProviderStatus{"provider": tt.status},
+			}
+			assert.Equal(t, tt.wantExpired, m.expireProviderRecords("provider", time.Minute))
+			assert.Equal(t, tt.wantRecords, m.cacheProvidersRecords["provider"])
+			assert.Equal(t, tt.wantStale, m.providersStatus["provider"].Stale)
+		})
+	}
+}
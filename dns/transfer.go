@@ -0,0 +1,197 @@
+package dns
+
+import (
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+const transferChunkSize = 100
+
+type zoneTransfer struct {
+	cfg         config.TransferZoneConfig
+	serial      uint32
+	fingerprint string
+	initialized bool
+}
+
+func createZoneTransfers(cfg config.TransferConfig) map[string]*zoneTransfer {
+	zones := map[string]*zoneTransfer{}
+	for _, zoneCfg := range cfg.Zones {
+		zones[strings.ToLower(dns.Fqdn(zoneCfg.Name))] = &zoneTransfer{cfg: zoneCfg, serial: uint32(time.Now().Unix())}
+	}
+	return zones
+}
+
+func isInZone(name string, zone string) bool {
+	name = strings.ToLower(dns.Fqdn(name))
+	return name == zone || strings.HasSuffix(name, "."+zone) || zone == "."
+}
+
+func (m *Manager) updateZones() {
+	m.zonesMtx.Lock()
+	defer m.zonesMtx.Unlock()
+	for zoneName, zone := range m.zones {
+		rrs := m.zoneRecords(zoneName)
+		lines := make([]string, 0, len(rrs))
+		for _, rr := range rrs {
+			lines = append(lines, rr.String())
+		}
+		slices.Sort(lines)
+		fingerprint := strings.Join(lines, "\n")
+		if fingerprint == zone.fingerprint {
+			continue
+		}
+		if zone.initialized {
+			zone.serial++
+		}
+		zone.fingerprint, zone.initialized = fingerprint, true
+		m.logger.Info(fmt.Sprintf("zone %s updated with serial %d", zoneName, zone.serial))
+		go m.sendNotify(zoneName, zone.cfg.Notify, zone.cfg.NotifyTsigKey)
+	}
+}
+
+func (m *Manager) zoneRecords(zoneName string) []dns.RR {
+	rrs := []dns.RR{}
+	for _, records := range m.GetRecords() {
+		for _, record := range records {
+			if !isInZone(record.Name, zoneName) {
+				continue
+			}
+			rr, err := types.ConvertRecordToRR(record)
+			if err != nil {
+				m.logger.Error(fmt.Sprintf("invalid record %s %s %s in zone %s: %v", record.Name, record.Type, record.Value, zoneName, err))
+				continue
+			}
+			if _, ok := rr.(*dns.SOA); ok && strings.EqualFold(rr.Header().Name, zoneName) {
+				continue
+			}
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+func (m *Manager) zoneSOA(zoneName string) dns.RR {
+	zoneName = strings.ToLower(dns.Fqdn(zoneName))
+	m.zonesMtx.RLock()
+	zone, ok := m.zones[zoneName]
+	var serial uint32
+	if ok {
+		serial = zone.serial
+	}
+	m.zonesMtx.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if rr := m.findSOARecord(zoneName); rr != nil {
+		return rr
+	}
+	return synthesizeSOA(zoneName, serial)
+}
+
+func (m *Manager) findSOARecord(zoneName string) dns.RR {
+	for _, record := range m.GetRecords()[types.FormatRecordKey(zoneName, "SOA")] {
+		if rr, err := types.ConvertRecordToRR(record); err == nil {
+			return rr
+		}
+	}
+	return nil
+}
+
+func synthesizeSOA(zoneName string, serial uint32) dns.RR {
+	rr, _ := dns.NewRR(fmt.Sprintf("%s 300 IN SOA ns.%s hostmaster.%s %d 3600 600 86400 300", zoneName, zoneName, zoneName, serial))
+	return rr
+}
+
+func (m *Manager) handleTransfer(w dns.ResponseWriter, r *dns.Msg, message *dns.Msg, keyName string) bool {
+	zoneName := strings.ToLower(dns.Fqdn(r.Question[0].Name))
+	m.zonesMtx.RLock()
+	zone, ok := m.zones[zoneName]
+	m.zonesMtx.RUnlock()
+
+	if !ok || !isTransferAllowed(zone.cfg, w.RemoteAddr(), keyName) {
+		m.logger.Warn(fmt.Sprintf("zone transfer of %s refused for %s", zoneName, w.RemoteAddr().String()))
+		message.Rcode = dns.RcodeRefused
+		if err := w.WriteMsg(message); err != nil {
+			m.logger.Error(fmt.Sprintf("error %v", err))
+		}
+		return false
+	}
+
+	soa := m.zoneSOA(zoneName)
+	rrs := append([]dns.RR{soa}, m.zoneRecords(zoneName)...)
+	rrs = append(rrs, soa)
+	ch := make(chan *dns.Envelope, len(rrs)/transferChunkSize+1)
+	for start := 0; start < len(rrs); start += transferChunkSize {
+		ch <- &dns.Envelope{RR: rrs[start:min(start+transferChunkSize, len(rrs))]}
+	}
+	close(ch)
+
+	m.logger.Info(fmt.Sprintf("zone transfer of %s to %s", zoneName, w.RemoteAddr().String()))
+	transfer := &dns.Transfer{}
+	if err := transfer.Out(w, r, ch); err != nil {
+		m.logger.Error(fmt.Sprintf("error when transfer zone %s: %v", zoneName, err))
+		message.Rcode = dns.RcodeServerFailure
+	}
+	return true
+}
+
+func isTransferAllowed(cfg config.TransferZoneConfig, addr net.Addr, keyName string) bool {
+	remoteAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	if keyName != "" {
+		return slices.ContainsFunc(cfg.TsigKeys, func(key string) bool {
+			return strings.EqualFold(dns.Fqdn(key), keyName)
+		})
+	}
+
+	for _, allow := range cfg.Allow {
+		if _, network, err := net.ParseCIDR(allow); err == nil {
+			if network.Contains(remoteAddr.IP) {
+				return true
+			}
+		} else if net.ParseIP(allow).Equal(remoteAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) sendNotify(zoneName string, targets []string, keyName string) {
+	for _, target := range targets {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			target = net.JoinHostPort(target, "53")
+		}
+		message := new(dns.Msg)
+		message.SetNotify(zoneName)
+		if keyName != "" {
+			keyName = strings.ToLower(dns.Fqdn(keyName))
+			key, ok := m.tsigKeys[keyName]
+			if !ok {
+				m.logger.Error(fmt.Sprintf("unknown tsig key %s to notify %s for zone %s", keyName, target, zoneName))
+				continue
+			}
+			message.SetTsig(keyName, key.Algorithm, 300, time.Now().Unix())
+		}
+		response, _, err := m.clientDNS.Exchange(message, target)
+		if err != nil {
+			m.logger.Error(fmt.Sprintf("error when notify %s for zone %s: %v", target, zoneName, err))
+			continue
+		}
+		if response.Rcode != dns.RcodeSuccess {
+			m.logger.Error(fmt.Sprintf("notify %s for zone %s failed with rcode %s", target, zoneName, dns.RcodeToString[response.Rcode]))
+			continue
+		}
+		m.logger.Debug(fmt.Sprintf("notify %s for zone %s", target, zoneName))
+	}
+}
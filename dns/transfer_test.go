@@ -0,0 +1,276 @@
+package dns
+
+import (
+	"bytes"
+	"errors"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	mockMiekgDns "github.com/alexandreh2ag/go-dns-discover/mocks/miekg"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_createZoneTransfers(t *testing.T) {
+	cfg := config.TransferConfig{Zones: []config.TransferZoneConfig{{Name: "Example.com"}, {Name: "local."}}}
+	got := createZoneTransfers(cfg)
+	assert.Len(t, got, 2)
+	assert.Equal(t, cfg.Zones[0], got["example.com."].cfg)
+	assert.Equal(t, cfg.Zones[1], got["local."].cfg)
+	assert.NotZero(t, got["local."].serial)
+}
+
+func Test_isInZone(t *testing.T) {
+	assert.True(t, isInZone("example.com", "example.com."))
+	assert.True(t, isInZone("Foo.Example.com.", "example.com."))
+	assert.False(t, isInZone("fooexample.com", "example.com."))
+	assert.False(t, isInZone("example.org", "example.com."))
+}
+
+func TestManager_updateZones(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientDns := mockTypes.NewMockClientDNS(ctrl)
+	notified := make(chan string, 10)
+	clientDns.EXPECT().Exchange(gomock.Any(), "10.0.0.2:53").AnyTimes().DoAndReturn(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+		assert.Equal(t, dns.OpcodeNotify, msg.Opcode)
+		notified <- msg.Question[0].Name
+		response := &dns.Msg{}
+		response.SetReply(msg)
+		return response, 0, nil
+	})
+	m := &Manager{
+		logger:    ctx.Logger,
+		clientDNS: clientDns,
+		zones:     map[string]*zoneTransfer{"local.": {cfg: config.TransferZoneConfig{Name: "local", Notify: []string{"10.0.0.2"}}, serial: 10}},
+	}
+
+	m.records = types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}
+	m.updateZones()
+	assert.Equal(t, uint32(10), m.zones["local."].serial)
+	assert.Equal(t, "local.", <-notified)
+
+	m.updateZones()
+	assert.Equal(t, uint32(10), m.zones["local."].serial)
+
+	m.records = types.Records{
+		"foo.local._A":   {{Name: "foo.local", Type: "A", Value: "127.0.0.2"}},
+		"foo.example._A": {{Name: "foo.example", Type: "A", Value: "127.0.0.3"}},
+	}
+	m.updateZones()
+	assert.Equal(t, uint32(11), m.zones["local."].serial)
+	assert.Equal(t, "local.", <-notified)
+	assert.Contains(t, buffer.String(), "zone local. updated with serial 11")
+}
+
+func TestManager_zoneSOA(t *testing.T) {
+	m := &Manager{zones: map[string]*zoneTransfer{"local.": {serial: 10}}}
+	assert.Nil(t, m.zoneSOA("example.com."))
+	assert.Equal(t, "local.\t300\tIN\tSOA\tns.local. hostmaster.local. 10 3600 600 86400 300", m.zoneSOA("Local").String())
+
+	m.records = types.Records{"local._SOA": {{Name: "local", Type: "SOA", Value: "ns1.local. admin.local. 5 1 1 2 60", TTL: 60}}}
+	assert.Equal(t, "local.\t60\tIN\tSOA\tns1.local. admin.local. 5 1 1 2 60", m.zoneSOA("local.").String())
+}
+
+func TestManager_answerQuestion_ZoneSOA(t *testing.T) {
+	m := &Manager{zones: map[string]*zoneTransfer{"local.": {serial: 10}}, records: types.Records{}}
+	message := &dns.Msg{Question: []dns.Question{{Name: "local.", Qtype: dns.TypeSOA, Qclass: dns.ClassINET}}}
+	m.answerQuestion(message, message.Question[0], nil)
+	assert.True(t, message.Authoritative)
+	assert.Contains(t, message.String(), "ANSWER SECTION:\nlocal.\t300\tIN\tSOA\tns.local. hostmaster.local. 10 3600 600 86400 300")
+}
+
+func TestManager_HandleDnsRequest_Transfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	records := types.Records{
+		"foo.local._A":    {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
+		"bar.local._TXT":  {{Name: "bar.local", Type: "TXT", Value: "\"hello\"", TTL: 60}},
+		"foo.example._A":  {{Name: "foo.example", Type: "A", Value: "127.0.0.2"}},
+		"wrong.local._A":  {{Name: "wrong.local", Type: "A", Value: "wrong"}},
+		"other.local._MX": {{Name: "other.local", Type: "MX", Value: "10 mail.local."}},
+	}
+	tcpAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	tests := []struct {
+		name       string
+		remoteAddr net.Addr
+		zone       string
+		tsig       string
+		tsigStatus error
+		cfg        config.TransferZoneConfig
+		wantRcode  int
+		wantLen    int
+	}{
+		{
+			name:       "SuccessAllowIP",
+			remoteAddr: tcpAddr,
+			zone:       "local.",
+			cfg:        config.TransferZoneConfig{Name: "local", Allow: []string{"10.0.0.1"}},
+			wantRcode:  dns.RcodeSuccess,
+			wantLen:    5,
+		},
+		{
+			name:       "SuccessAllowCIDR",
+			remoteAddr: tcpAddr,
+			zone:       "local.",
+			cfg:        config.TransferZoneConfig{Name: "local", Allow: []string{"192.168.0.0/16", "10.0.0.0/8"}},
+			wantRcode:  dns.RcodeSuccess,
+			wantLen:    5,
+		},
+		{
+			name:       "SuccessTsig",
+			remoteAddr: tcpAddr,
+			zone:       "local.",
+			tsig:       "transfer.",
+			cfg:        config.TransferZoneConfig{Name: "local", TsigKeys: []string{"transfer"}},
+			wantRcode:  dns.RcodeSuccess,
+			wantLen:    5,
+		},
+		{
+			name:       "FailUdp",
+			remoteAddr: &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000},
+			zone:       "local.",
+			cfg:        config.TransferZoneConfig{Name: "local", Allow: []string{"10.0.0.1"}},
+			wantRcode:  dns.RcodeRefused,
+		},
+		{
+			name:       "FailZoneNotFound",
+			remoteAddr: tcpAddr,
+			zone:       "example.",
+			cfg:        config.TransferZoneConfig{Name: "local", Allow: []string{"10.0.0.1"}},
+			wantRcode:  dns.RcodeRefused,
+		},
+		{
+			name:       "FailNotAllowed",
+			remoteAddr: tcpAddr,
+			zone:       "local.",
+			cfg:        config.TransferZoneConfig{Name: "local", Allow: []string{"10.0.0.2", "192.168.0.0/16"}},
+			wantRcode:  dns.RcodeRefused,
+		},
+		{
+			name:       "FailTsigKeyNotAllowed",
+			remoteAddr: tcpAddr,
+			zone:       "local.",
+			tsig:       "other.",
+			cfg:        config.TransferZoneConfig{Name: "local", TsigKeys: []string{"transfer"}, Allow: []string{"10.0.0.1"}},
+			wantRcode:  dns.RcodeRefused,
+		},
+		{
+			name:       "FailTsigInvalid",
+			remoteAddr: tcpAddr,
+			zone:       "local.",
+			tsig:       "transfer.",
+			tsigStatus: dns.ErrSig,
+			cfg:        config.TransferZoneConfig{Name: "local", TsigKeys: []string{"transfer"}},
+			wantRcode:  dns.RcodeNotAuth,
+		},
+		{
+			name:       "FailTsigUnknownKey",
+			remoteAddr: tcpAddr,
+			zone:       "local.",
+			tsig:       "unknown.",
+			cfg:        config.TransferZoneConfig{Name: "local", TsigKeys: []string{"unknown"}, Allow: []string{"10.0.0.1"}},
+			wantRcode:  dns.RcodeNotAuth,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TestContext(nil)
+			m := &Manager{
+				logger:   ctx.Logger,
+				records:  records,
+				zones:    map[string]*zoneTransfer{"local.": {cfg: tt.cfg, serial: 10}},
+				tsigKeys: tsig.Keys{"transfer.": {Algorithm: dns.HmacSHA256}, "other.": {Algorithm: dns.HmacSHA256}},
+			}
+			message := &dns.Msg{}
+			message.SetAxfr(tt.zone)
+			if tt.tsig != "" {
+				message.SetTsig(tt.tsig, dns.HmacSHA256, 300, time.Now().Unix())
+			}
+			responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+			responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(tt.remoteAddr)
+			responseWriter.EXPECT().TsigStatus().AnyTimes().Return(tt.tsigStatus)
+			responseWriter.EXPECT().TsigTimersOnly(true).AnyTimes()
+			responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).DoAndReturn(func(msg *dns.Msg) error {
+				assert.Equal(t, tt.wantRcode, msg.Rcode)
+				assert.Len(t, msg.Answer, tt.wantLen)
+				if tt.wantLen > 0 {
+					assert.Equal(t, dns.TypeSOA, msg.Answer[0].Header().Rrtype)
+					assert.Equal(t, dns.TypeSOA, msg.Answer[len(msg.Answer)-1].Header().Rrtype)
+				}
+				return nil
+			})
+
+			m.HandleDnsRequest()(responseWriter, message)
+		})
+	}
+}
+
+func TestManager_handleTransfer_FailWrite(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := &Manager{
+		logger:  ctx.Logger,
+		records: types.Records{},
+		zones:   map[string]*zoneTransfer{"local.": {cfg: config.TransferZoneConfig{Name: "local", Allow: []string{"10.0.0.1"}}, serial: 10}},
+	}
+	message := &dns.Msg{}
+	message.SetAxfr("local.")
+	responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+	responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+	responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).Return(errors.New("fail"))
+
+	assert.True(t, m.handleTransfer(responseWriter, message, new(dns.Msg), ""))
+	assert.Contains(t, buffer.String(), "error when transfer zone local.: fail")
+}
+
+func TestManager_sendNotify(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientDns := mockTypes.NewMockClientDNS(ctrl)
+	clientDns.EXPECT().Exchange(gomock.Any(), "10.0.0.2:53").Times(1).Return(nil, time.Duration(0), errors.New("timeout"))
+	clientDns.EXPECT().Exchange(gomock.Any(), "10.0.0.3:5353").Times(1).DoAndReturn(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+		response := &dns.Msg{}
+		response.SetRcode(msg, dns.RcodeRefused)
+		return response, 0, nil
+	})
+	m := &Manager{logger: ctx.Logger, clientDNS: clientDns}
+
+	m.sendNotify("local.", []string{"10.0.0.2", "10.0.0.3:5353"}, "")
+	assert.Contains(t, buffer.String(), "error when notify 10.0.0.2:53 for zone local.: timeout")
+	assert.Contains(t, buffer.String(), "notify 10.0.0.3:5353 for zone local. failed with rcode REFUSED")
+}
+
+func TestManager_sendNotify_Tsig(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientDns := mockTypes.NewMockClientDNS(ctrl)
+	clientDns.EXPECT().Exchange(gomock.Any(), "10.0.0.2:53").Times(1).DoAndReturn(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+		assert.Equal(t, "notify.", msg.IsTsig().Hdr.Name)
+		assert.Equal(t, dns.HmacSHA512, msg.IsTsig().Algorithm)
+		response := &dns.Msg{}
+		response.SetReply(msg)
+		return response, 0, nil
+	})
+	m := &Manager{logger: ctx.Logger, clientDNS: clientDns, tsigKeys: tsig.Keys{"notify.": {Algorithm: dns.HmacSHA512}}}
+
+	m.sendNotify("local.", []string{"10.0.0.2"}, "Notify")
+	m.sendNotify("local.", []string{"10.0.0.2"}, "unknown")
+	assert.Contains(t, buffer.String(), "unknown tsig key unknown. to notify 10.0.0.2:53 for zone local.")
+}
@@ -0,0 +1,50 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
+	"github.com/miekg/dns"
+	"strings"
+	"time"
+)
+
+func (m *Manager) SetTsigKeys(keys tsig.Keys) {
+	m.tsigKeys = keys
+	if client, ok := m.clientDNS.(*dns.Client); ok {
+		client.TsigProvider = keys
+	}
+}
+
+func (m *Manager) verifyTsig(w dns.ResponseWriter, r *dns.Msg) (string, error) {
+	rrTsig := r.IsTsig()
+	if rrTsig == nil {
+		return "", nil
+	}
+	keyName := strings.ToLower(rrTsig.Hdr.Name)
+	if _, ok := m.tsigKeys[keyName]; !ok {
+		return "", dns.ErrSecret
+	}
+	if err := w.TsigStatus(); err != nil {
+		return "", err
+	}
+	return keyName, nil
+}
+
+func (m *Manager) replyTsigError(w dns.ResponseWriter, r *dns.Msg, message *dns.Msg, err error) {
+	rrTsig := r.IsTsig()
+	m.logger.Warn(fmt.Sprintf("invalid tsig %s from %s: %v", rrTsig.Hdr.Name, w.RemoteAddr().String(), err))
+	message.Rcode = dns.RcodeNotAuth
+	message.SetTsig(rrTsig.Hdr.Name, rrTsig.Algorithm, rrTsig.Fudge, time.Now().Unix())
+	switch {
+	case errors.Is(err, dns.ErrSecret), errors.Is(err, dns.ErrKeyAlg):
+		message.IsTsig().Error = dns.RcodeBadKey
+	case errors.Is(err, dns.ErrTime):
+		message.IsTsig().Error = dns.RcodeBadTime
+	default:
+		message.IsTsig().Error = dns.RcodeBadSig
+	}
+	if errWrite := w.WriteMsg(message); errWrite != nil {
+		m.logger.Error(fmt.Sprintf("error %v", errWrite))
+	}
+}
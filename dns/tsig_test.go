@@ -0,0 +1,84 @@
+package dns
+
+import (
+	"bytes"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	mockMiekgDns "github.com/alexandreh2ag/go-dns-discover/mocks/miekg"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestManager_SetTsigKeys(t *testing.T) {
+	client := &dns.Client{}
+	keys := tsig.Keys{"transfer.": {Algorithm: dns.HmacSHA256, Secret: []byte("secret")}}
+	m := &Manager{clientDNS: client}
+	m.SetTsigKeys(keys)
+	assert.Equal(t, keys, m.tsigKeys)
+	assert.Equal(t, keys, client.TsigProvider)
+}
+
+func TestManager_HandleDnsRequest_TsigError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name       string
+		tsig       string
+		tsigStatus error
+		wantError  uint16
+	}{
+		{
+			name:      "FailUnknownKey",
+			tsig:      "unknown.",
+			wantError: dns.RcodeBadKey,
+		},
+		{
+			name:       "FailBadAlgorithm",
+			tsig:       "transfer.",
+			tsigStatus: dns.ErrKeyAlg,
+			wantError:  dns.RcodeBadKey,
+		},
+		{
+			name:       "FailBadSig",
+			tsig:       "transfer.",
+			tsigStatus: dns.ErrSig,
+			wantError:  dns.RcodeBadSig,
+		},
+		{
+			name:       "FailBadTime",
+			tsig:       "transfer.",
+			tsigStatus: dns.ErrTime,
+			wantError:  dns.RcodeBadTime,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			ctx := context.TestContext(buffer)
+			m := &Manager{
+				logger:   ctx.Logger,
+				tsigKeys: tsig.Keys{"transfer.": {Algorithm: dns.HmacSHA256, Secret: []byte("secret")}},
+			}
+			message := &dns.Msg{}
+			message.SetQuestion("foo.local.", dns.TypeA)
+			message.SetTsig(tt.tsig, dns.HmacSHA256, 300, time.Now().Unix())
+			responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+			responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+			responseWriter.EXPECT().TsigStatus().AnyTimes().Return(tt.tsigStatus)
+			responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).DoAndReturn(func(msg *dns.Msg) error {
+				assert.Equal(t, dns.RcodeNotAuth, msg.Rcode)
+				assert.Empty(t, msg.Answer)
+				assert.Equal(t, tt.wantError, msg.IsTsig().Error)
+				return nil
+			})
+
+			m.HandleDnsRequest()(responseWriter, message)
+			assert.Contains(t, buffer.String(), "invalid tsig "+tt.tsig)
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package dns
+
+import (
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var updateMetaTypes = []uint16{dns.TypeANY, dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB}
+
+func createUpdateZones(cfg config.UpdateConfig) map[string]config.UpdateZoneConfig {
+	zones := map[string]config.UpdateZoneConfig{}
+	for _, zoneCfg := range cfg.Zones {
+		zones[strings.ToLower(dns.Fqdn(zoneCfg.Name))] = zoneCfg
+	}
+	return zones
+}
+
+func AcceptMsgFunc(dh dns.Header) dns.MsgAcceptAction {
+	isResponse := dh.Bits&(1<<15) != 0
+	if opcode := int(dh.Bits>>11) & 0xF; opcode == dns.OpcodeUpdate && !isResponse {
+		if dh.Qdcount != 1 {
+			return dns.MsgReject
+		}
+		return dns.MsgAccept
+	}
+	return dns.DefaultMsgAcceptFunc(dh)
+}
+
+func (m *Manager) SetUpdateStore(store types.StoreProvider) {
+	m.updateStore = store
+}
+
+func (m *Manager) handleUpdate(message *dns.Msg, r *dns.Msg, keyName string, remoteAddr net.Addr) {
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA {
+		message.Rcode = dns.RcodeFormatError
+		return
+	}
+	zoneName := strings.ToLower(dns.Fqdn(r.Question[0].Name))
+	zone, ok := m.dynamicZones[zoneName]
+	if !ok {
+		m.logger.Warn(fmt.Sprintf("update of %s refused: zone not configured", zoneName))
+		message.Rcode = dns.RcodeNotAuth
+		return
+	}
+
+	if keyName == "" || !slices.ContainsFunc(zone.TsigKeys, func(key string) bool {
+		return strings.EqualFold(dns.Fqdn(key), keyName)
+	}) {
+		m.logger.Warn(fmt.Sprintf("update of %s refused for %s", zoneName, remoteAddr.String()))
+		message.Rcode = dns.RcodeRefused
+		return
+	}
+
+	if m.updateStore == nil {
+		m.logger.Error(fmt.Sprintf("update of %s refused: no update provider", zoneName))
+		message.Rcode = dns.RcodeRefused
+		return
+	}
+
+	if rcode := m.checkPrerequisites(zoneName, r.Answer); rcode != dns.RcodeSuccess {
+		message.Rcode = rcode
+		return
+	}
+	if rcode := prescanUpdates(zoneName, r.Ns); rcode != dns.RcodeSuccess {
+		message.Rcode = rcode
+		return
+	}
+	message.Rcode = m.applyUpdates(zoneName, r.Ns)
+}
+
+func (m *Manager) checkPrerequisites(zoneName string, prerequisites []dns.RR) int {
+	rrsets := map[string][]dns.RR{}
+	for _, rr := range prerequisites {
+		header := rr.Header()
+		if header.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !isInZone(header.Name, zoneName) {
+			return dns.RcodeNotZone
+		}
+		switch header.Class {
+		case dns.ClassANY:
+			if header.Rrtype == dns.TypeANY && !m.isNameInUse(header.Name) {
+				return dns.RcodeNameError
+			}
+			if header.Rrtype != dns.TypeANY && len(m.findRRset(header.Name, header.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if header.Rrtype == dns.TypeANY && m.isNameInUse(header.Name) {
+				return dns.RcodeYXDomain
+			}
+			if header.Rrtype != dns.TypeANY && len(m.findRRset(header.Name, header.Rrtype)) > 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			key := types.FormatRecordKey(strings.ToLower(header.Name), dns.TypeToString[header.Rrtype])
+			rrsets[key] = append(rrsets[key], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	for _, rrset := range rrsets {
+		existing := m.findRRset(rrset[0].Header().Name, rrset[0].Header().Rrtype)
+		if !isSameRRset(rrset, existing) {
+			return dns.RcodeNXRrset
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+func prescanUpdates(zoneName string, updates []dns.RR) int {
+	for _, rr := range updates {
+		header := rr.Header()
+		if !isInZone(header.Name, zoneName) {
+			return dns.RcodeNotZone
+		}
+		switch header.Class {
+		case dns.ClassINET:
+			if slices.Contains(updateMetaTypes, header.Rrtype) {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY:
+			if header.Ttl != 0 || (header.Rrtype != dns.TypeANY && slices.Contains(updateMetaTypes, header.Rrtype)) {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if header.Ttl != 0 || slices.Contains(updateMetaTypes, header.Rrtype) {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+func (m *Manager) applyUpdates(zoneName string, updates []dns.RR) int {
+	storeRecords, err := m.updateStore.GetRecords()
+	if err != nil {
+		m.logger.Error(fmt.Sprintf("error when get records of zone %s: %v", zoneName, err))
+		return dns.RcodeServerFailure
+	}
+	servedRecords := m.GetRecords()
+	records := types.Records{}
+	for key, keyRecords := range storeRecords {
+		records[key] = slices.Clone(keyRecords)
+	}
+
+	for _, rr := range updates {
+		header := rr.Header()
+		isApex := strings.EqualFold(dns.Fqdn(header.Name), zoneName)
+		if header.Rrtype == dns.TypeSOA && (header.Class == dns.ClassINET || isApex) {
+			continue
+		}
+		for _, key := range findRecordsKeys(servedRecords, header.Name, header.Rrtype) {
+			if isApex && header.Class == dns.ClassANY && isApexRecordsKey(key) {
+				continue
+			}
+			if slices.ContainsFunc(servedRecords[key], func(record *types.Record) bool { return !containsRecord(storeRecords[key], record) }) {
+				m.logger.Warn(fmt.Sprintf("update of %s refused: %s served by another provider", zoneName, rr.String()))
+				return dns.RcodeRefused
+			}
+		}
+
+		switch header.Class {
+		case dns.ClassINET:
+			record := types.ConvertRRToRecord(rr)
+			key := types.FormatRecordKey(record.Name, record.Type)
+			if keys := findRecordsKeys(records, header.Name, header.Rrtype); len(keys) > 0 {
+				key = keys[0]
+			}
+			records[key] = appendUniqueRecords(records[key], []*types.Record{record})
+		case dns.ClassANY:
+			for _, key := range findRecordsKeys(records, header.Name, header.Rrtype) {
+				if !isApex || !isApexRecordsKey(key) {
+					delete(records, key)
+				}
+			}
+		case dns.ClassNONE:
+			target := dns.Copy(rr)
+			target.Header().Class = dns.ClassINET
+			for _, key := range findRecordsKeys(records, header.Name, header.Rrtype) {
+				records[key] = slices.DeleteFunc(records[key], func(record *types.Record) bool {
+					existing, errConvert := types.ConvertRecordToRR(record)
+					return errConvert == nil && dns.IsDuplicate(existing, target)
+				})
+			}
+		}
+	}
+
+	updatedRecords, previousRecords := flattenRecords(records), flattenRecords(storeRecords)
+	added := []*types.Record{}
+	for _, record := range updatedRecords {
+		if !containsRecord(previousRecords, record) {
+			added = append(added, record)
+		}
+	}
+	deleted := []*types.Record{}
+	for _, record := range previousRecords {
+		if !containsRecord(updatedRecords, record) {
+			deleted = append(deleted, record)
+		}
+	}
+	if len(added) == 0 && len(deleted) == 0 {
+		return dns.RcodeSuccess
+	}
+	if err = m.updateStore.UpdateRecords(added, deleted); err != nil {
+		m.logger.Error(fmt.Sprintf("error when update zone %s: %v", zoneName, err))
+		return dns.RcodeServerFailure
+	}
+	m.logger.Info(fmt.Sprintf("zone %s updated with %d added and %d deleted records", zoneName, len(added), len(deleted)))
+	return dns.RcodeSuccess
+}
+
+func isApexRecordsKey(key string) bool {
+	i := strings.LastIndex(key, "_")
+	return slices.Contains([]string{"SOA", "NS"}, strings.ToUpper(key[i+1:]))
+}
+
+func findRecordsKeys(records types.Records, name string, rrtype uint16) []string {
+	name = dns.Fqdn(name)
+	keys := []string{}
+	for key := range records {
+		i := strings.LastIndex(key, "_")
+		if i < 0 || !strings.EqualFold(key[:i], name) {
+			continue
+		}
+		if rrtype == dns.TypeANY || strings.EqualFold(key[i+1:], dns.TypeToString[rrtype]) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func flattenRecords(records types.Records) []*types.Record {
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	flatten := []*types.Record{}
+	for _, key := range keys {
+		flatten = append(flatten, records[key]...)
+	}
+	return flatten
+}
+
+func (m *Manager) findRecordsByName(name string) []*types.Record {
+	records := []*types.Record{}
+	servedRecords := m.GetRecords()
+	for _, key := range findRecordsKeys(servedRecords, name, dns.TypeANY) {
+		records = append(records, servedRecords[key]...)
+	}
+	return records
+}
+
+func (m *Manager) isNameInUse(name string) bool {
+	return len(m.findRecordsByName(name)) > 0
+}
+
+func (m *Manager) findRRset(name string, rrtype uint16) []dns.RR {
+	rrs := []dns.RR{}
+	for _, record := range m.findRecordsByName(name) {
+		if !strings.EqualFold(record.Type, dns.TypeToString[rrtype]) {
+			continue
+		}
+		if rr, err := types.ConvertRecordToRR(record); err == nil {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+func isSameRRset(rrset []dns.RR, existing []dns.RR) bool {
+	for _, rr := range rrset {
+		if !slices.ContainsFunc(existing, func(e dns.RR) bool { return dns.IsDuplicate(e, rr) }) {
+			return false
+		}
+	}
+	for _, e := range existing {
+		if !slices.ContainsFunc(rrset, func(rr dns.RR) bool { return dns.IsDuplicate(e, rr) }) {
+			return false
+		}
+	}
+	return true
+}
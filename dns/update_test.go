@@ -0,0 +1,319 @@
+package dns
+
+import (
+	"bytes"
+	"errors"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	mockMiekgDns "github.com/alexandreh2ag/go-dns-discover/mocks/miekg"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestRR(s string) dns.RR {
+	rr, _ := dns.NewRR(s)
+	return rr
+}
+
+func Test_createUpdateZones(t *testing.T) {
+	cfg := config.UpdateConfig{Provider: "api", Zones: []config.UpdateZoneConfig{{Name: "Local", TsigKeys: []string{"update"}}}}
+	assert.Equal(t, map[string]config.UpdateZoneConfig{"local.": cfg.Zones[0]}, createUpdateZones(cfg))
+}
+
+func TestAcceptMsgFunc(t *testing.T) {
+	assert.Equal(t, dns.MsgAccept, AcceptMsgFunc(dns.Header{Bits: uint16(dns.OpcodeUpdate) << 11, Qdcount: 1, Ancount: 2, Nscount: 3}))
+	assert.Equal(t, dns.MsgReject, AcceptMsgFunc(dns.Header{Bits: uint16(dns.OpcodeUpdate) << 11, Qdcount: 2}))
+	assert.Equal(t, dns.MsgIgnore, AcceptMsgFunc(dns.Header{Bits: uint16(dns.OpcodeUpdate)<<11 | 1<<15, Qdcount: 1}))
+	assert.Equal(t, dns.MsgAccept, AcceptMsgFunc(dns.Header{Bits: uint16(dns.OpcodeQuery) << 11, Qdcount: 1}))
+	assert.Equal(t, dns.MsgRejectNotImplemented, AcceptMsgFunc(dns.Header{Bits: uint16(dns.OpcodeStatus) << 11, Qdcount: 1}))
+}
+
+func TestManager_HandleDnsRequest_Update(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	recordA := &types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.1"}
+	recordTXT := &types.Record{Name: "foo.local", Type: "TXT", Value: "\"hello\""}
+	records := types.Records{
+		"foo.local._A":   {recordA},
+		"foo.local._TXT": {recordTXT},
+		"local._SOA":     {{Name: "local", Type: "SOA", Value: "ns.local. hostmaster.local. 1 3600 600 86400 300"}},
+		"local._NS":      {{Name: "local", Type: "NS", Value: "ns.local."}},
+	}
+	tests := []struct {
+		name         string
+		zone         string
+		tsig         string
+		tsigStatus   error
+		noStore      bool
+		storeRecords types.Records
+		storeErr     error
+		msgFn        func(msg *dns.Msg)
+		mockFn       func(store *mockTypes.MockStoreProvider)
+		wantRcode    int
+	}{
+		{
+			name:  "SuccessAdd",
+			msgFn: func(msg *dns.Msg) { msg.Insert([]dns.RR{newTestRR("Bar.local. 60 IN A 127.0.0.2")}) },
+			mockFn: func(store *mockTypes.MockStoreProvider) {
+				store.EXPECT().UpdateRecords([]*types.Record{{Name: "bar.local", Type: "A", Value: "127.0.0.2", TTL: 60}}, []*types.Record{}).Times(1).Return(nil)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:      "SuccessAddDuplicate",
+			msgFn:     func(msg *dns.Msg) { msg.Insert([]dns.RR{newTestRR("foo.local. 60 IN A 127.0.0.1")}) },
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "SuccessAddSOAIgnored",
+			msgFn: func(msg *dns.Msg) {
+				msg.Insert([]dns.RR{newTestRR("local. 60 IN SOA ns.local. hostmaster.local. 2 3600 600 86400 300")})
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessDeleteRRset",
+			msgFn: func(msg *dns.Msg) { msg.RemoveRRset([]dns.RR{newTestRR("foo.local. A 0.0.0.0")}) },
+			mockFn: func(store *mockTypes.MockStoreProvider) {
+				store.EXPECT().UpdateRecords([]*types.Record{}, []*types.Record{recordA}).Times(1).Return(nil)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:  "SuccessDeleteName",
+			msgFn: func(msg *dns.Msg) { msg.RemoveName([]dns.RR{newTestRR("foo.local. A 0.0.0.0")}) },
+			mockFn: func(store *mockTypes.MockStoreProvider) {
+				store.EXPECT().UpdateRecords([]*types.Record{}, []*types.Record{recordA, recordTXT}).Times(1).Return(nil)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "SuccessDeleteRRsetAddSameRR",
+			msgFn: func(msg *dns.Msg) {
+				msg.RemoveRRset([]dns.RR{newTestRR("foo.local. A 0.0.0.0")})
+				msg.Insert([]dns.RR{newTestRR("foo.local. A 127.0.0.1"), newTestRR("foo.local. A 127.0.0.2")})
+			},
+			mockFn: func(store *mockTypes.MockStoreProvider) {
+				store.EXPECT().UpdateRecords([]*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.2", TTL: 3600}}, []*types.Record{}).Times(1).Return(nil)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:         "SuccessDeleteRRsetStoreOnly",
+			storeRecords: types.Records{"foo.local._A": {recordA}},
+			msgFn:        func(msg *dns.Msg) { msg.RemoveRRset([]dns.RR{newTestRR("foo.local. A 0.0.0.0")}) },
+			mockFn: func(store *mockTypes.MockStoreProvider) {
+				store.EXPECT().UpdateRecords([]*types.Record{}, []*types.Record{recordA}).Times(1).Return(nil)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:      "SuccessDeleteNameApex",
+			msgFn:     func(msg *dns.Msg) { msg.RemoveName([]dns.RR{newTestRR("local. A 0.0.0.0")}) },
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "SuccessDeleteRR",
+			msgFn: func(msg *dns.Msg) {
+				msg.Remove([]dns.RR{newTestRR("foo.local. A 127.0.0.1"), newTestRR("foo.local. A 127.0.0.9")})
+			},
+			mockFn: func(store *mockTypes.MockStoreProvider) {
+				store.EXPECT().UpdateRecords([]*types.Record{}, []*types.Record{recordA}).Times(1).Return(nil)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "SuccessPrerequisites",
+			msgFn: func(msg *dns.Msg) {
+				msg.Used([]dns.RR{newTestRR("foo.local. A 127.0.0.1")})
+				msg.NameUsed([]dns.RR{newTestRR("foo.local. A 0.0.0.0")})
+				msg.NameNotUsed([]dns.RR{newTestRR("bar.local. A 0.0.0.0")})
+				msg.RRsetUsed([]dns.RR{newTestRR("foo.local. TXT \"wrong\"")})
+				msg.RRsetNotUsed([]dns.RR{newTestRR("foo.local. AAAA ::1")})
+				msg.Insert([]dns.RR{newTestRR("bar.local. A 127.0.0.2")})
+			},
+			mockFn: func(store *mockTypes.MockStoreProvider) {
+				store.EXPECT().UpdateRecords([]*types.Record{{Name: "bar.local", Type: "A", Value: "127.0.0.2", TTL: 3600}}, []*types.Record{}).Times(1).Return(nil)
+			},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name:      "FailPrerequisiteUsed",
+			msgFn:     func(msg *dns.Msg) { msg.Used([]dns.RR{newTestRR("foo.local. A 127.0.0.9")}) },
+			wantRcode: dns.RcodeNXRrset,
+		},
+		{
+			name:      "FailPrerequisiteNameUsed",
+			msgFn:     func(msg *dns.Msg) { msg.NameUsed([]dns.RR{newTestRR("bar.local. A 0.0.0.0")}) },
+			wantRcode: dns.RcodeNameError,
+		},
+		{
+			name:      "FailPrerequisiteNameNotUsed",
+			msgFn:     func(msg *dns.Msg) { msg.NameNotUsed([]dns.RR{newTestRR("foo.local. A 0.0.0.0")}) },
+			wantRcode: dns.RcodeYXDomain,
+		},
+		{
+			name:      "FailPrerequisiteRRsetUsed",
+			msgFn:     func(msg *dns.Msg) { msg.RRsetUsed([]dns.RR{newTestRR("foo.local. AAAA ::1")}) },
+			wantRcode: dns.RcodeNXRrset,
+		},
+		{
+			name:      "FailPrerequisiteRRsetNotUsed",
+			msgFn:     func(msg *dns.Msg) { msg.RRsetNotUsed([]dns.RR{newTestRR("foo.local. A 0.0.0.0")}) },
+			wantRcode: dns.RcodeYXRrset,
+		},
+		{
+			name:      "FailPrerequisiteTTL",
+			msgFn:     func(msg *dns.Msg) { msg.Answer = append(msg.Answer, newTestRR("foo.local. 60 IN A 127.0.0.1")) },
+			wantRcode: dns.RcodeFormatError,
+		},
+		{
+			name:      "FailPrerequisiteNotZone",
+			msgFn:     func(msg *dns.Msg) { msg.Used([]dns.RR{newTestRR("foo.example. A 127.0.0.1")}) },
+			wantRcode: dns.RcodeNotZone,
+		},
+		{
+			name:      "FailUpdateNotZone",
+			msgFn:     func(msg *dns.Msg) { msg.Insert([]dns.RR{newTestRR("foo.example. A 127.0.0.1")}) },
+			wantRcode: dns.RcodeNotZone,
+		},
+		{
+			name: "FailUpdateMetaType",
+			msgFn: func(msg *dns.Msg) {
+				msg.Ns = append(msg.Ns, &dns.ANY{Hdr: dns.RR_Header{Name: "foo.local.", Rrtype: dns.TypeANY, Class: dns.ClassINET}})
+			},
+			wantRcode: dns.RcodeFormatError,
+		},
+		{
+			name: "FailUpdateClass",
+			msgFn: func(msg *dns.Msg) {
+				msg.Ns = append(msg.Ns, &dns.A{Hdr: dns.RR_Header{Name: "foo.local.", Rrtype: dns.TypeA, Class: dns.ClassCHAOS}, A: net.ParseIP("127.0.0.1")})
+			},
+			wantRcode: dns.RcodeFormatError,
+		},
+		{
+			name:  "FailStore",
+			msgFn: func(msg *dns.Msg) { msg.Insert([]dns.RR{newTestRR("bar.local. A 127.0.0.2")}) },
+			mockFn: func(store *mockTypes.MockStoreProvider) {
+				store.EXPECT().UpdateRecords(gomock.Any(), gomock.Any()).Times(1).Return(errors.New("fail"))
+			},
+			wantRcode: dns.RcodeServerFailure,
+		},
+		{
+			name:      "FailStoreGetRecords",
+			storeErr:  errors.New("fail"),
+			msgFn:     func(msg *dns.Msg) { msg.Insert([]dns.RR{newTestRR("bar.local. A 127.0.0.2")}) },
+			wantRcode: dns.RcodeServerFailure,
+		},
+		{
+			name:         "FailAddRRsetOtherProvider",
+			storeRecords: types.Records{"foo.local._A": {recordA}},
+			msgFn:        func(msg *dns.Msg) { msg.Insert([]dns.RR{newTestRR("foo.local. TXT \"world\"")}) },
+			wantRcode:    dns.RcodeRefused,
+		},
+		{
+			name:         "FailDeleteNameOtherProvider",
+			storeRecords: types.Records{"foo.local._A": {recordA}},
+			msgFn:        func(msg *dns.Msg) { msg.RemoveName([]dns.RR{newTestRR("foo.local. A 0.0.0.0")}) },
+			wantRcode:    dns.RcodeRefused,
+		},
+		{
+			name:      "FailZoneNotConfigured",
+			zone:      "example.",
+			msgFn:     func(msg *dns.Msg) {},
+			wantRcode: dns.RcodeNotAuth,
+		},
+		{
+			name:      "FailZoneSection",
+			msgFn:     func(msg *dns.Msg) { msg.Question[0].Qtype = dns.TypeA },
+			wantRcode: dns.RcodeFormatError,
+		},
+		{
+			name:      "FailWithoutTsig",
+			tsig:      "-",
+			msgFn:     func(msg *dns.Msg) {},
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name:       "FailTsigInvalid",
+			tsigStatus: dns.ErrSig,
+			msgFn:      func(msg *dns.Msg) {},
+			wantRcode:  dns.RcodeNotAuth,
+		},
+		{
+			name:      "FailTsigUnknownKey",
+			tsig:      "unknown.",
+			msgFn:     func(msg *dns.Msg) {},
+			wantRcode: dns.RcodeNotAuth,
+		},
+		{
+			name:      "FailTsigKeyNotAllowed",
+			tsig:      "other.",
+			msgFn:     func(msg *dns.Msg) {},
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name:      "FailWithoutStore",
+			noStore:   true,
+			msgFn:     func(msg *dns.Msg) {},
+			wantRcode: dns.RcodeRefused,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			ctx := context.TestContext(buffer)
+			store := mockTypes.NewMockStoreProvider(ctrl)
+			storeRecords := tt.storeRecords
+			if storeRecords == nil {
+				storeRecords = records
+			}
+			store.EXPECT().GetRecords().AnyTimes().Return(storeRecords, tt.storeErr)
+			if tt.mockFn != nil {
+				tt.mockFn(store)
+			}
+			m := &Manager{
+				logger:       ctx.Logger,
+				records:      records,
+				dynamicZones: map[string]config.UpdateZoneConfig{"local.": {Name: "local", TsigKeys: []string{"update"}}},
+				updateStore:  store,
+				tsigKeys:     tsig.Keys{"update.": {Algorithm: dns.HmacSHA256}, "other.": {Algorithm: dns.HmacSHA256}},
+			}
+			if tt.noStore {
+				m.updateStore = nil
+			}
+			zone := tt.zone
+			if zone == "" {
+				zone = "local."
+			}
+			message := &dns.Msg{}
+			message.SetUpdate(zone)
+			tt.msgFn(message)
+			switch tt.tsig {
+			case "":
+				message.SetTsig("update.", dns.HmacSHA256, 300, time.Now().Unix())
+			case "-":
+			default:
+				message.SetTsig(tt.tsig, dns.HmacSHA256, 300, time.Now().Unix())
+			}
+
+			responseWriter := mockMiekgDns.NewMockResponseWriter(ctrl)
+			responseWriter.EXPECT().RemoteAddr().AnyTimes().Return(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+			responseWriter.EXPECT().TsigStatus().AnyTimes().Return(tt.tsigStatus)
+			responseWriter.EXPECT().WriteMsg(gomock.Any()).Times(1).DoAndReturn(func(msg *dns.Msg) error {
+				assert.Equal(t, dns.RcodeToString[tt.wantRcode], dns.RcodeToString[msg.Rcode])
+				return nil
+			})
+
+			m.HandleDnsRequest()(responseWriter, message)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package dns
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"strings"
+)
+
+func createRecordsNames(records types.Records) map[string]struct{} {
+	names := map[string]struct{}{}
+	for key := range records {
+		i := strings.LastIndex(key, "_")
+		if i < 0 {
+			continue
+		}
+		owner := strings.ToLower(dns.Fqdn(key[:i]))
+		for off, end := 0, false; !end; off, end = dns.NextLabel(owner, off) {
+			names[owner[off:]] = struct{}{}
+		}
+		names["."] = struct{}{}
+	}
+	return names
+}
+
+func (m *Manager) nameExists(name string) bool {
+	_, ok := m.getRecordsNames()[strings.ToLower(dns.Fqdn(name))]
+	return ok
+}
+
+func (m *Manager) closestEncloser(name string) string {
+	name = strings.ToLower(dns.Fqdn(name))
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		if m.nameExists(name[off:]) {
+			return name[off:]
+		}
+	}
+	return "."
+}
+
+func (m *Manager) wildcardSource(name string) string {
+	if m.nameExists(name) {
+		return ""
+	}
+	source := "*."
+	if encloser := m.closestEncloser(name); encloser != "." {
+		source += encloser
+	}
+	if !m.nameExists(source) {
+		return ""
+	}
+	return source
+}
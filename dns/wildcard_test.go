@@ -0,0 +1,154 @@
+package dns
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func createRfc4592Records() types.Records {
+	return types.Records{
+		"example._SOA":                 {{Name: "example", Type: "SOA", Value: "ns.example.com. hostmaster.example. 1 3600 600 86400 300"}},
+		"example._NS":                  {{Name: "example", Type: "NS", Value: "ns.example.com."}, {Name: "example", Type: "NS", Value: "ns.example.net."}},
+		"*.example._TXT":               {{Name: "*.example", Type: "TXT", Value: "this is a wildcard"}},
+		"*.example._MX":                {{Name: "*.example", Type: "MX", Value: "10 host1.example."}},
+		"sub.*.example._TXT":           {{Name: "sub.*.example", Type: "TXT", Value: "this is not a wildcard"}},
+		"host1.example._A":             {{Name: "host1.example", Type: "A", Value: "192.0.2.1"}},
+		"_ssh._tcp.host1.example._SRV": {{Name: "_ssh._tcp.host1.example", Type: "SRV", Value: "0 0 22 host1.example."}},
+		"_ssh._tcp.host2.example._SRV": {{Name: "_ssh._tcp.host2.example", Type: "SRV", Value: "0 0 22 host2.example."}},
+		"subdel.example._NS":           {{Name: "subdel.example", Type: "NS", Value: "ns.example.com."}, {Name: "subdel.example", Type: "NS", Value: "ns.example.net."}},
+		"*.alias.example._CNAME":       {{Name: "*.alias.example", Type: "CNAME", Value: "host1.example."}},
+	}
+}
+
+func TestManager_findRecords_Rfc4592(t *testing.T) {
+	ctx := context.TestContext(nil)
+	tests := []struct {
+		name     string
+		question dns.Question
+		want     []*types.Record
+	}{
+		{
+			name:     "SuccessSynthesizeMX",
+			question: dns.Question{Name: "host3.example.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "host3.example", Type: "MX", Value: "10 host1.example."}},
+		},
+		{
+			name:     "SuccessSynthesizeNoDataA",
+			question: dns.Question{Name: "host3.example.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+			want:     []*types.Record{},
+		},
+		{
+			name:     "SuccessSynthesizeTXTMultipleLabels",
+			question: dns.Question{Name: "foo.bar.example.", Qtype: dns.TypeTXT, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "foo.bar.example", Type: "TXT", Value: "this is a wildcard"}},
+		},
+		{
+			name:     "SuccessNameExistsMX",
+			question: dns.Question{Name: "host1.example.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+			want:     []*types.Record{},
+		},
+		{
+			name:     "SuccessNameExistsWildcardParentMX",
+			question: dns.Question{Name: "sub.*.example.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+			want:     []*types.Record{},
+		},
+		{
+			name:     "SuccessEmptyNonTerminalEncloserSRV",
+			question: dns.Question{Name: "_telnet._tcp.host1.example.", Qtype: dns.TypeSRV, Qclass: dns.ClassINET},
+			want:     []*types.Record{},
+		},
+		{
+			name:     "SuccessEmptyNonTerminalTXT",
+			question: dns.Question{Name: "_tcp.host1.example.", Qtype: dns.TypeTXT, Qclass: dns.ClassINET},
+			want:     []*types.Record{},
+		},
+		{
+			name:     "SuccessWildcardEncloserMX",
+			question: dns.Question{Name: "ghost.*.example.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+			want:     []*types.Record{},
+		},
+		{
+			name:     "SuccessWildcardItself",
+			question: dns.Question{Name: "*.example.", Qtype: dns.TypeTXT, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "*.example", Type: "TXT", Value: "this is a wildcard"}},
+		},
+		{
+			name:     "SuccessExistingName",
+			question: dns.Question{Name: "sub.*.example.", Qtype: dns.TypeTXT, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "sub.*.example", Type: "TXT", Value: "this is not a wildcard"}},
+		},
+		{
+			name:     "SuccessSynthesizeCNAME",
+			question: dns.Question{Name: "www.alias.example.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+			want:     []*types.Record{{Name: "www.alias.example", Type: "CNAME", Value: "host1.example."}, {Name: "host1.example", Type: "A", Value: "192.0.2.1"}},
+		},
+		{
+			name:     "SuccessOutOfZone",
+			question: dns.Question{Name: "host3.other.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+			want:     []*types.Record{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{logger: ctx.Logger, records: createRfc4592Records(), recordsNames: createRecordsNames(createRfc4592Records())}
+			assert.Equal(t, tt.want, m.findRecords(tt.question))
+		})
+	}
+}
+
+func TestManager_wildcardSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		qname      string
+		wantExists bool
+		wantSource string
+	}{
+		{name: "SuccessSynthesized", qname: "host3.example.", wantSource: "*.example."},
+		{name: "SuccessSynthesizedMultipleLabels", qname: "foo.bar.example", wantSource: "*.example."},
+		{name: "SuccessExists", qname: "host1.example.", wantExists: true},
+		{name: "SuccessExistsCaseInsensitive", qname: "HOST1.Example.", wantExists: true},
+		{name: "SuccessEmptyNonTerminal", qname: "_tcp.host1.example.", wantExists: true},
+		{name: "SuccessNXDomainEmptyNonTerminalEncloser", qname: "_telnet._tcp.host1.example."},
+		{name: "SuccessNXDomainWildcardEncloser", qname: "ghost.*.example."},
+		{name: "SuccessNXDomainOutOfZone", qname: "host3.other."},
+		{name: "SuccessWildcardExists", qname: "*.example.", wantExists: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{recordsNames: createRecordsNames(createRfc4592Records())}
+			assert.Equal(t, tt.wantExists, m.nameExists(tt.qname))
+			assert.Equal(t, tt.wantSource, m.wildcardSource(tt.qname))
+		})
+	}
+}
+
+func TestManager_closestEncloser(t *testing.T) {
+	m := &Manager{recordsNames: createRecordsNames(createRfc4592Records())}
+	assert.Equal(t, "example.", m.closestEncloser("host3.example."))
+	assert.Equal(t, "_tcp.host1.example.", m.closestEncloser("_telnet._tcp.host1.example."))
+	assert.Equal(t, "*.example.", m.closestEncloser("ghost.*.example."))
+	assert.Equal(t, ".", m.closestEncloser("host3.other."))
+	assert.Equal(t, ".", m.closestEncloser("."))
+	assert.True(t, m.nameExists("."))
+	assert.False(t, (&Manager{}).nameExists("."))
+}
+
+func TestCreateRecordsNames(t *testing.T) {
+	records := types.Records{
+		"_ssh._tcp.Host1.example._SRV": {{Name: "_ssh._tcp.Host1.example", Type: "SRV", Value: "0 0 22 host1.example."}},
+		"*.example._TXT":               {{Name: "*.example", Type: "TXT", Value: "this is a wildcard"}},
+	}
+	want := map[string]struct{}{
+		"_ssh._tcp.host1.example.": {},
+		"_tcp.host1.example.":      {},
+		"host1.example.":           {},
+		"*.example.":               {},
+		"example.":                 {},
+		".":                        {},
+	}
+	assert.Equal(t, want, createRecordsNames(records))
+	assert.Equal(t, map[string]struct{}{}, createRecordsNames(types.Records{}))
+}
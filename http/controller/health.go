@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/dns"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+func GetHealthz(manager *dns.Manager) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		status := manager.Health()
+		if !status.Healthy {
+			return c.JSON(http.StatusServiceUnavailable, status)
+		}
+		return c.JSON(http.StatusOK, status)
+	}
+}
+
+func GetReadyz(manager *dns.Manager) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		status := manager.Readiness()
+		if !status.Ready {
+			return c.JSON(http.StatusServiceUnavailable, status)
+		}
+		return c.JSON(http.StatusOK, status)
+	}
+}
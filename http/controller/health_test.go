@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/dns"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetHealthz(t *testing.T) {
+	tests := []struct {
+		name      string
+		listeners []string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "SuccessHealthy",
+			listeners: []string{"udp", "tcp"},
+			wantCode:  http.StatusOK,
+			wantBody:  "{\"healthy\":true,\"listeners\":{\"tcp\":true,\"udp\":true}}\n",
+		},
+		{
+			name:      "FailListenerNotBound",
+			listeners: []string{"udp"},
+			wantCode:  http.StatusServiceUnavailable,
+			wantBody:  "{\"healthy\":false,\"listeners\":{\"tcp\":false,\"udp\":true}}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := dns.CreateManager(context.TestContext(nil), types.Providers{})
+			for _, network := range tt.listeners {
+				m.SetListenerReady(network, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53})
+			}
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := GetHealthz(m)(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestGetReadyz(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers types.Providers
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "SuccessReady",
+			providers: types.Providers{},
+			wantCode:  http.StatusOK,
+			wantBody:  "{\"ready\":true,\"listeners\":{\"tcp\":true,\"udp\":true},\"providers\":{}}\n",
+		},
+		{
+			name:      "FailProviderNotLoaded",
+			providers: types.Providers{"fs": nil},
+			wantCode:  http.StatusServiceUnavailable,
+			wantBody:  "{\"ready\":false,\"listeners\":{\"tcp\":true,\"udp\":true},\"providers\":{\"fs\":{\"type\":\"\",\"state\":\"pending\",\"ready\":false,\"records\":0}}}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := dns.CreateManager(context.TestContext(nil), tt.providers)
+			m.SetListenerReady("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53})
+			m.SetListenerReady("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53})
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := GetReadyz(m)(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
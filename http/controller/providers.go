@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/dns"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+func GetProviders(manager *dns.Manager) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, manager.GetProvidersStatus())
+	}
+}
+
+func GetProviderRecords(manager *dns.Manager) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		records, ok := manager.GetProviderRecords(c.Param("id"))
+		if !ok {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusOK, records)
+	}
+}
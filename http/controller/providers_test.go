@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/dns"
+	"github.com/alexandreh2ag/go-dns-discover/provider"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func createProvidersTestManager(t *testing.T) *dns.Manager {
+	ctx := context.TestContext(nil)
+	_ = afero.WriteFile(ctx.FS, "/app/config.yml", []byte("[{name: foo.local, type: A, value: 127.0.0.1}]"), 0644)
+	p, errProvider := provider.CreateProvider(ctx, "fs", config.Provider{Type: "fs", Config: map[string]interface{}{"path": "/app/config.yml"}})
+	assert.NoError(t, errProvider)
+	m := dns.CreateManager(ctx, types.Providers{"fs": p})
+	m.Start()
+	time.Sleep(500 * time.Millisecond)
+	return m
+}
+
+func TestGetProviders(t *testing.T) {
+	m := createProvidersTestManager(t)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	err := GetProviders(m)(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	got := map[string]dns.ProviderStatus{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Len(t, got, 1)
+	assert.Equal(t, "fs", got["fs"].Type)
+	assert.Equal(t, dns.ProviderStateStopped, got["fs"].State)
+	assert.True(t, got["fs"].Ready)
+	assert.Equal(t, 1, got["fs"].Records)
+	assert.NotNil(t, got["fs"].LastUpdate)
+}
+
+func TestGetProviderRecords(t *testing.T) {
+	records := types.Records{
+		"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
+	}
+	wantJson, _ := json.Marshal(&records)
+	m := createProvidersTestManager(t)
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Success",
+			id:       "fs",
+			wantCode: http.StatusOK,
+			wantBody: string(wantJson) + "\n",
+		},
+		{
+			name:     "FailNotFound",
+			id:       "wrong",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+			err := GetProviderRecords(m)(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/querylog"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type queryLogState struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func GetQueryLog(queryLogger *querylog.Logger) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		enabled := queryLogger.Enabled()
+		return c.JSON(http.StatusOK, queryLogState{Enabled: &enabled})
+	}
+}
+
+func UpdateQueryLog(queryLogger *querylog.Logger) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		state := queryLogState{}
+		if err := c.Bind(&state); err != nil || state.Enabled == nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		queryLogger.SetEnabled(*state.Enabled)
+		return c.JSON(http.StatusOK, state)
+	}
+}
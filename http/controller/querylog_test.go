@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/querylog"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetQueryLog(t *testing.T) {
+	queryLogger, err := querylog.NewLogger(config.QueryLogConfig{Enable: true, Output: "stdout", Format: "json", SampleRate: 1})
+	assert.NoError(t, err)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	err = GetQueryLog(queryLogger)(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "{\"enabled\":true}\n", rec.Body.String())
+}
+
+func TestUpdateQueryLog(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantEnabled bool
+	}{
+		{
+			name:        "SuccessEnable",
+			body:        `{"enabled": true}`,
+			wantCode:    http.StatusOK,
+			wantEnabled: true,
+		},
+		{
+			name:     "SuccessDisable",
+			body:     `{"enabled": false}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "FailMissingEnabled",
+			body:     `{}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "FailInvalidBody",
+			body:     `{"enabled": "wrong"`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryLogger, err := querylog.NewLogger(config.QueryLogConfig{Output: "stdout", Format: "json", SampleRate: 1})
+			assert.NoError(t, err)
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err = UpdateQueryLog(queryLogger)(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantEnabled, queryLogger.Enabled())
+		})
+	}
+}
@@ -12,3 +12,9 @@ func GetRecords(manager *dns.Manager) func(c echo.Context) error {
 		return c.JSON(http.StatusOK, records)
 	}
 }
+
+func GetRecordsConflicts(manager *dns.Manager) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, manager.GetConflicts())
+	}
+}
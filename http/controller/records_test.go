@@ -39,3 +39,26 @@ func TestGetRecords(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, string(wantJson)+"\n", rec.Body.String())
 }
+
+func TestGetRecordsConflicts(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctx.Config.MergePolicy = dns.MergePolicyPriority
+	ctx.Config.Providers = map[string]config.Provider{"fs": {Type: "fs", Priority: 10}}
+	_ = afero.WriteFile(ctx.FS, "/app/config.yml", []byte("[{name: foo.local, type: A, value: 127.0.0.1}]"), 0644)
+	_ = afero.WriteFile(ctx.FS, "/app/other.yml", []byte("[{name: foo.local, type: A, value: 127.0.0.2}]"), 0644)
+	p, errProvider := provider.CreateProvider(ctx, "fs", config.Provider{Type: "fs", Config: map[string]interface{}{"path": "/app/config.yml"}})
+	assert.NoError(t, errProvider)
+	p2, errProvider := provider.CreateProvider(ctx, "other", config.Provider{Type: "fs", Config: map[string]interface{}{"path": "/app/other.yml"}})
+	assert.NoError(t, errProvider)
+	m := dns.CreateManager(ctx, types.Providers{"fs": p, "other": p2})
+	m.Start()
+	time.Sleep(500 * time.Millisecond)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	err := GetRecordsConflicts(m)(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "[{\"key\":\"foo.local._A\",\"policy\":\"priority\",\"providers\":[\"fs\",\"other\"],\"winner\":\"fs\",\"shadowed\":[\"other\"]}]\n", rec.Body.String())
+}
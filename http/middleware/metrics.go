@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/alexandreh2ag/go-dns-discover/metrics"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+func HandlerMetrics(m *metrics.Metrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+			code := c.Response().Status
+			var httpError *echo.HTTPError
+			if errors.As(err, &httpError) {
+				code = httpError.Code
+			} else if err != nil {
+				code = http.StatusInternalServerError
+			}
+			m.ObserveHttpRequest(c.Request().Method, c.Path(), code)
+			return err
+		}
+	}
+}
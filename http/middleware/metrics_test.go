@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/alexandreh2ag/go-dns-discover/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler echo.HandlerFunc
+		want    string
+	}{
+		{
+			name: "SuccessOk",
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			},
+			want: `godnsd_http_requests_total{code="200",method="GET",path="/api/records"} 1`,
+		},
+		{
+			name: "SuccessHttpError",
+			handler: func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusNotFound)
+			},
+			want: `godnsd_http_requests_total{code="404",method="GET",path="/api/records"} 1`,
+		},
+		{
+			name: "SuccessError",
+			handler: func(c echo.Context) error {
+				return errors.New("fail")
+			},
+			want: `godnsd_http_requests_total{code="500",method="GET",path="/api/records"} 1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := metrics.NewMetrics()
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/records", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/records")
+			_ = HandlerMetrics(m)(tt.handler)(c)
+
+			recMetrics := httptest.NewRecorder()
+			m.Handler().ServeHTTP(recMetrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			assert.Contains(t, recMetrics.Body.String(), tt.want)
+		})
+	}
+}
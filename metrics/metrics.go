@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "godnsd"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	queries            *prometheus.CounterVec
+	queryDuration      *prometheus.HistogramVec
+	fallbackDuration   *prometheus.HistogramVec
+	signatureCache     *prometheus.CounterVec
+	providerRecords    *prometheus.GaugeVec
+	providerUpdates    *prometheus.CounterVec
+	providerLastUpdate *prometheus.GaugeVec
+	httpRequests       *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dns_queries_total",
+			Help:      "Number of DNS queries by type, response code and answer source.",
+		}, []string{"qtype", "rcode", "source"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "dns_query_duration_seconds",
+			Help:      "Duration of DNS queries by answer source.",
+			Buckets:   []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+		}, []string{"source"}),
+		fallbackDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "fallback_request_duration_seconds",
+			Help:      "Duration of requests to fallback nameservers.",
+			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		}, []string{"upstream", "result"}),
+		signatureCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dnssec_signature_cache_total",
+			Help:      "Number of DNSSEC signature cache lookups by result.",
+		}, []string{"result"}),
+		providerRecords: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "provider_records",
+			Help:      "Number of records provided by provider.",
+		}, []string{"provider"}),
+		providerUpdates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "provider_updates_total",
+			Help:      "Number of records updates received by provider.",
+		}, []string{"provider"}),
+		providerLastUpdate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "provider_last_update_timestamp_seconds",
+			Help:      "Timestamp of the last records update received by provider.",
+		}, []string{"provider"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP API requests by method, path and status code.",
+		}, []string{"method", "path", "code"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.queries,
+		m.queryDuration,
+		m.fallbackDuration,
+		m.signatureCache,
+		m.providerRecords,
+		m.providerUpdates,
+		m.providerLastUpdate,
+		m.httpRequests,
+	)
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+func (m *Metrics) ObserveQuery(qtype string, rcode string, source string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.queries.WithLabelValues(qtype, rcode, source).Inc()
+	m.queryDuration.WithLabelValues(source).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveFallback(upstream string, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.fallbackDuration.WithLabelValues(upstream, result).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveSignatureCache(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.signatureCache.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) ObserveProviderUpdate(provider string, records int) {
+	if m == nil {
+		return
+	}
+	m.providerRecords.WithLabelValues(provider).Set(float64(records))
+	m.providerUpdates.WithLabelValues(provider).Inc()
+	m.providerLastUpdate.WithLabelValues(provider).SetToCurrentTime()
+}
+
+func (m *Metrics) ObserveProviderExpire(provider string) {
+	if m == nil {
+		return
+	}
+	m.providerRecords.WithLabelValues(provider).Set(0)
+}
+
+func (m *Metrics) ObserveHttpRequest(method string, path string, code int) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.WithLabelValues(method, path, strconv.Itoa(code)).Inc()
+}
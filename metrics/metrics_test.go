@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewMetrics(t *testing.T) {
+	m := NewMetrics()
+	assert.NotNil(t, m.registry)
+	count, err := testutil.GatherAndCount(m.registry, "go_goroutines")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveQuery("A", "NOERROR", "fs", time.Millisecond)
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `godnsd_dns_queries_total{qtype="A",rcode="NOERROR",source="fs"} 1`)
+}
+
+func TestMetrics_ObserveQuery(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveQuery("A", "NOERROR", "fs", time.Millisecond)
+	m.ObserveQuery("A", "NOERROR", "fs", time.Millisecond)
+	m.ObserveQuery("AAAA", "NXDOMAIN", "", time.Millisecond)
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.queries.WithLabelValues("A", "NOERROR", "fs")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.queries.WithLabelValues("AAAA", "NXDOMAIN", "")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.queryDuration))
+}
+
+func TestMetrics_ObserveFallback(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveFallback("1.1.1.1:53", nil, 10*time.Millisecond)
+	m.ObserveFallback("1.1.1.1:53", errors.New("fail"), time.Second)
+	assert.Equal(t, 2, testutil.CollectAndCount(m.fallbackDuration))
+}
+
+func TestMetrics_ObserveSignatureCache(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveSignatureCache(true)
+	m.ObserveSignatureCache(false)
+	m.ObserveSignatureCache(true)
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.signatureCache.WithLabelValues("hit")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.signatureCache.WithLabelValues("miss")))
+}
+
+func TestMetrics_ObserveProviderUpdate(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveProviderUpdate("fs", 3)
+	m.ObserveProviderUpdate("fs", 2)
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.providerRecords.WithLabelValues("fs")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.providerUpdates.WithLabelValues("fs")))
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(m.providerLastUpdate.WithLabelValues("fs")), 5)
+}
+
+func TestMetrics_ObserveProviderExpire(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveProviderUpdate("fs", 3)
+	m.ObserveProviderExpire("fs")
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.providerRecords.WithLabelValues("fs")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.providerUpdates.WithLabelValues("fs")))
+}
+
+func TestMetrics_ObserveHttpRequest(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveHttpRequest(http.MethodGet, "/api/records", http.StatusOK)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/api/records", "200")))
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.ObserveQuery("A", "NOERROR", "fs", time.Millisecond)
+		m.ObserveFallback("1.1.1.1:53", nil, time.Millisecond)
+		m.ObserveSignatureCache(true)
+		m.ObserveProviderUpdate("fs", 1)
+		m.ObserveProviderExpire("fs")
+		m.ObserveHttpRequest(http.MethodGet, "/", http.StatusOK)
+	})
+}
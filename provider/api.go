@@ -21,7 +21,7 @@ const (
 )
 
 var (
-	_ types.Provider = &API{}
+	_ types.StoreProvider = &API{}
 )
 
 type httpRequestAcme struct {
@@ -33,6 +33,7 @@ type API struct {
 	id      string
 	logger  *slog.Logger
 	records types.Records
+	store   types.StoreProvider
 	notify  chan bool
 	done    chan bool
 	mtx     sync.Mutex
@@ -46,12 +47,17 @@ func (a *API) GetType() string {
 	return ApiKeyType
 }
 
+func (a *API) SetStore(store types.StoreProvider) {
+	a.store = store
+}
+
 func (a *API) Provide(configurationChan chan<- types.Message) error {
+	configurationChan <- types.Message{Provider: a, Records: a.cloneRecords()}
 
 	for {
 		select {
 		case <-a.notify:
-			configurationChan <- types.Message{Provider: a, Records: a.records}
+			configurationChan <- types.Message{Provider: a, Records: a.cloneRecords()}
 
 		case <-a.done:
 			return nil
@@ -70,7 +76,10 @@ func (a *API) HandlerAddRecord(c echo.Context) error {
 		a.logger.Error(fmt.Sprintf("record not valid: %v", record), "provider-type", a.GetType(), "provider-id", a.GetId())
 		return c.NoContent(http.StatusBadRequest)
 	}
-	a.addRecord(record)
+	if err := a.AddRecord(record); err != nil {
+		a.logger.Error(fmt.Sprintf("failed to store record %v: %s", record, err.Error()), "provider-type", a.GetType(), "provider-id", a.GetId())
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
 	return c.NoContent(http.StatusCreated)
 }
@@ -87,7 +96,10 @@ func (a *API) HandlerPresent(c echo.Context) error {
 		a.logger.Error(fmt.Sprintf("record not valid: %v", record), "provider-type", a.GetType(), "provider-id", a.GetId())
 		return c.NoContent(http.StatusBadRequest)
 	}
-	a.addRecord(record)
+	if err := a.AddRecord(record); err != nil {
+		a.logger.Error(fmt.Sprintf("failed to store record %v: %s", record, err.Error()), "provider-type", a.GetType(), "provider-id", a.GetId())
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
 	return c.NoContent(http.StatusCreated)
 }
@@ -103,7 +115,10 @@ func (a *API) HandlerDeleteRecord(c echo.Context) error {
 		a.logger.Error(fmt.Sprintf("record not valid: %v", record), "provider-type", a.GetType(), "provider-id", a.GetId())
 		return c.NoContent(http.StatusBadRequest)
 	}
-	a.deleteRecord(record)
+	if err := a.DeleteRecord(record); err != nil {
+		a.logger.Error(fmt.Sprintf("failed to delete record %v: %s", record, err.Error()), "provider-type", a.GetType(), "provider-id", a.GetId())
+		return c.NoContent(http.StatusInternalServerError)
+	}
 	return c.NoContent(http.StatusOK)
 }
 
@@ -119,45 +134,79 @@ func (a *API) HandlerCleanup(c echo.Context) error {
 		a.logger.Error(fmt.Sprintf("record not valid: %v", record), "provider-type", a.GetType(), "provider-id", a.GetId())
 		return c.NoContent(http.StatusBadRequest)
 	}
-	a.deleteRecord(record)
+	if err := a.DeleteRecord(record); err != nil {
+		a.logger.Error(fmt.Sprintf("failed to delete record %v: %s", record, err.Error()), "provider-type", a.GetType(), "provider-id", a.GetId())
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
 	return c.NoContent(http.StatusOK)
 }
 
-func (a *API) addRecord(record *types.Record) {
-	key := types.FormatRecordKey(record.Name, record.Type)
+func (a *API) AddRecord(record *types.Record) error {
+	if a.store != nil {
+		return a.store.AddRecord(record)
+	}
+	return a.UpdateRecords([]*types.Record{record}, []*types.Record{})
+}
+
+func (a *API) DeleteRecord(record *types.Record) error {
+	if a.store != nil {
+		return a.store.DeleteRecord(record)
+	}
+	return a.UpdateRecords([]*types.Record{}, []*types.Record{record})
+}
+
+func (a *API) GetRecords() (types.Records, error) {
+	if a.store != nil {
+		return a.store.GetRecords()
+	}
+	return a.cloneRecords(), nil
+}
+
+func (a *API) cloneRecords() types.Records {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
-	if _, ok := a.records[key]; !ok {
-		a.records[key] = []*types.Record{}
+	records := types.Records{}
+	for key, keyRecords := range a.records {
+		records[key] = slices.Clone(keyRecords)
 	}
-	a.records[key] = append(a.records[key], record)
-	a.notify <- true
+	return records
 }
 
-func (a *API) deleteRecord(record *types.Record) {
-	key := types.FormatRecordKey(record.Name, record.Type)
+func (a *API) UpdateRecords(added []*types.Record, deleted []*types.Record) error {
+	if a.store != nil {
+		return a.store.UpdateRecords(added, deleted)
+	}
 	a.mtx.Lock()
-	defer a.mtx.Unlock()
-	if _, ok := a.records[key]; ok {
-		a.records[key] = slices.DeleteFunc(a.records[key], func(r *types.Record) bool {
-			if r.Value == record.Value {
-				return true
+	for _, record := range deleted {
+		key := types.FormatRecordKey(record.Name, record.Type)
+		if _, ok := a.records[key]; ok {
+			a.records[key] = slices.DeleteFunc(a.records[key], func(r *types.Record) bool {
+				return r.Value == record.Value
+			})
+			if len(a.records[key]) == 0 {
+				delete(a.records, key)
 			}
-			return false
-		})
-		if len(a.records[key]) == 0 {
-			delete(a.records, key)
 		}
 	}
-	a.notify <- true
+	for _, record := range added {
+		key := types.FormatRecordKey(record.Name, record.Type)
+		a.records[key] = append(a.records[key], record)
+	}
+	a.mtx.Unlock()
+
+	select {
+	case a.notify <- true:
+	default:
+	}
+	return nil
 }
 
 func createApiProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
 
 	instance := &API{
 		id:      id,
-		notify:  make(chan bool),
+		notify:  make(chan bool, 1),
 		done:    ctx.Done(),
 		records: types.Records{},
 	}
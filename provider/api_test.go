@@ -3,11 +3,14 @@ package provider
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/alexandreh2ag/go-dns-discover/config"
 	"github.com/alexandreh2ag/go-dns-discover/context"
+	mockTypes "github.com/alexandreh2ag/go-dns-discover/mocks/types"
 	"github.com/alexandreh2ag/go-dns-discover/types"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -34,7 +37,7 @@ func Test_createApiProvider(t *testing.T) {
 	assert.NotNil(t, got)
 }
 
-func TestAPI_addRecord(t *testing.T) {
+func TestAPI_AddRecord(t *testing.T) {
 	ctx := context.TestContext(nil)
 
 	tests := []struct {
@@ -69,17 +72,17 @@ func TestAPI_addRecord(t *testing.T) {
 				id:      "api",
 				records: tt.records,
 				logger:  ctx.Logger,
-				notify:  make(chan bool),
+				notify:  make(chan bool, 1),
 				done:    ctx.Done(),
 			}
-			go a.addRecord(tt.record)
+			assert.NoError(t, a.AddRecord(tt.record))
 			<-a.notify
 			assert.Equal(t, tt.want, a.records)
 		})
 	}
 }
 
-func TestAPI_deleteRecord(t *testing.T) {
+func TestAPI_DeleteRecord(t *testing.T) {
 	ctx := context.TestContext(nil)
 
 	tests := []struct {
@@ -114,16 +117,65 @@ func TestAPI_deleteRecord(t *testing.T) {
 				id:      "api",
 				records: tt.records,
 				logger:  ctx.Logger,
-				notify:  make(chan bool),
+				notify:  make(chan bool, 1),
 				done:    ctx.Done(),
 			}
-			go a.deleteRecord(tt.record)
+			assert.NoError(t, a.DeleteRecord(tt.record))
 			<-a.notify
 			assert.Equal(t, tt.want, a.records)
 		})
 	}
 }
 
+func TestAPI_UpdateRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+	a := &API{
+		id: "api",
+		records: types.Records{
+			"foo.local._A":   {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
+			"foo.local._TXT": {{Name: "foo.local", Type: "TXT", Value: "\"hello\""}},
+		},
+		logger: ctx.Logger,
+		notify: make(chan bool, 1),
+		done:   ctx.Done(),
+	}
+	assert.NoError(t, a.UpdateRecords(
+		[]*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.2"}},
+		[]*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.1"}, {Name: "foo.local", Type: "TXT", Value: "\"hello\""}},
+	))
+	<-a.notify
+	got, err := a.GetRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.2"}}}, got)
+}
+
+func TestAPI_AddRecord_DeleteRecord_WithStore(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctrl := gomock.NewController(t)
+	record := &types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.1"}
+	store := mockTypes.NewMockStoreProvider(ctrl)
+	store.EXPECT().AddRecord(record).Times(1).Return(nil)
+	store.EXPECT().DeleteRecord(record).Times(1).Return(errors.New("fail"))
+	store.EXPECT().GetRecords().Times(1).Return(types.Records{"foo.local._A": {record}}, nil)
+	store.EXPECT().UpdateRecords([]*types.Record{record}, []*types.Record{}).Times(1).Return(nil)
+
+	a := &API{
+		id:      "api",
+		records: types.Records{},
+		logger:  ctx.Logger,
+		notify:  make(chan bool, 1),
+		done:    ctx.Done(),
+	}
+	a.SetStore(store)
+	assert.NoError(t, a.AddRecord(record))
+	assert.Error(t, a.DeleteRecord(record))
+	got, err := a.GetRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, types.Records{"foo.local._A": {record}}, got)
+	assert.NoError(t, a.UpdateRecords([]*types.Record{record}, []*types.Record{}))
+	assert.Equal(t, types.Records{}, a.records)
+}
+
 func TestAPI_Provide(t *testing.T) {
 	ctx := context.TestContext(nil)
 	records := types.Records{
@@ -135,7 +187,7 @@ func TestAPI_Provide(t *testing.T) {
 			"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
 		},
 		logger: ctx.Logger,
-		notify: make(chan bool),
+		notify: make(chan bool, 1),
 		done:   ctx.Done(),
 	}
 	configurationChan := make(chan types.Message, 1)
@@ -143,9 +195,14 @@ func TestAPI_Provide(t *testing.T) {
 		err := a.Provide(configurationChan)
 		assert.NoError(t, err)
 	}()
-	a.notify <- true
 	got := <-configurationChan
 	assert.Equal(t, records, got.Records)
+	assert.NoError(t, a.AddRecord(&types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.2"}))
+	assert.Equal(t, records, got.Records)
+	got = <-configurationChan
+	assert.Equal(t, types.Records{
+		"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}, {Name: "foo.local", Type: "A", Value: "127.0.0.2"}},
+	}, got.Records)
 	ctx.Cancel()
 }
 
@@ -189,7 +246,7 @@ func TestAPI_HandlerAddRecord(t *testing.T) {
 				id:      "api",
 				records: tt.records,
 				logger:  ctx.Logger,
-				notify:  make(chan bool),
+				notify:  make(chan bool, 1),
 				done:    ctx.Done(),
 			}
 			jsonBody, _ := json.Marshal(tt.body)
@@ -199,19 +256,36 @@ func TestAPI_HandlerAddRecord(t *testing.T) {
 			req.Header.Add("Content-Type", "application/json")
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
+			tt.wantErr(t, a.HandlerAddRecord(c))
 			if tt.waitChan {
-				go func() {
-					tt.wantErr(t, a.HandlerAddRecord(c))
-				}()
 				<-a.notify
-			} else {
-				tt.wantErr(t, a.HandlerAddRecord(c))
 			}
 			assert.Equal(t, tt.wantHttpCode, rec.Code)
 		})
 	}
 }
 
+func TestAPI_HandlerAddRecord_StoreFail(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctrl := gomock.NewController(t)
+	store := mockTypes.NewMockStoreProvider(ctrl)
+	store.EXPECT().AddRecord(gomock.Any()).Times(1).Return(errors.New("fail"))
+	a := &API{
+		id:      "api",
+		records: types.Records{},
+		logger:  ctx.Logger,
+		store:   store,
+		done:    ctx.Done(),
+	}
+	jsonBody, _ := json.Marshal(types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.1"})
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(jsonBody))
+	req.Header.Add("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	assert.NoError(t, a.HandlerAddRecord(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
 func TestAPI_HandlerDeleteRecord(t *testing.T) {
 	ctx := context.TestContext(nil)
 
@@ -252,7 +326,7 @@ func TestAPI_HandlerDeleteRecord(t *testing.T) {
 				id:      "api",
 				records: tt.records,
 				logger:  ctx.Logger,
-				notify:  make(chan bool),
+				notify:  make(chan bool, 1),
 				done:    ctx.Done(),
 			}
 			jsonBody, _ := json.Marshal(tt.body)
@@ -262,13 +336,9 @@ func TestAPI_HandlerDeleteRecord(t *testing.T) {
 			req.Header.Add("Content-Type", "application/json")
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
+			tt.wantErr(t, a.HandlerDeleteRecord(c))
 			if tt.waitChan {
-				go func() {
-					tt.wantErr(t, a.HandlerDeleteRecord(c))
-				}()
 				<-a.notify
-			} else {
-				tt.wantErr(t, a.HandlerDeleteRecord(c))
 			}
 			assert.Equal(t, tt.wantHttpCode, rec.Code)
 		})
@@ -315,7 +385,7 @@ func TestAPI_HandlerPresent(t *testing.T) {
 				id:      "api",
 				records: tt.records,
 				logger:  ctx.Logger,
-				notify:  make(chan bool),
+				notify:  make(chan bool, 1),
 				done:    ctx.Done(),
 			}
 			jsonBody, _ := json.Marshal(tt.body)
@@ -325,13 +395,9 @@ func TestAPI_HandlerPresent(t *testing.T) {
 			req.Header.Add("Content-Type", "application/json")
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
+			tt.wantErr(t, a.HandlerPresent(c))
 			if tt.waitChan {
-				go func() {
-					tt.wantErr(t, a.HandlerPresent(c))
-				}()
 				<-a.notify
-			} else {
-				tt.wantErr(t, a.HandlerPresent(c))
 			}
 			assert.Equal(t, tt.wantHttpCode, rec.Code)
 		})
@@ -378,7 +444,7 @@ func TestAPI_HandlerCleanup(t *testing.T) {
 				id:      "api",
 				records: tt.records,
 				logger:  ctx.Logger,
-				notify:  make(chan bool),
+				notify:  make(chan bool, 1),
 				done:    ctx.Done(),
 			}
 			jsonBody, _ := json.Marshal(tt.body)
@@ -388,13 +454,9 @@ func TestAPI_HandlerCleanup(t *testing.T) {
 			req.Header.Add("Content-Type", "application/json")
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
+			tt.wantErr(t, a.HandlerCleanup(c))
 			if tt.waitChan {
-				go func() {
-					tt.wantErr(t, a.HandlerCleanup(c))
-				}()
 				<-a.notify
-			} else {
-				tt.wantErr(t, a.HandlerCleanup(c))
 			}
 			assert.Equal(t, tt.wantHttpCode, rec.Code)
 		})
@@ -0,0 +1,286 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/miekg/dns"
+	"github.com/mitchellh/mapstructure"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[axfrKeyType] = createAxfrProvider
+}
+
+const (
+	axfrKeyType = "axfr"
+)
+
+var (
+	_ types.NotifyProvider = &Axfr{}
+)
+
+type configAxfr struct {
+	Zone    string `mapstructure:"zone" validate:"required"`
+	Primary string `mapstructure:"primary" validate:"required"`
+	Timeout int64  `mapstructure:"timeout" validate:"gt=0"`
+	TsigKey string `mapstructure:"tsig_key"`
+}
+
+type axfrZoneState struct {
+	soa         *dns.SOA
+	lastRefresh time.Time
+	expired     bool
+}
+
+type axfrPrimaryIps struct {
+	mtx sync.RWMutex
+	ips []net.IP
+}
+
+type Axfr struct {
+	id         string
+	cfg        configAxfr
+	notify     chan bool
+	primaryIps *axfrPrimaryIps
+	tsigKeys   tsig.Keys
+	logger     *slog.Logger
+	done       chan bool
+}
+
+func (a Axfr) GetId() string {
+	return a.id
+}
+
+func (a Axfr) GetType() string {
+	return axfrKeyType
+}
+
+func (a Axfr) Notify(zone string, remoteAddr net.Addr, keyName string) bool {
+	if !strings.EqualFold(dns.Fqdn(zone), dns.Fqdn(a.cfg.Zone)) || !a.isPrimary(remoteAddr) {
+		return false
+	}
+	if a.cfg.TsigKey != "" && keyName != tsig.KeyName(a.cfg.TsigKey) {
+		return false
+	}
+	select {
+	case a.notify <- true:
+	default:
+	}
+	return true
+}
+
+func (a Axfr) Provide(configurationChan chan<- types.Message) error {
+	a.resolvePrimary()
+	records, soa, err := a.transfer()
+	if err != nil {
+		return err
+	}
+	configurationChan <- types.Message{Provider: a, Records: records}
+
+	state := &axfrZoneState{soa: soa, lastRefresh: time.Now()}
+	timer := time.NewTimer(time.Duration(soa.Refresh) * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-a.notify:
+			a.logger.Debug(fmt.Sprintf("notify received for zone %s", a.cfg.Zone), "provider-type", a.GetType(), "provider-id", a.GetId())
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-a.done:
+			return nil
+		}
+
+		a.resolvePrimary()
+		records, soa, err = a.refresh(state)
+		if err != nil {
+			a.logger.Error(fmt.Sprintf("error when refresh zone %s: %s", a.cfg.Zone, err.Error()), "provider-type", a.GetType(), "provider-id", a.GetId())
+			if !state.expired && time.Since(state.lastRefresh) > time.Duration(state.soa.Expire)*time.Second {
+				a.logger.Error(fmt.Sprintf("zone %s expired", a.cfg.Zone), "provider-type", a.GetType(), "provider-id", a.GetId())
+				state.expired = true
+				configurationChan <- types.Message{Provider: a, Records: types.Records{}}
+			}
+			timer.Reset(time.Duration(state.soa.Retry) * time.Second)
+			continue
+		}
+
+		state.lastRefresh = time.Now()
+		if records != nil {
+			state.soa, state.expired = soa, false
+			configurationChan <- types.Message{Provider: a, Records: records}
+		}
+		timer.Reset(time.Duration(state.soa.Refresh) * time.Second)
+	}
+}
+
+func (a Axfr) refresh(state *axfrZoneState) (types.Records, *dns.SOA, error) {
+	soa, err := a.fetchSOA()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !state.expired && !isSerialNewer(state.soa.Serial, soa.Serial) {
+		return nil, state.soa, nil
+	}
+	return a.transfer()
+}
+
+func (a Axfr) fetchSOA() (*dns.SOA, error) {
+	msg := &dns.Msg{}
+	msg.SetQuestion(dns.Fqdn(a.cfg.Zone), dns.TypeSOA)
+	client := &dns.Client{Net: "tcp", Timeout: time.Duration(a.cfg.Timeout) * time.Second, TsigProvider: a.tsigKeys}
+	a.signMsg(msg)
+
+	response, _, err := client.Exchange(msg, a.primaryAddr())
+	if err != nil {
+		return nil, err
+	}
+	if response.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("SOA query failed with rcode %s", dns.RcodeToString[response.Rcode])
+	}
+	for _, rr := range response.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa, nil
+		}
+	}
+	return nil, errors.New("no SOA record in response")
+}
+
+func (a Axfr) transfer() (types.Records, *dns.SOA, error) {
+	msg := &dns.Msg{}
+	msg.SetAxfr(dns.Fqdn(a.cfg.Zone))
+	transfer := &dns.Transfer{
+		DialTimeout:  time.Duration(a.cfg.Timeout) * time.Second,
+		ReadTimeout:  time.Duration(a.cfg.Timeout) * time.Second,
+		WriteTimeout: time.Duration(a.cfg.Timeout) * time.Second,
+		TsigProvider: a.tsigKeys,
+	}
+	a.signMsg(msg)
+
+	envelopes, err := transfer.In(msg, a.primaryAddr())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := types.Records{}
+	var soa *dns.SOA
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, nil, envelope.Error
+		}
+		for _, rr := range envelope.RR {
+			if rrSOA, ok := rr.(*dns.SOA); ok && soa == nil {
+				soa = rrSOA
+			}
+			record := types.ConvertRRToRecord(rr)
+			key := types.FormatRecordKey(record.Name, record.Type)
+			if slices.ContainsFunc(records[key], func(r *types.Record) bool { return r.Value == record.Value }) {
+				continue
+			}
+			records[key] = append(records[key], record)
+		}
+	}
+	if soa == nil {
+		return nil, nil, fmt.Errorf("no SOA record received for zone %s", a.cfg.Zone)
+	}
+	a.logger.Info(fmt.Sprintf("zone %s transferred with serial %d", a.cfg.Zone, soa.Serial), "provider-type", a.GetType(), "provider-id", a.GetId())
+	return records, soa, nil
+}
+
+func (a Axfr) signMsg(msg *dns.Msg) {
+	if a.cfg.TsigKey == "" {
+		return
+	}
+	keyName := tsig.KeyName(a.cfg.TsigKey)
+	msg.SetTsig(keyName, a.tsigKeys[keyName].Algorithm, 300, time.Now().Unix())
+}
+
+func (a Axfr) primaryAddr() string {
+	if _, _, err := net.SplitHostPort(a.cfg.Primary); err != nil {
+		return net.JoinHostPort(a.cfg.Primary, "53")
+	}
+	return a.cfg.Primary
+}
+
+func (a Axfr) resolvePrimary() {
+	primaryHost, _, _ := net.SplitHostPort(a.primaryAddr())
+	addresses, err := net.LookupHost(primaryHost)
+	if err != nil {
+		a.logger.Warn(fmt.Sprintf("failed to resolve primary %s: %v", primaryHost, err), "provider-type", a.GetType(), "provider-id", a.GetId())
+		return
+	}
+	ips := []net.IP{}
+	for _, address := range addresses {
+		if ip := net.ParseIP(address); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	a.primaryIps.mtx.Lock()
+	defer a.primaryIps.mtx.Unlock()
+	a.primaryIps.ips = ips
+}
+
+func (a Axfr) isPrimary(remoteAddr net.Addr) bool {
+	if remoteAddr == nil {
+		return false
+	}
+	remoteHost, _, err := net.SplitHostPort(remoteAddr.String())
+	if err != nil {
+		return false
+	}
+	remoteIP := net.ParseIP(remoteHost)
+	a.primaryIps.mtx.RLock()
+	defer a.primaryIps.mtx.RUnlock()
+	return slices.ContainsFunc(a.primaryIps.ips, func(ip net.IP) bool { return ip.Equal(remoteIP) })
+}
+
+func isSerialNewer(current uint32, serial uint32) bool {
+	return int32(serial-current) > 0
+}
+
+func createAxfrProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configAxfr{Timeout: 10}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var tsigKeys tsig.Keys
+	if instanceConfig.TsigKey != "" {
+		tsigKeys, err = tsig.LoadKey(ctx.FS, ctx.Config.Tsig, instanceConfig.TsigKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	instance := &Axfr{
+		id:         id,
+		cfg:        instanceConfig,
+		notify:     make(chan bool, 1),
+		primaryIps: &axfrPrimaryIps{},
+		tsigKeys:   tsigKeys,
+		logger:     ctx.Logger,
+		done:       ctx.Done(),
+	}
+	return instance, nil
+}
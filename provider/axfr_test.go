@@ -0,0 +1,279 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/tsig"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const axfrTestSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+
+var axfrTestTsigKeys = tsig.Keys{"transfer.": {Algorithm: dns.HmacSHA256, Secret: []byte("secret-secret-secret")}}
+
+type axfrTestPrimary struct {
+	mtx     sync.Mutex
+	serial  uint32
+	records []string
+	tsig    bool
+	fail    bool
+	addr    string
+	server  *dns.Server
+}
+
+func newAxfrTestPrimary(t *testing.T, tsig bool) *axfrTestPrimary {
+	primary := &axfrTestPrimary{serial: 1, records: []string{"www.example.com. 60 IN A 10.0.0.1"}, tsig: tsig}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	primary.addr = listener.Addr().String()
+	started := make(chan bool)
+	primary.server = &dns.Server{Listener: listener, Handler: primary, NotifyStartedFunc: func() { close(started) }}
+	if tsig {
+		primary.server.TsigSecret = map[string]string{"transfer.": axfrTestSecret}
+	}
+	go func() {
+		_ = primary.server.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() { _ = primary.server.Shutdown() })
+	return primary
+}
+
+func (p *axfrTestPrimary) update(serial uint32, records ...string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.serial = serial
+	p.records = records
+}
+
+func (p *axfrTestPrimary) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	msg := &dns.Msg{}
+	msg.SetReply(r)
+	if p.fail || (p.tsig && (r.IsTsig() == nil || w.TsigStatus() != nil)) {
+		msg.Rcode = dns.RcodeRefused
+		_ = w.WriteMsg(msg)
+		return
+	}
+
+	soa, _ := dns.NewRR(fmt.Sprintf("example.com. 300 IN SOA ns1.example.com. admin.example.com. %d 1 1 2 60", p.serial))
+	if r.IsTsig() != nil {
+		msg.SetTsig(r.IsTsig().Hdr.Name, dns.HmacSHA256, 300, time.Now().Unix())
+	}
+	switch r.Question[0].Qtype {
+	case dns.TypeSOA:
+		msg.Answer = []dns.RR{soa}
+		_ = w.WriteMsg(msg)
+	case dns.TypeAXFR:
+		rrs := []dns.RR{soa}
+		for _, record := range p.records {
+			rr, _ := dns.NewRR(record)
+			rrs = append(rrs, rr)
+		}
+		rrs = append(rrs, soa)
+		ch := make(chan *dns.Envelope, 1)
+		transfer := &dns.Transfer{TsigSecret: p.server.TsigSecret}
+		ch <- &dns.Envelope{RR: rrs}
+		close(ch)
+		_ = transfer.Out(w, r, ch)
+		w.Hijack()
+	}
+}
+
+func Test_createAxfrProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctx.Config.Tsig = []config.TsigConfig{{Name: "transfer", Secret: axfrTestSecret}}
+
+	tests := []struct {
+		name         string
+		cfg          config.Provider
+		want         configAxfr
+		wantTsigKeys tsig.Keys
+		wantErr      assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessDefault",
+			cfg:     config.Provider{Config: map[string]interface{}{"zone": "example.com", "primary": "10.0.0.1"}},
+			want:    configAxfr{Zone: "example.com", Primary: "10.0.0.1", Timeout: 10},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "SuccessWithTsig",
+			cfg:          config.Provider{Config: map[string]interface{}{"zone": "example.com", "primary": "10.0.0.1:5353", "timeout": 2, "tsig_key": "transfer"}},
+			want:         configAxfr{Zone: "example.com", Primary: "10.0.0.1:5353", Timeout: 2, TsigKey: "transfer"},
+			wantTsigKeys: axfrTestTsigKeys,
+			wantErr:      assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"zone": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"zone": "example.com"}},
+			wantErr: assert.Error,
+		},
+		{
+			name: "FailUnknownTsigKey",
+			cfg:  config.Provider{Config: map[string]interface{}{"zone": "example.com", "primary": "10.0.0.1", "tsig_key": "unknown"}},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, "tsig key unknown is not defined", i...)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createAxfrProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createAxfrProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got.(*Axfr).cfg)
+				assert.Equal(t, tt.wantTsigKeys, got.(*Axfr).tsigKeys)
+				assert.Equal(t, "provider", got.GetId())
+				assert.Equal(t, axfrKeyType, got.GetType())
+			}
+		})
+	}
+}
+
+func TestAxfr_transfer(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name    string
+		tsig    bool
+		tsigKey string
+		want    types.Records
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Success",
+			want: types.Records{
+				"example.com._SOA":   {{Name: "example.com", Type: "SOA", Value: "ns1.example.com. admin.example.com. 1 1 1 2 60", TTL: 300}},
+				"www.example.com._A": {{Name: "www.example.com", Type: "A", Value: "10.0.0.1", TTL: 60}},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessWithTsig",
+			tsig:    true,
+			tsigKey: "transfer",
+			want: types.Records{
+				"example.com._SOA":   {{Name: "example.com", Type: "SOA", Value: "ns1.example.com. admin.example.com. 1 1 1 2 60", TTL: 300}},
+				"www.example.com._A": {{Name: "www.example.com", Type: "A", Value: "10.0.0.1", TTL: 60}},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailWithoutTsig",
+			tsig:    true,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newAxfrTestPrimary(t, tt.tsig)
+			a := Axfr{id: "provider", cfg: configAxfr{Zone: "example.com", Primary: primary.addr, Timeout: 1, TsigKey: tt.tsigKey}, tsigKeys: axfrTestTsigKeys, logger: ctx.Logger}
+			got, soa, err := a.transfer()
+			if !tt.wantErr(t, err, "transfer()") {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got)
+				assert.Equal(t, uint32(1), soa.Serial)
+			}
+		})
+	}
+}
+
+func TestAxfr_fetchSOA(t *testing.T) {
+	ctx := context.TestContext(nil)
+	primary := newAxfrTestPrimary(t, true)
+	a := Axfr{id: "provider", cfg: configAxfr{Zone: "example.com", Primary: primary.addr, Timeout: 1, TsigKey: "transfer"}, tsigKeys: axfrTestTsigKeys, logger: ctx.Logger}
+	soa, err := a.fetchSOA()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), soa.Serial)
+
+	a.cfg.TsigKey = ""
+	_, err = a.fetchSOA()
+	assert.ErrorContains(t, err, "SOA query failed with rcode REFUSED")
+}
+
+func TestAxfr_Notify(t *testing.T) {
+	ctx := context.TestContext(nil)
+	a := Axfr{cfg: configAxfr{Zone: "example.com", Primary: "127.0.0.1"}, notify: make(chan bool, 1), primaryIps: &axfrPrimaryIps{}, logger: ctx.Logger}
+	assert.False(t, a.Notify("example.com.", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}, ""))
+	a.resolvePrimary()
+	assert.True(t, a.Notify("Example.com.", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}, ""))
+	assert.True(t, a.Notify("example.com.", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}, ""))
+	assert.Len(t, a.notify, 1)
+	assert.False(t, a.Notify("other.com.", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}, ""))
+	assert.False(t, a.Notify("example.com.", &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}, ""))
+	assert.False(t, a.Notify("example.com.", nil, ""))
+
+	a.cfg.TsigKey = "Transfer"
+	assert.False(t, a.Notify("example.com.", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}, ""))
+	assert.False(t, a.Notify("example.com.", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}, "other."))
+	assert.True(t, a.Notify("example.com.", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}, "transfer."))
+}
+
+func Test_isSerialNewer(t *testing.T) {
+	assert.True(t, isSerialNewer(1, 2))
+	assert.False(t, isSerialNewer(2, 2))
+	assert.False(t, isSerialNewer(3, 2))
+	assert.True(t, isSerialNewer(4294967295, 1))
+}
+
+func TestAxfr_Provide(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	primary := newAxfrTestPrimary(t, false)
+	a := Axfr{id: "provider", cfg: configAxfr{Zone: "example.com", Primary: primary.addr, Timeout: 1}, notify: make(chan bool, 1), primaryIps: &axfrPrimaryIps{}, logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 10)
+	go func() {
+		assert.NoError(t, a.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Len(t, msg.Records, 2)
+	assert.True(t, a.isPrimary(&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}))
+
+	primary.update(2, "www.example.com. 60 IN A 10.0.0.2")
+	a.notify <- true
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, []*types.Record{{Name: "www.example.com", Type: "A", Value: "10.0.0.2", TTL: 60}}, msg.Records["www.example.com._A"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after notify")
+	}
+
+	primary.mtx.Lock()
+	primary.fail = true
+	primary.mtx.Unlock()
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, types.Records{}, msg.Records)
+	case <-time.After(5 * time.Second):
+		t.Fatal("zone not expired")
+	}
+	assert.Contains(t, buffer.String(), "error when refresh zone example.com")
+	ctx.Cancel()
+}
+
+func TestAxfr_Provide_Fail(t *testing.T) {
+	ctx := context.TestContext(nil)
+	primary := newAxfrTestPrimary(t, true)
+	a := Axfr{id: "provider", cfg: configAxfr{Zone: "example.com", Primary: primary.addr, Timeout: 1}, notify: make(chan bool, 1), primaryIps: &axfrPrimaryIps{}, logger: ctx.Logger, done: ctx.Done()}
+	assert.Error(t, a.Provide(make(chan types.Message, 1)))
+}
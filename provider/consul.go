@@ -0,0 +1,273 @@
+package provider
+
+import (
+	stdContext "context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[consulKeyType] = createConsulProvider
+}
+
+const (
+	consulKeyType       = "consul"
+	consulIndexHeader   = "X-Consul-Index"
+	consulTokenHeader   = "X-Consul-Token"
+	defaultConsulDomain = "consul"
+)
+
+var (
+	_ types.Provider = &Consul{}
+
+	consulRetryTimeout = 5 * time.Second
+)
+
+type configConsul struct {
+	Address    string   `mapstructure:"address" validate:"required,url"`
+	Token      string   `mapstructure:"token"`
+	Datacenter string   `mapstructure:"datacenter"`
+	Domain     string   `mapstructure:"domain" validate:"required"`
+	Tags       []string `mapstructure:"tags"`
+	Wait       int64    `mapstructure:"wait" validate:"gt=0"`
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Node    string `json:"Node"`
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string   `json:"ID"`
+		Service string   `json:"Service"`
+		Tags    []string `json:"Tags"`
+		Address string   `json:"Address"`
+		Port    int      `json:"Port"`
+	} `json:"Service"`
+}
+
+type Consul struct {
+	id     string
+	cfg    configConsul
+	client *http.Client
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (c Consul) GetId() string {
+	return c.id
+}
+
+func (c Consul) GetType() string {
+	return consulKeyType
+}
+
+func (c Consul) Provide(configurationChan chan<- types.Message) error {
+	records, err := c.fetchRecords()
+	if err != nil {
+		return err
+	}
+	configurationChan <- types.Message{Provider: c, Records: records}
+
+	ctx, cancel := stdContext.WithCancel(stdContext.Background())
+	defer cancel()
+	notify := make(chan bool, 1)
+	go c.watch(ctx, "/v1/catalog/services", notify)
+	go c.watch(ctx, "/v1/health/state/any", notify)
+
+	for {
+		select {
+		case <-notify:
+			c.logger.Debug("consul catalog updated", "provider-type", c.GetType(), "provider-id", c.GetId())
+			records, err = c.fetchRecords()
+			if err != nil {
+				c.logger.Error(fmt.Sprintf("error when fetch consul records: %s", err.Error()), "provider-type", c.GetType(), "provider-id", c.GetId())
+				continue
+			}
+			configurationChan <- types.Message{Provider: c, Records: records}
+		case <-c.done:
+			return nil
+		}
+	}
+}
+
+func (c Consul) watch(ctx stdContext.Context, path string, notify chan bool) {
+	index := uint64(0)
+	for ctx.Err() == nil {
+		query := url.Values{}
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", c.cfg.Wait))
+		newIndex, err := c.get(ctx, path, query, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.Error(fmt.Sprintf("error when watch consul %s: %s", path, err.Error()), "provider-type", c.GetType(), "provider-id", c.GetId())
+			select {
+			case <-time.After(consulRetryTimeout):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		if index != 0 && newIndex != index {
+			select {
+			case notify <- true:
+			default:
+			}
+		}
+		index = newIndex
+		if index == 0 {
+			index = 1
+		}
+	}
+}
+
+func (c Consul) fetchRecords() (types.Records, error) {
+	records := types.Records{}
+	services := map[string][]string{}
+	if _, err := c.get(stdContext.Background(), "/v1/catalog/services", url.Values{}, &services); err != nil {
+		return records, err
+	}
+
+	for service := range services {
+		entries := []consulServiceEntry{}
+		query := url.Values{}
+		query.Set("passing", "true")
+		if _, err := c.get(stdContext.Background(), fmt.Sprintf("/v1/health/service/%s", url.PathEscape(service)), query, &entries); err != nil {
+			return records, err
+		}
+		for _, entry := range entries {
+			if !c.matchTags(entry.Service.Tags) {
+				continue
+			}
+			for _, record := range c.formatEntryToRecords(entry) {
+				key := types.FormatRecordKey(record.Name, record.Type)
+				if slices.ContainsFunc(records[key], func(r *types.Record) bool { return r.Value == record.Value }) {
+					continue
+				}
+				records[key] = append(records[key], record)
+			}
+		}
+	}
+	return records, nil
+}
+
+func (c Consul) matchTags(tags []string) bool {
+	for _, tag := range c.cfg.Tags {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Consul) formatEntryToRecords(entry consulServiceEntry) []*types.Record {
+	records := []*types.Record{}
+	domain := strings.Trim(c.cfg.Domain, ".")
+	serviceName := strings.ToLower(entry.Service.Service)
+
+	address := entry.Service.Address
+	if address == "" {
+		address = entry.Node.Address
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		c.logger.Error(fmt.Sprintf("invalid address '%s' for consul service %s", address, entry.Service.ID), "provider-type", c.GetType(), "provider-id", c.GetId())
+		return records
+	}
+	typeRecord := "AAAA"
+	if ip.To4() != nil {
+		typeRecord = "A"
+		ip = ip.To4()
+	}
+
+	target := fmt.Sprintf("%s.node.%s", strings.ToLower(entry.Node.Node), domain)
+	if address != entry.Node.Address {
+		target = fmt.Sprintf("%s.addr.%s", hex.EncodeToString(ip), domain)
+	}
+	records = append(records, &types.Record{Name: target, Type: typeRecord, Value: address})
+
+	names := []string{fmt.Sprintf("%s.service.%s", serviceName, domain)}
+	srvNames := []string{fmt.Sprintf("%s.service.%s", serviceName, domain), fmt.Sprintf("_%s._tcp.service.%s", serviceName, domain)}
+	for _, tag := range entry.Service.Tags {
+		tag = strings.ToLower(tag)
+		names = append(names, fmt.Sprintf("%s.%s.service.%s", tag, serviceName, domain))
+		srvNames = append(srvNames, fmt.Sprintf("%s.%s.service.%s", tag, serviceName, domain), fmt.Sprintf("_%s._%s.service.%s", serviceName, tag, domain))
+	}
+
+	for _, name := range names {
+		records = append(records, &types.Record{Name: name, Type: typeRecord, Value: address})
+	}
+	if entry.Service.Port > 0 {
+		for _, name := range srvNames {
+			records = append(records, &types.Record{Name: name, Type: "SRV", Value: fmt.Sprintf("1 1 %d %s.", entry.Service.Port, target)})
+		}
+	}
+	return records
+}
+
+func (c Consul) get(ctx stdContext.Context, path string, query url.Values, value interface{}) (uint64, error) {
+	if c.cfg.Datacenter != "" {
+		query.Set("dc", c.cfg.Datacenter)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s%s?%s", strings.TrimSuffix(c.cfg.Address, "/"), path, query.Encode()), nil)
+	if err != nil {
+		return 0, err
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set(consulTokenHeader, c.cfg.Token)
+	}
+
+	response, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("request %s failed with status code %d", path, response.StatusCode)
+	}
+
+	index, _ := strconv.ParseUint(response.Header.Get(consulIndexHeader), 10, 64)
+	if value == nil {
+		return index, nil
+	}
+	return index, json.NewDecoder(response.Body).Decode(value)
+}
+
+func createConsulProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configConsul{Address: "http://127.0.0.1:8500", Domain: defaultConsulDomain, Wait: 300}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &Consul{
+		id:     id,
+		cfg:    instanceConfig,
+		client: &http.Client{},
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
@@ -0,0 +1,250 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type consulTestServer struct {
+	mtx      sync.Mutex
+	index    uint64
+	changed  chan bool
+	services map[string][]string
+	health   map[string]string
+	fail     bool
+}
+
+func newConsulTestServer() *consulTestServer {
+	return &consulTestServer{
+		index:    10,
+		changed:  make(chan bool),
+		services: map[string][]string{"web": {"v1"}, "db": {}},
+		health: map[string]string{
+			"web": `[{"Node":{"Node":"node1","Address":"10.0.0.1"},"Service":{"ID":"web1","Service":"web","Tags":["v1"],"Address":"","Port":8080}},{"Node":{"Node":"node2","Address":"10.0.0.2"},"Service":{"ID":"web2","Service":"web","Tags":["v1"],"Address":"10.0.1.2","Port":8080}}]`,
+			"db":  `[{"Node":{"Node":"node1","Address":"10.0.0.1"},"Service":{"ID":"db1","Service":"db","Tags":[],"Address":"","Port":0}}]`,
+		},
+	}
+}
+
+func (s *consulTestServer) update(fn func()) {
+	s.mtx.Lock()
+	fn()
+	s.index++
+	changed := s.changed
+	s.changed = make(chan bool)
+	s.mtx.Unlock()
+	close(changed)
+}
+
+func (s *consulTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	if s.fail {
+		s.mtx.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	index, changed := s.index, s.changed
+	s.mtx.Unlock()
+
+	if wantIndex, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wantIndex == index {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	w.Header().Set(consulIndexHeader, strconv.FormatUint(s.index, 10))
+	switch r.URL.Path {
+	case "/v1/catalog/services":
+		body := "{"
+		i := 0
+		for service, tags := range s.services {
+			if i > 0 {
+				body += ","
+			}
+			body += fmt.Sprintf(`"%s":[`, service)
+			for j, tag := range tags {
+				if j > 0 {
+					body += ","
+				}
+				body += fmt.Sprintf(`"%s"`, tag)
+			}
+			body += "]"
+			i++
+		}
+		_, _ = w.Write([]byte(body + "}"))
+	case "/v1/health/state/any":
+		_, _ = w.Write([]byte("[]"))
+	case "/v1/health/service/web", "/v1/health/service/db":
+		_, _ = w.Write([]byte(s.health[r.URL.Path[len("/v1/health/service/"):]]))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_createConsulProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name    string
+		cfg     config.Provider
+		want    configConsul
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessDefault",
+			cfg:     config.Provider{},
+			want:    configConsul{Address: "http://127.0.0.1:8500", Domain: "consul", Wait: 300},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessWithConfig",
+			cfg:     config.Provider{Config: map[string]interface{}{"address": "https://consul.local", "token": "secret", "datacenter": "dc1", "domain": "service.local", "tags": []string{"prod"}, "wait": 10}},
+			want:    configConsul{Address: "https://consul.local", Token: "secret", Datacenter: "dc1", Domain: "service.local", Tags: []string{"prod"}, Wait: 10},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"address": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"address": "wrong", "wait": 0}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createConsulProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createConsulProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got.(*Consul).cfg)
+				assert.Equal(t, "provider", got.GetId())
+				assert.Equal(t, consulKeyType, got.GetType())
+			}
+		})
+	}
+}
+
+func TestConsul_formatEntryToRecords(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	c := Consul{id: "provider", cfg: configConsul{Domain: "consul."}, logger: ctx.Logger}
+
+	entry := consulServiceEntry{}
+	entry.Node.Node = "Node1"
+	entry.Node.Address = "10.0.0.1"
+	entry.Service.Service = "Web"
+	entry.Service.Tags = []string{"v1"}
+	entry.Service.Port = 8080
+	assert.Equal(t, []*types.Record{
+		{Name: "node1.node.consul", Type: "A", Value: "10.0.0.1"},
+		{Name: "web.service.consul", Type: "A", Value: "10.0.0.1"},
+		{Name: "v1.web.service.consul", Type: "A", Value: "10.0.0.1"},
+		{Name: "web.service.consul", Type: "SRV", Value: "1 1 8080 node1.node.consul."},
+		{Name: "_web._tcp.service.consul", Type: "SRV", Value: "1 1 8080 node1.node.consul."},
+		{Name: "v1.web.service.consul", Type: "SRV", Value: "1 1 8080 node1.node.consul."},
+		{Name: "_web._v1.service.consul", Type: "SRV", Value: "1 1 8080 node1.node.consul."},
+	}, c.formatEntryToRecords(entry))
+
+	entry.Service.Address = "fd00::1"
+	entry.Service.Tags = []string{}
+	entry.Service.Port = 0
+	assert.Equal(t, []*types.Record{
+		{Name: "fd000000000000000000000000000001.addr.consul", Type: "AAAA", Value: "fd00::1"},
+		{Name: "web.service.consul", Type: "AAAA", Value: "fd00::1"},
+	}, c.formatEntryToRecords(entry))
+
+	entry.Service.Address = "wrong"
+	assert.Equal(t, []*types.Record{}, c.formatEntryToRecords(entry))
+	assert.Contains(t, buffer.String(), "invalid address 'wrong' for consul service")
+}
+
+func TestConsul_fetchRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+	consulServer := newConsulTestServer()
+	var gotToken, gotDc string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken, gotDc = r.Header.Get(consulTokenHeader), r.URL.Query().Get("dc")
+		consulServer.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := Consul{id: "provider", cfg: configConsul{Address: server.URL, Token: "secret", Datacenter: "dc1", Domain: "consul", Tags: []string{"v1"}, Wait: 1}, client: server.Client(), logger: ctx.Logger}
+	got, err := c.fetchRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", gotToken)
+	assert.Equal(t, "dc1", gotDc)
+	assert.ElementsMatch(t, []*types.Record{{Name: "web.service.consul", Type: "A", Value: "10.0.0.1"}, {Name: "web.service.consul", Type: "A", Value: "10.0.1.2"}}, got["web.service.consul._A"])
+	assert.ElementsMatch(t, []*types.Record{{Name: "web.service.consul", Type: "SRV", Value: "1 1 8080 node1.node.consul."}, {Name: "web.service.consul", Type: "SRV", Value: "1 1 8080 0a000102.addr.consul."}}, got["web.service.consul._SRV"])
+	assert.NotContains(t, got, "db.service.consul._A")
+
+	consulServer.fail = true
+	_, err = c.fetchRecords()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed with status code 403")
+}
+
+func TestConsul_Provide(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	consulRetryTimeout = 10 * time.Millisecond
+	consulServer := newConsulTestServer()
+	server := httptest.NewServer(consulServer)
+	defer server.Close()
+
+	c := Consul{id: "provider", cfg: configConsul{Address: server.URL, Domain: "consul", Wait: 1}, client: server.Client(), logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 40)
+	go func() {
+		assert.NoError(t, c.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Equal(t, []*types.Record{{Name: "db.service.consul", Type: "A", Value: "10.0.0.1"}}, msg.Records["db.service.consul._A"])
+
+	time.Sleep(100 * time.Millisecond)
+	consulServer.update(func() {
+		consulServer.health["db"] = `[{"Node":{"Node":"node3","Address":"10.0.0.3"},"Service":{"ID":"db1","Service":"db","Tags":[],"Address":"","Port":0}}]`
+	})
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, []*types.Record{{Name: "db.service.consul", Type: "A", Value: "10.0.0.3"}}, msg.Records["db.service.consul._A"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after consul update")
+	}
+
+	consulServer.update(func() {
+		consulServer.fail = true
+	})
+	time.Sleep(100 * time.Millisecond)
+	assert.Contains(t, buffer.String(), "error when watch consul")
+	ctx.Cancel()
+}
+
+func TestConsul_Provide_Fail(t *testing.T) {
+	ctx := context.TestContext(nil)
+	consulServer := newConsulTestServer()
+	consulServer.fail = true
+	server := httptest.NewServer(consulServer)
+	defer server.Close()
+
+	c := Consul{id: "provider", cfg: configConsul{Address: server.URL, Domain: "consul", Wait: 1}, client: server.Client(), logger: ctx.Logger, done: ctx.Done()}
+	err := c.Provide(make(chan types.Message, 1))
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"bytes"
 	stdContext "context"
 	"fmt"
 	"github.com/alexandreh2ag/go-dns-discover/config"
@@ -11,10 +12,14 @@ import (
 	dockerEvents "github.com/docker/docker/api/types/events"
 	dockerTypesFilters "github.com/docker/docker/api/types/filters"
 	docketClient "github.com/docker/docker/client"
+	"github.com/mitchellh/mapstructure"
 	"github.com/traefik/paerser/parser"
 	"log/slog"
 	"regexp"
 	"slices"
+	"strconv"
+	"strings"
+	"text/template"
 )
 
 func init() {
@@ -25,6 +30,11 @@ const (
 	dockerKeyType             = "docker"
 	defaultNetworkName        = "bridge"
 	defaultComposeNetworkName = "default"
+
+	dockerComposeProjectLabel = "com.docker.compose.project"
+	dockerComposeServiceLabel = "com.docker.compose.service"
+
+	dockerShortIdLength = 12
 )
 
 var (
@@ -32,6 +42,11 @@ var (
 	dockerClientFn                = func() (docketClient.APIClient, error) {
 		return docketClient.NewClientWithOpts(docketClient.FromEnv, docketClient.WithAPIVersionNegotiation())
 	}
+	dockerRuleFuncs = template.FuncMap{
+		"lower":     strings.ToLower,
+		"normalize": normalizeDomainLabel,
+	}
+	regexInvalidDomainLabel = regexp.MustCompile("[^a-z0-9-]+")
 )
 
 type ConfigRecordContainer struct {
@@ -41,16 +56,41 @@ type ConfigRecordContainer struct {
 	Network string
 }
 
+type ConfigDefaultContainer struct {
+	Enable  *bool
+	Name    string
+	Network string
+}
+
 type ConfigContainer struct {
 	Enable  bool
+	Default *ConfigDefaultContainer
 	Records map[string]*ConfigRecordContainer
 }
 
+type DockerRuleData struct {
+	ID       string
+	Name     string
+	Image    string
+	Labels   map[string]string
+	Networks map[string]string
+	Service  string
+	Project  string
+}
+
+type configDocker struct {
+	DefaultDomain    string `mapstructure:"default_domain"`
+	DefaultRule      string `mapstructure:"default_rule"`
+	ExposedByDefault bool   `mapstructure:"exposed_by_default"`
+}
+
 type Docker struct {
-	id     string
-	client docketClient.APIClient
-	logger *slog.Logger
-	done   chan bool
+	id          string
+	cfg         configDocker
+	defaultRule *template.Template
+	client      docketClient.APIClient
+	logger      *slog.Logger
+	done        chan bool
 }
 
 func (d Docker) GetId() string {
@@ -95,13 +135,18 @@ func (d Docker) listen(configurationChan chan<- types.Message) error {
 func (d Docker) fetchRecords() (types.Records, error) {
 	records := types.Records{}
 	listOpt := dockerContainer.ListOptions{Filters: dockerTypesFilters.NewArgs()}
-	listOpt.Filters.Add("label", fmt.Sprintf("%s.enable=true", types.AppName))
+	if !d.cfg.ExposedByDefault {
+		listOpt.Filters.Add("label", fmt.Sprintf("%s.enable=true", types.AppName))
+	}
 	containers, err := d.client.ContainerList(stdContext.Background(), listOpt)
 	if err != nil {
 		return records, err
 	}
 
 	for _, container := range containers {
+		if !d.isContainerEnabled(&container) {
+			continue
+		}
 		recordsContainer := d.formatLabelsToRecords(&container)
 		for _, record := range recordsContainer {
 			key := types.FormatRecordKey(record.Name, record.Type)
@@ -121,16 +166,18 @@ func (d Docker) formatLabelsToRecords(container *dockerTypes.Container) []*types
 	recordsContainer := &ConfigContainer{}
 	err := parser.Decode(container.Labels, recordsContainer, types.AppName, types.AppName)
 	if err != nil {
-		d.logger.Error(fmt.Sprintf("failed to decode labels for docker container %s", container.Names[0]))
+		d.logger.Error(fmt.Sprintf("failed to decode labels for docker container %s", containerName(container)))
 		return records
 	}
 
+	records = append(records, d.formatDefaultRecords(container, recordsContainer.Default)...)
+
 	for key, recordContainer := range recordsContainer.Records {
 		record := &types.Record{Name: recordContainer.Name, Type: recordContainer.Type, Value: recordContainer.Value}
 		if recordContainer.Value == "" && recordContainer.Type == "A" {
 			record.Value = d.findContainerIp(container, recordContainer)
 			if record.Value == "" {
-				d.logger.Error(fmt.Sprintf("failed to find container ip for container %s, label %s", container.Names[0], key))
+				d.logger.Error(fmt.Sprintf("failed to find container ip for container %s, label %s", containerName(container), key))
 				continue
 			}
 		}
@@ -140,8 +187,112 @@ func (d Docker) formatLabelsToRecords(container *dockerTypes.Container) []*types
 	return records
 }
 
+func (d Docker) isContainerEnabled(container *dockerTypes.Container) bool {
+	value, ok := container.Labels[fmt.Sprintf("%s.enable", types.AppName)]
+	if !ok {
+		return d.cfg.ExposedByDefault
+	}
+	enable, err := strconv.ParseBool(value)
+	if err != nil {
+		d.logger.Error(fmt.Sprintf("failed to parse enable label for docker container %s", containerName(container)))
+		return false
+	}
+	return enable
+}
+
+func (d Docker) formatDefaultRecords(container *dockerTypes.Container, defaultContainer *ConfigDefaultContainer) []*types.Record {
+	records := []*types.Record{}
+	if d.cfg.DefaultDomain == "" && d.defaultRule == nil {
+		return records
+	}
+
+	if defaultContainer == nil {
+		defaultContainer = &ConfigDefaultContainer{}
+	}
+	if defaultContainer.Enable != nil && !*defaultContainer.Enable {
+		return records
+	}
+
+	ip := d.findContainerIp(container, &ConfigRecordContainer{Network: defaultContainer.Network})
+	if ip == "" {
+		d.logger.Error(fmt.Sprintf("failed to find container ip for container %s, default records", containerName(container)))
+		return records
+	}
+
+	names := d.formatDefaultDomainNames(container, defaultContainer)
+	ruleNames, err := d.formatDefaultRuleNames(container)
+	if err != nil {
+		d.logger.Error(fmt.Sprintf("failed to execute default rule for container %s: %v", containerName(container), err))
+	}
+	names = append(names, ruleNames...)
+
+	for _, name := range names {
+		records = append(records, &types.Record{Name: name, Type: "A", Value: ip})
+	}
+
+	return records
+}
+
+func (d Docker) formatDefaultDomainNames(container *dockerTypes.Container, defaultContainer *ConfigDefaultContainer) []string {
+	names := []string{}
+	if d.cfg.DefaultDomain == "" {
+		return names
+	}
+
+	domain := strings.Trim(d.cfg.DefaultDomain, ".")
+	if defaultContainer.Name != "" {
+		names = append(names, fmt.Sprintf("%s.%s", defaultContainer.Name, domain))
+	} else {
+		names = append(names, fmt.Sprintf("%s.%s", containerName(container), domain))
+	}
+
+	service, project := container.Labels[dockerComposeServiceLabel], container.Labels[dockerComposeProjectLabel]
+	if service != "" && project != "" {
+		names = append(names, fmt.Sprintf("%s.%s.%s", service, project, domain))
+	}
+	return names
+}
+
+func (d Docker) formatDefaultRuleNames(container *dockerTypes.Container) ([]string, error) {
+	names := []string{}
+	if d.defaultRule == nil {
+		return names, nil
+	}
+
+	data := DockerRuleData{
+		ID:       container.ID,
+		Name:     containerName(container),
+		Image:    container.Image,
+		Labels:   container.Labels,
+		Networks: map[string]string{},
+		Service:  container.Labels[dockerComposeServiceLabel],
+		Project:  container.Labels[dockerComposeProjectLabel],
+	}
+	if container.NetworkSettings != nil {
+		for networkName, network := range container.NetworkSettings.Networks {
+			data.Networks[networkName] = network.IPAddress
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := d.defaultRule.Execute(buffer, data); err != nil {
+		return names, err
+	}
+
+	for _, name := range strings.Split(buffer.String(), ",") {
+		name = strings.Trim(strings.TrimSpace(name), ".")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
 func (d Docker) findContainerIp(container *dockerTypes.Container, recordContainer *ConfigRecordContainer) string {
-	dockerComposeProjectName := container.Labels["com.docker.compose.project"]
+	if container.NetworkSettings == nil {
+		return ""
+	}
+	dockerComposeProjectName := container.Labels[dockerComposeProjectLabel]
 	regexNetwork := regexp.MustCompile(fmt.Sprintf("^(%s|%s_%s)$", defaultNetworkName, dockerComposeProjectName, defaultComposeNetworkName))
 	if recordContainer.Network != "" && recordContainer.Network != defaultNetworkName {
 		regexNetwork = regexp.MustCompile(fmt.Sprintf("^(%s|%s_%s)$", recordContainer.Network, dockerComposeProjectName, recordContainer.Network))
@@ -155,15 +306,45 @@ func (d Docker) findContainerIp(container *dockerTypes.Container, recordContaine
 }
 
 func createDockerProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configDocker{}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var defaultRule *template.Template
+	if instanceConfig.DefaultRule != "" {
+		defaultRule, err = template.New(id).Option("missingkey=zero").Funcs(dockerRuleFuncs).Parse(instanceConfig.DefaultRule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse default rule: %w", err)
+		}
+	}
+
 	client, err := dockerClientFn()
 	if err != nil {
 		return nil, err
 	}
 	instance := &Docker{
-		id:     id,
-		logger: ctx.Logger,
-		client: client,
-		done:   ctx.Done(),
+		id:          id,
+		cfg:         instanceConfig,
+		defaultRule: defaultRule,
+		logger:      ctx.Logger,
+		client:      client,
+		done:        ctx.Done(),
 	}
 	return instance, nil
 }
+
+func containerName(container *dockerTypes.Container) string {
+	if len(container.Names) > 0 {
+		return strings.TrimPrefix(container.Names[0], "/")
+	}
+	if len(container.ID) > dockerShortIdLength {
+		return container.ID[:dockerShortIdLength]
+	}
+	return container.ID
+}
+
+func normalizeDomainLabel(value string) string {
+	return strings.Trim(regexInvalidDomainLabel.ReplaceAllString(strings.ToLower(value), "-"), "-")
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"bytes"
+	stdContext "context"
 	"errors"
 	"fmt"
 	"github.com/alexandreh2ag/go-dns-discover/config"
@@ -9,12 +10,14 @@ import (
 	mockDocker "github.com/alexandreh2ag/go-dns-discover/mocks/docker"
 	"github.com/alexandreh2ag/go-dns-discover/types"
 	dockerTypes "github.com/docker/docker/api/types"
+	dockerContainer "github.com/docker/docker/api/types/container"
 	dockerEvents "github.com/docker/docker/api/types/events"
 	dockerNetwork "github.com/docker/docker/api/types/network"
 	docketClient "github.com/docker/docker/client"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"testing"
+	"text/template"
 	"time"
 )
 
@@ -24,6 +27,7 @@ func Test_createDockerProvider(t *testing.T) {
 	client := mockDocker.NewMockAPIClient(ctrl)
 	tests := []struct {
 		name           string
+		cfg            config.Provider
 		createClientFn func() (docketClient.APIClient, error)
 		want           types.Provider
 		wantErr        assert.ErrorAssertionFunc
@@ -36,6 +40,33 @@ func Test_createDockerProvider(t *testing.T) {
 			want:    &Docker{id: "provider", logger: ctx.Logger, client: client, done: ctx.Done()},
 			wantErr: assert.NoError,
 		},
+		{
+			name: "successWithConfig",
+			cfg:  config.Provider{Config: map[string]interface{}{"default_domain": "docker.local", "exposed_by_default": true}},
+			createClientFn: func() (docketClient.APIClient, error) {
+				return client, nil
+			},
+			want:    &Docker{id: "provider", cfg: configDocker{DefaultDomain: "docker.local", ExposedByDefault: true}, logger: ctx.Logger, client: client, done: ctx.Done()},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "failDecodeCfg",
+			cfg:  config.Provider{Config: map[string]interface{}{"default_domain": []string{"wrong"}}},
+			createClientFn: func() (docketClient.APIClient, error) {
+				return client, nil
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
+		{
+			name: "failParseDefaultRule",
+			cfg:  config.Provider{Config: map[string]interface{}{"default_rule": "{{ .Name "}},
+			createClientFn: func() (docketClient.APIClient, error) {
+				return client, nil
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
 		{
 			name: "failCreateClientDocker",
 			createClientFn: func() (docketClient.APIClient, error) {
@@ -48,7 +79,7 @@ func Test_createDockerProvider(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			dockerClientFn = tt.createClientFn
-			got, err := createDockerProvider(ctx, "provider", config.Provider{})
+			got, err := createDockerProvider(ctx, "provider", tt.cfg)
 			if !tt.wantErr(t, err, fmt.Sprintf("createDockerProvider(ctx, 'provider', cfg)")) {
 				return
 			}
@@ -106,6 +137,12 @@ func TestDocker_findContainerIp(t *testing.T) {
 			recordContainer: &ConfigRecordContainer{Network: "unknown"},
 			want:            "",
 		},
+		{
+			name:            "SuccessWithoutNetworkSettings",
+			container:       &dockerTypes.Container{Labels: map[string]string{"com.docker.compose.project": "project"}},
+			recordContainer: &ConfigRecordContainer{},
+			want:            "",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -118,6 +155,259 @@ func TestDocker_findContainerIp(t *testing.T) {
 	}
 }
 
+func Test_createDockerProvider_WithDefaultRule(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctrl := gomock.NewController(t)
+	client := mockDocker.NewMockAPIClient(ctrl)
+	dockerClientFn = func() (docketClient.APIClient, error) {
+		return client, nil
+	}
+	got, err := createDockerProvider(ctx, "provider", config.Provider{Config: map[string]interface{}{"default_rule": "{{ .Name }}.local"}})
+	assert.NoError(t, err)
+	assert.NotNil(t, got.(*Docker).defaultRule)
+	assert.Equal(t, configDocker{DefaultRule: "{{ .Name }}.local"}, got.(*Docker).cfg)
+}
+
+func TestDocker_formatDefaultRuleNames(t *testing.T) {
+	ctx := context.TestContext(nil)
+	container := &dockerTypes.Container{
+		ID:     "abcdef",
+		Names:  []string{"/project-web-1"},
+		Image:  "nginx:latest",
+		Labels: map[string]string{"com.docker.compose.project": "Project", "com.docker.compose.service": "web"},
+		NetworkSettings: &dockerTypes.SummaryNetworkSettings{
+			Networks: map[string]*dockerNetwork.EndpointSettings{"project_default": {IPAddress: "127.0.0.1"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		want    []string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessNoRule",
+			want:    []string{},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessName",
+			rule:    "{{ .Name }}.local",
+			want:    []string{"project-web-1.local"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessLabels",
+			rule:    `{{ .Service }}.{{ index .Labels "com.docker.compose.project" | normalize }}.local.`,
+			want:    []string{"web.project.local"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessMultipleNames",
+			rule:    `{{ .Name }}.local, {{ .Service }}.local,{{ index .Labels "missing" }}`,
+			want:    []string{"project-web-1.local", "web.local"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessNetworks",
+			rule:    `{{ if index .Networks "project_default" }}{{ .Name }}.default.local{{ end }}`,
+			want:    []string{"project-web-1.default.local"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailExecute",
+			rule:    `{{ index .Name 100 }}`,
+			want:    []string{},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Docker{
+				id:     "provider",
+				logger: ctx.Logger,
+			}
+			if tt.rule != "" {
+				d.defaultRule = template.Must(template.New("provider").Option("missingkey=zero").Funcs(dockerRuleFuncs).Parse(tt.rule))
+			}
+			got, err := d.formatDefaultRuleNames(container)
+			if !tt.wantErr(t, err, fmt.Sprintf("formatDefaultRuleNames(%v)", container)) {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_normalizeDomainLabel(t *testing.T) {
+	assert.Equal(t, "my-project", normalizeDomainLabel("My_Project"))
+	assert.Equal(t, "foo-bar", normalizeDomainLabel("/foo.bar/"))
+}
+
+func TestDocker_isContainerEnabled(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name      string
+		cfg       configDocker
+		container *dockerTypes.Container
+		want      bool
+	}{
+		{
+			name:      "SuccessEnabled",
+			container: &dockerTypes.Container{Names: []string{"/test"}, Labels: map[string]string{fmt.Sprintf("%s.enable", types.AppName): "true"}},
+			want:      true,
+		},
+		{
+			name:      "SuccessNoLabel",
+			container: &dockerTypes.Container{Names: []string{"/test"}, Labels: map[string]string{}},
+			want:      false,
+		},
+		{
+			name:      "SuccessNoLabelExposedByDefault",
+			cfg:       configDocker{ExposedByDefault: true},
+			container: &dockerTypes.Container{Names: []string{"/test"}, Labels: map[string]string{}},
+			want:      true,
+		},
+		{
+			name:      "SuccessDisabledExposedByDefault",
+			cfg:       configDocker{ExposedByDefault: true},
+			container: &dockerTypes.Container{Names: []string{"/test"}, Labels: map[string]string{fmt.Sprintf("%s.enable", types.AppName): "false"}},
+			want:      false,
+		},
+		{
+			name:      "FailParseLabel",
+			cfg:       configDocker{ExposedByDefault: true},
+			container: &dockerTypes.Container{Names: []string{"/test"}, Labels: map[string]string{fmt.Sprintf("%s.enable", types.AppName): "wrong"}},
+			want:      false,
+		},
+		{
+			name:      "FailParseLabelWithoutNames",
+			cfg:       configDocker{ExposedByDefault: true},
+			container: &dockerTypes.Container{ID: "4f66ad9a0b2e1c3d", Labels: map[string]string{fmt.Sprintf("%s.enable", types.AppName): "wrong"}},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Docker{
+				id:     "provider",
+				cfg:    tt.cfg,
+				logger: ctx.Logger,
+			}
+			assert.Equalf(t, tt.want, d.isContainerEnabled(tt.container), "isContainerEnabled(%v)", tt.container)
+		})
+	}
+}
+
+func Test_containerName(t *testing.T) {
+	tests := []struct {
+		name      string
+		container *dockerTypes.Container
+		want      string
+	}{
+		{name: "SuccessName", container: &dockerTypes.Container{ID: "4f66ad9a0b2e1c3d5e7f9a1b3c5d7e9f", Names: []string{"/test"}}, want: "test"},
+		{name: "SuccessShortId", container: &dockerTypes.Container{ID: "4f66ad9a0b2e1c3d5e7f9a1b3c5d7e9f"}, want: "4f66ad9a0b2e"},
+		{name: "SuccessId", container: &dockerTypes.Container{ID: "4f66ad"}, want: "4f66ad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, containerName(tt.container))
+		})
+	}
+}
+
+func TestDocker_formatDefaultRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+	disable := false
+	networks := &dockerTypes.SummaryNetworkSettings{Networks: map[string]*dockerNetwork.EndpointSettings{"project_default": {IPAddress: "127.0.0.1"}, "project_other": {IPAddress: "127.0.0.2"}}}
+	composeLabels := map[string]string{"com.docker.compose.project": "project", "com.docker.compose.service": "web"}
+
+	tests := []struct {
+		name             string
+		cfg              configDocker
+		rule             string
+		container        *dockerTypes.Container
+		defaultContainer *ConfigDefaultContainer
+		want             []*types.Record
+	}{
+		{
+			name:      "SuccessNoDefaultDomain",
+			container: &dockerTypes.Container{Names: []string{"/test"}, Labels: composeLabels, NetworkSettings: networks},
+			want:      []*types.Record{},
+		},
+		{
+			name:      "SuccessContainerAndCompose",
+			cfg:       configDocker{DefaultDomain: "docker.local."},
+			container: &dockerTypes.Container{Names: []string{"/project-web-1"}, Labels: composeLabels, NetworkSettings: networks},
+			want: []*types.Record{
+				{Name: "project-web-1.docker.local", Type: "A", Value: "127.0.0.1"},
+				{Name: "web.project.docker.local", Type: "A", Value: "127.0.0.1"},
+			},
+		},
+		{
+			name: "SuccessWithoutCompose",
+			cfg:  configDocker{DefaultDomain: "docker.local"},
+			container: &dockerTypes.Container{Names: []string{"/test"}, Labels: map[string]string{}, NetworkSettings: &dockerTypes.SummaryNetworkSettings{
+				Networks: map[string]*dockerNetwork.EndpointSettings{"bridge": {IPAddress: "127.0.0.1"}},
+			}},
+			want: []*types.Record{{Name: "test.docker.local", Type: "A", Value: "127.0.0.1"}},
+		},
+		{
+			name: "SuccessWithoutNames",
+			cfg:  configDocker{DefaultDomain: "docker.local"},
+			container: &dockerTypes.Container{ID: "4f66ad9a0b2e1c3d5e7f", Labels: map[string]string{}, NetworkSettings: &dockerTypes.SummaryNetworkSettings{
+				Networks: map[string]*dockerNetwork.EndpointSettings{"bridge": {IPAddress: "127.0.0.1"}},
+			}},
+			want: []*types.Record{{Name: "4f66ad9a0b2e.docker.local", Type: "A", Value: "127.0.0.1"}},
+		},
+		{
+			name:             "SuccessOverrideNameAndNetwork",
+			cfg:              configDocker{DefaultDomain: "docker.local"},
+			container:        &dockerTypes.Container{Names: []string{"/project-web-1"}, Labels: composeLabels, NetworkSettings: networks},
+			defaultContainer: &ConfigDefaultContainer{Name: "custom", Network: "other"},
+			want: []*types.Record{
+				{Name: "custom.docker.local", Type: "A", Value: "127.0.0.2"},
+				{Name: "web.project.docker.local", Type: "A", Value: "127.0.0.2"},
+			},
+		},
+		{
+			name:      "SuccessDefaultRule",
+			rule:      "{{ .Service }}.rule.local",
+			container: &dockerTypes.Container{Names: []string{"/project-web-1"}, Labels: composeLabels, NetworkSettings: networks},
+			want:      []*types.Record{{Name: "web.rule.local", Type: "A", Value: "127.0.0.1"}},
+		},
+		{
+			name:             "SuccessDisabled",
+			cfg:              configDocker{DefaultDomain: "docker.local"},
+			container:        &dockerTypes.Container{Names: []string{"/project-web-1"}, Labels: composeLabels, NetworkSettings: networks},
+			defaultContainer: &ConfigDefaultContainer{Enable: &disable},
+			want:             []*types.Record{},
+		},
+		{
+			name:      "FailFindIp",
+			cfg:       configDocker{DefaultDomain: "docker.local"},
+			container: &dockerTypes.Container{Names: []string{"/test"}, Labels: composeLabels, NetworkSettings: &dockerTypes.SummaryNetworkSettings{}},
+			want:      []*types.Record{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Docker{
+				id:     "provider",
+				cfg:    tt.cfg,
+				logger: ctx.Logger,
+			}
+			if tt.rule != "" {
+				d.defaultRule = template.Must(template.New("provider").Funcs(dockerRuleFuncs).Parse(tt.rule))
+			}
+			got := d.formatDefaultRecords(tt.container, tt.defaultContainer)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestDocker_formatLabelsToRecords(t *testing.T) {
 	ctx := context.TestContext(nil)
 	tests := []struct {
@@ -193,6 +483,7 @@ func TestDocker_fetchRecords(t *testing.T) {
 
 	tests := []struct {
 		name    string
+		cfg     configDocker
 		mockFn  func(client *mockDocker.MockAPIClient)
 		want    types.Records
 		wantErr assert.ErrorAssertionFunc
@@ -219,6 +510,25 @@ func TestDocker_fetchRecords(t *testing.T) {
 			want:    types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}},
 			wantErr: assert.NoError,
 		},
+		{
+			name: "SuccessExposedByDefault",
+			cfg:  configDocker{DefaultDomain: "docker.local", ExposedByDefault: true},
+			mockFn: func(client *mockDocker.MockAPIClient) {
+				networks := &dockerTypes.SummaryNetworkSettings{
+					Networks: map[string]*dockerNetwork.EndpointSettings{"bridge": {IPAddress: "127.0.0.1"}},
+				}
+				containers := []dockerTypes.Container{
+					{Names: []string{"/foo"}, NetworkSettings: networks, Labels: map[string]string{}},
+					{Names: []string{"/bar"}, NetworkSettings: networks, Labels: map[string]string{fmt.Sprintf("%s.enable", types.AppName): "false"}},
+				}
+				client.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(_ stdContext.Context, options dockerContainer.ListOptions) ([]dockerTypes.Container, error) {
+					assert.Equal(t, 0, options.Filters.Len())
+					return containers, nil
+				})
+			},
+			want:    types.Records{"foo.docker.local._A": {{Name: "foo.docker.local", Type: "A", Value: "127.0.0.1"}}},
+			wantErr: assert.NoError,
+		},
 		{
 			name: "Fail",
 			mockFn: func(client *mockDocker.MockAPIClient) {
@@ -236,6 +546,7 @@ func TestDocker_fetchRecords(t *testing.T) {
 			tt.mockFn(client)
 			d := Docker{
 				id:     "provider",
+				cfg:    tt.cfg,
 				client: client,
 				logger: ctx.Logger,
 			}
@@ -0,0 +1,379 @@
+package provider
+
+import (
+	"bytes"
+	stdContext "context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[etcdKeyType] = createEtcdProvider
+}
+
+const (
+	etcdKeyType       = "etcd"
+	defaultEtcdPrefix = "/skydns"
+)
+
+var (
+	_ types.Provider = &Etcd{}
+
+	etcdRetryTimeout = 5 * time.Second
+)
+
+type configEtcd struct {
+	Endpoints []string `mapstructure:"endpoints" validate:"required,dive,url"`
+	Prefix    string   `mapstructure:"prefix" validate:"required"`
+	Username  string   `mapstructure:"username"`
+	Password  string   `mapstructure:"password" validate:"required_with=Username"`
+}
+
+type etcdKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type etcdRangeResponse struct {
+	Header struct {
+		Revision string `json:"revision"`
+	} `json:"header"`
+	Kvs []etcdKeyValue `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Created bool `json:"created"`
+		Events  []struct {
+			Type string       `json:"type"`
+			Kv   etcdKeyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type SkyDNSService struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Text     string `json:"text"`
+	TTL      uint32 `json:"ttl"`
+}
+
+type Etcd struct {
+	id     string
+	cfg    configEtcd
+	client *http.Client
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (e Etcd) GetId() string {
+	return e.id
+}
+
+func (e Etcd) GetType() string {
+	return etcdKeyType
+}
+
+func (e Etcd) Provide(configurationChan chan<- types.Message) error {
+	records, revision, err := e.fetchRecords()
+	if err != nil {
+		return err
+	}
+	configurationChan <- types.Message{Provider: e, Records: records}
+
+	ctx, cancel := stdContext.WithCancel(stdContext.Background())
+	defer cancel()
+	notify, errs := e.watch(ctx, revision)
+	for {
+		select {
+		case errWatch := <-errs:
+			e.logger.Error(fmt.Sprintf("error when watch etcd prefix %s: %s", e.cfg.Prefix, errWatch.Error()), "provider-type", e.GetType(), "provider-id", e.GetId())
+			select {
+			case <-time.After(etcdRetryTimeout):
+				if revision, err = e.resync(configurationChan, revision); err != nil {
+					e.logger.Error(fmt.Sprintf("error when fetch etcd records: %s", err.Error()), "provider-type", e.GetType(), "provider-id", e.GetId())
+				}
+				notify, errs = e.watch(ctx, revision)
+			case <-e.done:
+				return nil
+			}
+		case <-notify:
+			e.logger.Debug("etcd keys updated", "provider-type", e.GetType(), "provider-id", e.GetId())
+			records, revision, err = e.fetchRecords()
+			if err != nil {
+				e.logger.Error(fmt.Sprintf("error when fetch etcd records: %s", err.Error()), "provider-type", e.GetType(), "provider-id", e.GetId())
+				continue
+			}
+			configurationChan <- types.Message{Provider: e, Records: records}
+		case <-e.done:
+			return nil
+		}
+	}
+}
+
+func (e Etcd) resync(configurationChan chan<- types.Message, revision int64) (int64, error) {
+	records, newRevision, err := e.fetchRecords()
+	if err != nil {
+		return revision, err
+	}
+	configurationChan <- types.Message{Provider: e, Records: records}
+	return newRevision, nil
+}
+
+func (e Etcd) watch(ctx stdContext.Context, revision int64) (<-chan bool, <-chan error) {
+	notify, errs := make(chan bool), make(chan error, 1)
+	go func() {
+		key, rangeEnd := e.keyRange()
+		body := map[string]interface{}{"create_request": map[string]interface{}{"key": key, "range_end": rangeEnd, "start_revision": revision + 1}}
+		response, err := e.post(ctx, "/v3/watch", body)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer response.Body.Close()
+
+		decoder := json.NewDecoder(response.Body)
+		for {
+			watchResponse := etcdWatchResponse{}
+			if err = decoder.Decode(&watchResponse); err != nil {
+				if ctx.Err() == nil {
+					errs <- err
+				}
+				return
+			}
+			if watchResponse.Error != nil {
+				errs <- fmt.Errorf("%s", watchResponse.Error.Message)
+				return
+			}
+			if len(watchResponse.Result.Events) == 0 {
+				continue
+			}
+			select {
+			case notify <- true:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return notify, errs
+}
+
+func (e Etcd) fetchRecords() (types.Records, int64, error) {
+	records := types.Records{}
+	key, rangeEnd := e.keyRange()
+	response, err := e.post(stdContext.Background(), "/v3/kv/range", map[string]interface{}{"key": key, "range_end": rangeEnd})
+	if err != nil {
+		return records, 0, err
+	}
+	defer response.Body.Close()
+
+	rangeResponse := etcdRangeResponse{}
+	if err = json.NewDecoder(response.Body).Decode(&rangeResponse); err != nil {
+		return records, 0, err
+	}
+	revision := int64(0)
+	_, _ = fmt.Sscan(rangeResponse.Header.Revision, &revision)
+
+	for _, kv := range rangeResponse.Kvs {
+		rawKey, errKey := base64.StdEncoding.DecodeString(kv.Key)
+		rawValue, errValue := base64.StdEncoding.DecodeString(kv.Value)
+		if errKey != nil || errValue != nil {
+			e.logger.Error(fmt.Sprintf("failed to decode etcd key %s", kv.Key), "provider-type", e.GetType(), "provider-id", e.GetId())
+			continue
+		}
+		for _, record := range e.formatKeyToRecords(string(rawKey), rawValue) {
+			key := types.FormatRecordKey(record.Name, record.Type)
+			records[key] = append(records[key], record)
+		}
+	}
+	return records, revision, nil
+}
+
+func (e Etcd) formatKeyToRecords(key string, value []byte) []*types.Record {
+	records := []*types.Record{}
+	name := e.keyToName(key)
+	if name == "" {
+		return records
+	}
+
+	service := SkyDNSService{}
+	if err := json.Unmarshal(value, &service); err != nil {
+		e.logger.Error(fmt.Sprintf("failed to decode etcd value for key %s: %v", key, err), "provider-type", e.GetType(), "provider-id", e.GetId())
+		return records
+	}
+
+	if service.Host != "" {
+		target := fmt.Sprintf("%s.", strings.TrimSuffix(service.Host, "."))
+		if ip := net.ParseIP(service.Host); ip != nil {
+			typeRecord := "AAAA"
+			if ip.To4() != nil {
+				typeRecord = "A"
+			}
+			records = append(records, &types.Record{Name: name, Type: typeRecord, Value: service.Host})
+			target = fmt.Sprintf("%s.", name)
+		} else if service.Port == 0 {
+			records = append(records, &types.Record{Name: name, Type: "CNAME", Value: target})
+		}
+
+		if service.Port > 0 {
+			priority := service.Priority
+			if priority == 0 {
+				priority = 10
+			}
+			records = append(records, &types.Record{Name: name, Type: "SRV", Value: fmt.Sprintf("%d %d %d %s", priority, service.Weight, service.Port, target)})
+		}
+	}
+
+	if service.Text != "" {
+		records = append(records, &types.Record{Name: name, Type: "TXT", Value: fmt.Sprintf("%q", service.Text)})
+	}
+
+	for _, record := range records {
+		record.TTL = service.TTL
+	}
+	for _, parentName := range parentNames(name) {
+		for _, record := range records {
+			if record.Name == name && record.Type != "CNAME" {
+				records = append(records, &types.Record{Name: parentName, Type: record.Type, Value: record.Value, TTL: record.TTL})
+			}
+		}
+	}
+	return records
+}
+
+func parentNames(name string) []string {
+	names := []string{}
+	labels := strings.Split(name, ".")
+	for i := 1; i < len(labels)-1; i++ {
+		names = append(names, strings.Join(labels[i:], "."))
+	}
+	return names
+}
+
+func (e Etcd) keyToName(key string) string {
+	prefix := strings.TrimSuffix(e.cfg.Prefix, "/")
+	if !strings.HasPrefix(key, prefix+"/") {
+		return ""
+	}
+	labels := strings.Split(strings.Trim(strings.TrimPrefix(key, prefix), "/"), "/")
+	labels = slices.DeleteFunc(labels, func(label string) bool { return label == "" })
+	slices.Reverse(labels)
+	return strings.Join(labels, ".")
+}
+
+func (e Etcd) keyRange() (string, string) {
+	prefix := []byte(fmt.Sprintf("%s/", strings.TrimSuffix(e.cfg.Prefix, "/")))
+	rangeEnd := slices.Clone(prefix)
+	rangeEnd[len(rangeEnd)-1]++
+	return base64.StdEncoding.EncodeToString(prefix), base64.StdEncoding.EncodeToString(rangeEnd)
+}
+
+func (e Etcd) post(ctx stdContext.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	token := ""
+	if e.cfg.Username != "" {
+		token, err = e.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for _, endpoint := range e.cfg.Endpoints {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s%s", strings.TrimSuffix(endpoint, "/"), path), bytes.NewReader(payload))
+		if errReq != nil {
+			return nil, errReq
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		response, errDo := e.client.Do(req)
+		if errDo != nil {
+			lastErr = errDo
+			continue
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			lastErr = fmt.Errorf("request %s failed with status code %d", path, response.StatusCode)
+			continue
+		}
+		return response, nil
+	}
+	return nil, lastErr
+}
+
+func (e Etcd) authenticate(ctx stdContext.Context) (string, error) {
+	payload, err := json.Marshal(map[string]string{"name": e.cfg.Username, "password": e.cfg.Password})
+	if err != nil {
+		return "", err
+	}
+
+	var lastErr error
+	for _, endpoint := range e.cfg.Endpoints {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/v3/auth/authenticate", strings.TrimSuffix(endpoint, "/")), bytes.NewReader(payload))
+		if errReq != nil {
+			return "", errReq
+		}
+		response, errDo := e.client.Do(req)
+		if errDo != nil {
+			lastErr = errDo
+			continue
+		}
+		authResponse := struct {
+			Token string `json:"token"`
+		}{}
+		errDecode := json.NewDecoder(response.Body).Decode(&authResponse)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || errDecode != nil {
+			lastErr = fmt.Errorf("etcd authentication failed with status code %d", response.StatusCode)
+			continue
+		}
+		return authResponse.Token, nil
+	}
+	return "", lastErr
+}
+
+func createEtcdProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configEtcd{Endpoints: []string{"http://127.0.0.1:2379"}, Prefix: defaultEtcdPrefix}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &Etcd{
+		id:     id,
+		cfg:    instanceConfig,
+		client: &http.Client{},
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
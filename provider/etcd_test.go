@@ -0,0 +1,370 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type etcdTestServer struct {
+	mtx      sync.Mutex
+	revision int64
+	kvs      map[string]string
+	watchers []chan bool
+	token    string
+	fail     bool
+}
+
+func newEtcdTestServer() *etcdTestServer {
+	return &etcdTestServer{
+		revision: 5,
+		kvs: map[string]string{
+			"/skydns/local/foo/x1":   `{"host":"10.0.0.1","ttl":60}`,
+			"/skydns/local/foo/www":  `{"host":"foo.local"}`,
+			"/skydns/local/other":    `{"host":"fd00::1"}`,
+			"/skydns-other/local/no": `{"host":"10.0.0.9"}`,
+		},
+	}
+}
+
+func (s *etcdTestServer) put(key, value string) {
+	s.mtx.Lock()
+	s.kvs[key] = value
+	s.revision++
+	watchers := s.watchers
+	s.mtx.Unlock()
+	for _, watcher := range watchers {
+		watcher <- true
+	}
+}
+
+func (s *etcdTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	if s.fail {
+		s.mtx.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.URL.Path != "/v3/auth/authenticate" && s.token != "" && r.Header.Get("Authorization") != s.token {
+		s.mtx.Unlock()
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.mtx.Unlock()
+
+	body := map[string]interface{}{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	switch r.URL.Path {
+	case "/v3/auth/authenticate":
+		if body["name"] != "root" || body["password"] != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"token":"%s"}`, s.token)))
+	case "/v3/kv/range":
+		key, _ := base64.StdEncoding.DecodeString(body["key"].(string))
+		rangeEnd, _ := base64.StdEncoding.DecodeString(body["range_end"].(string))
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		keys := []string{}
+		for k := range s.kvs {
+			if k >= string(key) && k < string(rangeEnd) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		kvs := []map[string]string{}
+		for _, k := range keys {
+			kvs = append(kvs, map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(k)), "value": base64.StdEncoding.EncodeToString([]byte(s.kvs[k]))})
+		}
+		response, _ := json.Marshal(map[string]interface{}{"header": map[string]string{"revision": fmt.Sprintf("%d", s.revision)}, "kvs": kvs})
+		_, _ = w.Write(response)
+	case "/v3/watch":
+		watcher := make(chan bool, 10)
+		s.mtx.Lock()
+		s.watchers = append(s.watchers, watcher)
+		s.mtx.Unlock()
+		_, _ = w.Write([]byte(`{"result":{"created":true}}` + "\n"))
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-watcher:
+				_, _ = w.Write([]byte(`{"result":{"events":[{"type":"PUT","kv":{"key":"","value":""}}]}}` + "\n"))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_createEtcdProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name    string
+		cfg     config.Provider
+		want    configEtcd
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessDefault",
+			cfg:     config.Provider{},
+			want:    configEtcd{Endpoints: []string{"http://127.0.0.1:2379"}, Prefix: "/skydns"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessWithConfig",
+			cfg:     config.Provider{Config: map[string]interface{}{"endpoints": []string{"http://etcd1:2379", "http://etcd2:2379"}, "prefix": "/dns", "username": "root", "password": "secret"}},
+			want:    configEtcd{Endpoints: []string{"http://etcd1:2379", "http://etcd2:2379"}, Prefix: "/dns", Username: "root", Password: "secret"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"prefix": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"endpoints": []string{"wrong"}, "username": "root"}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createEtcdProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createEtcdProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got.(*Etcd).cfg)
+				assert.Equal(t, "provider", got.GetId())
+				assert.Equal(t, etcdKeyType, got.GetType())
+			}
+		})
+	}
+}
+
+func TestEtcd_keyToName(t *testing.T) {
+	e := Etcd{cfg: configEtcd{Prefix: "/skydns/"}}
+	assert.Equal(t, "x1.foo.local", e.keyToName("/skydns/local/foo/x1"))
+	assert.Equal(t, "foo.local", e.keyToName("/skydns/local//foo/"))
+	assert.Equal(t, "", e.keyToName("/skydns-other/local/foo"))
+}
+
+func Test_parentNames(t *testing.T) {
+	assert.Equal(t, []string{"foo.local"}, parentNames("x1.foo.local"))
+	assert.Equal(t, []string{"b.c.local", "c.local"}, parentNames("a.b.c.local"))
+	assert.Equal(t, []string{}, parentNames("foo.local"))
+	assert.Equal(t, []string{}, parentNames("local"))
+}
+
+func TestEtcd_keyRange(t *testing.T) {
+	e := Etcd{cfg: configEtcd{Prefix: "/skydns"}}
+	key, rangeEnd := e.keyRange()
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("/skydns/")), key)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("/skydns0")), rangeEnd)
+}
+
+func TestEtcd_formatKeyToRecords(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  []*types.Record
+	}{
+		{
+			name:  "SuccessA",
+			key:   "/skydns/local/foo/x1",
+			value: `{"host":"10.0.0.1","ttl":60}`,
+			want: []*types.Record{
+				{Name: "x1.foo.local", Type: "A", Value: "10.0.0.1", TTL: 60},
+				{Name: "foo.local", Type: "A", Value: "10.0.0.1", TTL: 60},
+			},
+		},
+		{
+			name:  "SuccessCoreDNSMultipleHosts",
+			key:   "/skydns/local/skydns/x2",
+			value: `{"host":"1.1.1.2","ttl":60}`,
+			want: []*types.Record{
+				{Name: "x2.skydns.local", Type: "A", Value: "1.1.1.2", TTL: 60},
+				{Name: "skydns.local", Type: "A", Value: "1.1.1.2", TTL: 60},
+			},
+		},
+		{
+			name:  "SuccessCoreDNSSubdomain",
+			key:   "/skydns/local/skydns/east/production/rails/1",
+			value: `{"host":"service1.example.com","port":8080,"ttl":60}`,
+			want: []*types.Record{
+				{Name: "1.rails.production.east.skydns.local", Type: "SRV", Value: "10 0 8080 service1.example.com.", TTL: 60},
+				{Name: "rails.production.east.skydns.local", Type: "SRV", Value: "10 0 8080 service1.example.com.", TTL: 60},
+				{Name: "production.east.skydns.local", Type: "SRV", Value: "10 0 8080 service1.example.com.", TTL: 60},
+				{Name: "east.skydns.local", Type: "SRV", Value: "10 0 8080 service1.example.com.", TTL: 60},
+				{Name: "skydns.local", Type: "SRV", Value: "10 0 8080 service1.example.com.", TTL: 60},
+			},
+		},
+		{
+			name:  "SuccessCoreDNSCNAME",
+			key:   "/skydns/local/skydns/x3",
+			value: `{"host":"bar.skydns.local","ttl":3600}`,
+			want:  []*types.Record{{Name: "x3.skydns.local", Type: "CNAME", Value: "bar.skydns.local.", TTL: 3600}},
+		},
+		{
+			name:  "SuccessCoreDNSTXT",
+			key:   "/skydns/local/skydns/x4",
+			value: `{"text":"this is a text","ttl":60}`,
+			want: []*types.Record{
+				{Name: "x4.skydns.local", Type: "TXT", Value: `"this is a text"`, TTL: 60},
+				{Name: "skydns.local", Type: "TXT", Value: `"this is a text"`, TTL: 60},
+			},
+		},
+		{
+			name:  "SuccessAAAAWithSRV",
+			key:   "/skydns/local/foo",
+			value: `{"host":"fd00::1","port":8080,"priority":20,"weight":5}`,
+			want: []*types.Record{
+				{Name: "foo.local", Type: "AAAA", Value: "fd00::1"},
+				{Name: "foo.local", Type: "SRV", Value: "20 5 8080 foo.local."},
+			},
+		},
+		{
+			name:  "SuccessCNAME",
+			key:   "/skydns/local/www",
+			value: `{"host":"foo.local"}`,
+			want:  []*types.Record{{Name: "www.local", Type: "CNAME", Value: "foo.local."}},
+		},
+		{
+			name:  "SuccessSRV",
+			key:   "/skydns/local/_http/_tcp",
+			value: `{"host":"foo.local.","port":80}`,
+			want: []*types.Record{
+				{Name: "_tcp._http.local", Type: "SRV", Value: "10 0 80 foo.local."},
+				{Name: "_http.local", Type: "SRV", Value: "10 0 80 foo.local."},
+			},
+		},
+		{
+			name:  "SuccessTXT",
+			key:   "/skydns/local/txt",
+			value: `{"text":"hello world"}`,
+			want:  []*types.Record{{Name: "txt.local", Type: "TXT", Value: `"hello world"`}},
+		},
+		{
+			name:  "SuccessOutsidePrefix",
+			key:   "/other/local/txt",
+			value: `{"text":"hello world"}`,
+			want:  []*types.Record{},
+		},
+		{
+			name:  "FailDecode",
+			key:   "/skydns/local/wrong",
+			value: `{`,
+			want:  []*types.Record{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Etcd{id: "provider", cfg: configEtcd{Prefix: "/skydns"}, logger: ctx.Logger}
+			assert.Equal(t, tt.want, e.formatKeyToRecords(tt.key, []byte(tt.value)))
+		})
+	}
+	assert.Contains(t, buffer.String(), "failed to decode etcd value for key /skydns/local/wrong")
+}
+
+func TestEtcd_fetchRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+	etcdServer := newEtcdTestServer()
+	etcdServer.token = "token"
+	server := httptest.NewServer(etcdServer)
+	defer server.Close()
+
+	e := Etcd{id: "provider", cfg: configEtcd{Endpoints: []string{"http://127.0.0.1:1", server.URL}, Prefix: "/skydns", Username: "root", Password: "secret"}, client: server.Client(), logger: ctx.Logger}
+	got, revision, err := e.fetchRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), revision)
+	assert.Equal(t, types.Records{
+		"x1.foo.local._A":      {{Name: "x1.foo.local", Type: "A", Value: "10.0.0.1", TTL: 60}},
+		"foo.local._A":         {{Name: "foo.local", Type: "A", Value: "10.0.0.1", TTL: 60}},
+		"www.foo.local._CNAME": {{Name: "www.foo.local", Type: "CNAME", Value: "foo.local."}},
+		"other.local._AAAA":    {{Name: "other.local", Type: "AAAA", Value: "fd00::1"}},
+	}, got)
+
+	e.cfg.Password = "wrong"
+	_, _, err = e.fetchRecords()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "etcd authentication failed")
+
+	e.cfg.Username = ""
+	_, _, err = e.fetchRecords()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed with status code 401")
+}
+
+func TestEtcd_Provide(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	etcdRetryTimeout = 10 * time.Millisecond
+	etcdServer := newEtcdTestServer()
+	server := httptest.NewServer(etcdServer)
+	defer server.Close()
+
+	e := Etcd{id: "provider", cfg: configEtcd{Endpoints: []string{server.URL}, Prefix: "/skydns"}, client: server.Client(), logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 40)
+	go func() {
+		assert.NoError(t, e.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Len(t, msg.Records, 4)
+
+	time.Sleep(100 * time.Millisecond)
+	etcdServer.put("/skydns/local/bar", `{"host":"10.0.0.2"}`)
+	select {
+	case msg = <-configurationChan:
+		assert.Len(t, msg.Records, 5)
+		assert.Equal(t, []*types.Record{{Name: "bar.local", Type: "A", Value: "10.0.0.2"}}, msg.Records["bar.local._A"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after etcd update")
+	}
+
+	etcdServer.mtx.Lock()
+	etcdServer.kvs["/skydns/local/baz"] = `{"host":"10.0.0.3"}`
+	etcdServer.revision++
+	etcdServer.mtx.Unlock()
+	server.CloseClientConnections()
+	select {
+	case msg = <-configurationChan:
+		assert.Len(t, msg.Records, 6)
+		assert.Equal(t, []*types.Record{{Name: "baz.local", Type: "A", Value: "10.0.0.3"}}, msg.Records["baz.local._A"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after etcd watch restart")
+	}
+	assert.Contains(t, buffer.String(), "error when watch etcd prefix")
+	ctx.Cancel()
+}
+
+func TestEtcd_Provide_Fail(t *testing.T) {
+	ctx := context.TestContext(nil)
+	etcdServer := newEtcdTestServer()
+	etcdServer.fail = true
+	server := httptest.NewServer(etcdServer)
+	defer server.Close()
+
+	e := Etcd{id: "provider", cfg: configEtcd{Endpoints: []string{server.URL}, Prefix: "/skydns"}, client: server.Client(), logger: ctx.Logger, done: ctx.Done()}
+	err := e.Provide(make(chan types.Message, 1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/miekg/dns"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/afero"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[hostsKeyType] = createHostsProvider
+}
+
+const (
+	hostsKeyType = "hosts"
+)
+
+var (
+	_ types.Provider = &Hosts{}
+)
+
+type configHosts struct {
+	Path     string `mapstructure:"path" validate:"required"`
+	Ptr      bool   `mapstructure:"ptr"`
+	Watch    bool   `mapstructure:"watch"`
+	Interval int64  `mapstructure:"interval" validate:"required_if=Watch true,gte=0"`
+}
+
+type Hosts struct {
+	id     string
+	fs     afero.Fs
+	cfg    configHosts
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (h Hosts) GetId() string {
+	return h.id
+}
+
+func (h Hosts) GetType() string {
+	return hostsKeyType
+}
+
+func (h Hosts) Provide(configurationChan chan<- types.Message) error {
+	info, err := h.fs.Stat(h.cfg.Path)
+	if err != nil {
+		return err
+	}
+	records, err := h.readFile()
+	if err != nil {
+		return err
+	}
+	configurationChan <- types.Message{Provider: h, Records: records}
+
+	if !h.cfg.Watch {
+		return nil
+	}
+
+	modTime, size := info.ModTime(), info.Size()
+	ticker := time.NewTicker(time.Duration(h.cfg.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			info, err = h.fs.Stat(h.cfg.Path)
+			if err != nil {
+				h.logger.Error(fmt.Sprintf("error when stat %s: %s", h.cfg.Path, err.Error()), "provider-type", h.GetType(), "provider-id", h.GetId())
+				continue
+			}
+			if info.ModTime().Equal(modTime) && info.Size() == size {
+				continue
+			}
+			modTime, size = info.ModTime(), info.Size()
+
+			h.logger.Debug(fmt.Sprintf("%s updated", h.cfg.Path), "provider-type", h.GetType(), "provider-id", h.GetId())
+			records, err = h.readFile()
+			if err != nil {
+				h.logger.Error(fmt.Sprintf("error when read %s: %s", h.cfg.Path, err.Error()), "provider-type", h.GetType(), "provider-id", h.GetId())
+				continue
+			}
+			configurationChan <- types.Message{Provider: h, Records: records}
+		case <-h.done:
+			return nil
+		}
+	}
+}
+
+func (h Hosts) readFile() (types.Records, error) {
+	content, err := afero.ReadFile(h.fs, h.cfg.Path)
+	if err != nil {
+		return types.Records{}, err
+	}
+	return h.parseHosts(content), nil
+}
+
+func (h Hosts) parseHosts(content []byte) types.Records {
+	records := types.Records{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			h.logger.Error(fmt.Sprintf("no hostname defined in %s line %d", h.cfg.Path, lineNumber), "provider-type", h.GetType(), "provider-id", h.GetId())
+			continue
+		}
+
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			h.logger.Error(fmt.Sprintf("invalid address '%s' in %s line %d", fields[0], h.cfg.Path, lineNumber), "provider-type", h.GetType(), "provider-id", h.GetId())
+			continue
+		}
+		typeRecord := "AAAA"
+		if ip.To4() != nil {
+			typeRecord = "A"
+		}
+
+		for _, name := range fields[1:] {
+			name = strings.TrimSuffix(strings.ToLower(name), ".")
+			addHostsRecord(records, &types.Record{Name: name, Type: typeRecord, Value: ip.String()})
+		}
+
+		if h.cfg.Ptr {
+			reverse, _ := dns.ReverseAddr(ip.String())
+			key := types.FormatRecordKey(reverse, "PTR")
+			if _, ok := records[key]; !ok {
+				records[key] = []*types.Record{{Name: strings.TrimSuffix(reverse, "."), Type: "PTR", Value: dns.Fqdn(strings.ToLower(fields[1]))}}
+			}
+		}
+	}
+	return records
+}
+
+func addHostsRecord(records types.Records, record *types.Record) {
+	key := types.FormatRecordKey(record.Name, record.Type)
+	if slices.ContainsFunc(records[key], func(r *types.Record) bool { return r.Value == record.Value }) {
+		return
+	}
+	records[key] = append(records[key], record)
+}
+
+func createHostsProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configHosts{Path: "/etc/hosts", Interval: 5}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &Hosts{
+		id:     id,
+		fs:     ctx.FS,
+		cfg:    instanceConfig,
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_createHostsProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name    string
+		cfg     config.Provider
+		want    configHosts
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessDefault",
+			cfg:     config.Provider{},
+			want:    configHosts{Path: "/etc/hosts", Interval: 5},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessWithConfig",
+			cfg:     config.Provider{Config: map[string]interface{}{"path": "/app/hosts", "ptr": true, "watch": true, "interval": 1}},
+			want:    configHosts{Path: "/app/hosts", Ptr: true, Watch: true, Interval: 1},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"path": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"watch": true, "interval": 0}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createHostsProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createHostsProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got.(*Hosts).cfg)
+				assert.Equal(t, "provider", got.GetId())
+				assert.Equal(t, hostsKeyType, got.GetType())
+			}
+		})
+	}
+}
+
+func TestHosts_parseHosts(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	content := []byte(`# comment
+127.0.0.1	localhost
+10.0.0.1 Foo.local foo # inline comment
+10.0.0.1 bar.local
+10.0.0.2 foo.local.
+fd00::1  foo.local
+
+wrong    wrong.local
+10.0.0.3
+`)
+
+	tests := []struct {
+		name string
+		ptr  bool
+		want types.Records
+	}{
+		{
+			name: "Success",
+			want: types.Records{
+				"localhost._A":    {{Name: "localhost", Type: "A", Value: "127.0.0.1"}},
+				"foo.local._A":    {{Name: "foo.local", Type: "A", Value: "10.0.0.1"}, {Name: "foo.local", Type: "A", Value: "10.0.0.2"}},
+				"foo._A":          {{Name: "foo", Type: "A", Value: "10.0.0.1"}},
+				"bar.local._A":    {{Name: "bar.local", Type: "A", Value: "10.0.0.1"}},
+				"foo.local._AAAA": {{Name: "foo.local", Type: "AAAA", Value: "fd00::1"}},
+			},
+		},
+		{
+			name: "SuccessWithPtr",
+			ptr:  true,
+			want: types.Records{
+				"localhost._A":                {{Name: "localhost", Type: "A", Value: "127.0.0.1"}},
+				"foo.local._A":                {{Name: "foo.local", Type: "A", Value: "10.0.0.1"}, {Name: "foo.local", Type: "A", Value: "10.0.0.2"}},
+				"foo._A":                      {{Name: "foo", Type: "A", Value: "10.0.0.1"}},
+				"bar.local._A":                {{Name: "bar.local", Type: "A", Value: "10.0.0.1"}},
+				"foo.local._AAAA":             {{Name: "foo.local", Type: "AAAA", Value: "fd00::1"}},
+				"1.0.0.127.in-addr.arpa._PTR": {{Name: "1.0.0.127.in-addr.arpa", Type: "PTR", Value: "localhost."}},
+				"1.0.0.10.in-addr.arpa._PTR":  {{Name: "1.0.0.10.in-addr.arpa", Type: "PTR", Value: "foo.local."}},
+				"2.0.0.10.in-addr.arpa._PTR":  {{Name: "2.0.0.10.in-addr.arpa", Type: "PTR", Value: "foo.local."}},
+				"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa._PTR": {{Name: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa", Type: "PTR", Value: "foo.local."}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Hosts{id: "provider", cfg: configHosts{Path: "/etc/hosts", Ptr: tt.ptr}, logger: ctx.Logger}
+			assert.Equal(t, tt.want, h.parseHosts(content))
+		})
+	}
+	assert.Contains(t, buffer.String(), "invalid address 'wrong' in /etc/hosts line 8")
+	assert.Contains(t, buffer.String(), "no hostname defined in /etc/hosts line 9")
+}
+
+func TestHosts_Provide(t *testing.T) {
+	ctx := context.TestContext(nil)
+	_ = afero.WriteFile(ctx.FS, "/etc/hosts", []byte("10.0.0.1 foo.local"), 0644)
+
+	h := Hosts{id: "provider", fs: ctx.FS, cfg: configHosts{Path: "/etc/hosts"}, logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 1)
+	assert.NoError(t, h.Provide(configurationChan))
+	msg := <-configurationChan
+	assert.Equal(t, types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "10.0.0.1"}}}, msg.Records)
+}
+
+func TestHosts_Provide_Watch(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	_ = afero.WriteFile(ctx.FS, "/etc/hosts", []byte("10.0.0.1 foo.local"), 0644)
+
+	h := Hosts{id: "provider", fs: ctx.FS, cfg: configHosts{Path: "/etc/hosts", Watch: true, Interval: 1}, logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 10)
+	go func() {
+		assert.NoError(t, h.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Len(t, msg.Records, 1)
+
+	_ = afero.WriteFile(ctx.FS, "/etc/hosts", []byte("10.0.0.1 foo.local\n10.0.0.2 bar.local"), 0644)
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, types.Records{
+			"foo.local._A": {{Name: "foo.local", Type: "A", Value: "10.0.0.1"}},
+			"bar.local._A": {{Name: "bar.local", Type: "A", Value: "10.0.0.2"}},
+		}, msg.Records)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after hosts update")
+	}
+
+	_ = ctx.FS.Remove("/etc/hosts")
+	time.Sleep(1200 * time.Millisecond)
+	assert.Contains(t, buffer.String(), "error when stat /etc/hosts")
+	ctx.Cancel()
+}
+
+func TestHosts_Provide_Fail(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	h := Hosts{id: "provider", fs: ctx.FS, cfg: configHosts{Path: "/etc/hosts"}, logger: ctx.Logger, done: ctx.Done()}
+	assert.Error(t, h.Provide(make(chan types.Message, 1)))
+}
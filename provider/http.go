@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[httpKeyType] = createHttpProvider
+}
+
+const (
+	httpKeyType    = "http"
+	httpFormatJson = "json"
+	httpFormatYaml = "yaml"
+)
+
+var (
+	_ types.Provider = &Http{}
+)
+
+type configHttp struct {
+	URL           string            `mapstructure:"url" validate:"required,http_url"`
+	Interval      int64             `mapstructure:"interval" validate:"gt=0"`
+	Timeout       int64             `mapstructure:"timeout" validate:"gt=0"`
+	Format        string            `mapstructure:"format" validate:"omitempty,oneof=yaml json"`
+	Headers       map[string]string `mapstructure:"headers"`
+	Username      string            `mapstructure:"username"`
+	Password      string            `mapstructure:"password"`
+	Token         string            `mapstructure:"token" validate:"excluded_with=Username"`
+	TlsSkipVerify bool              `mapstructure:"tls_skip_verify"`
+}
+
+type httpCacheState struct {
+	etag         string
+	lastModified string
+}
+
+type Http struct {
+	id     string
+	cfg    configHttp
+	client *http.Client
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (h Http) GetId() string {
+	return h.id
+}
+
+func (h Http) GetType() string {
+	return httpKeyType
+}
+
+func (h Http) Provide(configurationChan chan<- types.Message) error {
+	state := &httpCacheState{}
+	ticker := time.NewTicker(time.Duration(h.cfg.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		records, modified, err := h.fetchRecords(state)
+		if err != nil {
+			h.logger.Error(fmt.Sprintf("error when fetch %s, keep last records: %s", h.cfg.URL, err.Error()), "provider-type", h.GetType(), "provider-id", h.GetId())
+		} else if modified {
+			configurationChan <- types.Message{Provider: h, Records: records}
+		} else {
+			h.logger.Debug(fmt.Sprintf("%s not modified", h.cfg.URL), "provider-type", h.GetType(), "provider-id", h.GetId())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-h.done:
+			return nil
+		}
+	}
+}
+
+func (h Http) fetchRecords(state *httpCacheState) (types.Records, bool, error) {
+	records := types.Records{}
+	req, err := http.NewRequest(http.MethodGet, h.cfg.URL, nil)
+	if err != nil {
+		return records, false, err
+	}
+	for key, value := range h.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	if h.cfg.Username != "" {
+		req.SetBasicAuth(h.cfg.Username, h.cfg.Password)
+	}
+	if h.cfg.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", h.cfg.Token))
+	}
+	if state.etag != "" {
+		req.Header.Set("If-None-Match", state.etag)
+	}
+	if state.lastModified != "" {
+		req.Header.Set("If-Modified-Since", state.lastModified)
+	}
+
+	response, err := h.client.Do(req)
+	if err != nil {
+		return records, false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified {
+		return records, false, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return records, false, fmt.Errorf("request failed with status code %d", response.StatusCode)
+	}
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return records, false, err
+	}
+
+	format := h.cfg.Format
+	if format == "" {
+		format = httpFormatYaml
+		if strings.Contains(response.Header.Get("Content-Type"), "json") {
+			format = httpFormatJson
+		}
+	}
+
+	switch format {
+	case httpFormatJson:
+		list := []*types.Record{}
+		if err = json.Unmarshal(content, &list); err != nil {
+			return records, false, err
+		}
+		for _, record := range list {
+			key := types.FormatRecordKey(record.Name, record.Type)
+			records[key] = append(records[key], record)
+		}
+	default:
+		if err = yaml.Unmarshal(content, &records); err != nil {
+			return records, false, err
+		}
+	}
+
+	state.etag = response.Header.Get("ETag")
+	state.lastModified = response.Header.Get("Last-Modified")
+	return records, true, nil
+}
+
+func createHttpProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configHttp{Interval: 60, Timeout: 10}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: instanceConfig.TlsSkipVerify}
+
+	instance := &Http{
+		id:     id,
+		cfg:    instanceConfig,
+		client: &http.Client{Timeout: time.Duration(instanceConfig.Timeout) * time.Second, Transport: transport},
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
@@ -0,0 +1,251 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type httpTestServer struct {
+	mtx         sync.Mutex
+	contentType string
+	body        string
+	etag        string
+	status      int
+	requests    []*http.Request
+}
+
+func (s *httpTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests = append(s.requests, r)
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", s.contentType)
+	w.Header().Set("ETag", s.etag)
+	w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	_, _ = w.Write([]byte(s.body))
+}
+
+func (s *httpTestServer) update(fn func()) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	fn()
+}
+
+func Test_createHttpProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name    string
+		cfg     config.Provider
+		want    configHttp
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessDefault",
+			cfg:     config.Provider{Config: map[string]interface{}{"url": "https://cmdb.local/records.yml"}},
+			want:    configHttp{URL: "https://cmdb.local/records.yml", Interval: 60, Timeout: 10},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessWithConfig",
+			cfg:     config.Provider{Config: map[string]interface{}{"url": "https://cmdb.local/records", "interval": 10, "timeout": 2, "format": "json", "headers": map[string]string{"X-Env": "prod"}, "username": "user", "password": "secret", "tls_skip_verify": true}},
+			want:    configHttp{URL: "https://cmdb.local/records", Interval: 10, Timeout: 2, Format: "json", Headers: map[string]string{"X-Env": "prod"}, Username: "user", Password: "secret", TlsSkipVerify: true},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"url": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"url": "ftp://cmdb.local", "format": "xml"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidateAuth",
+			cfg:     config.Provider{Config: map[string]interface{}{"url": "https://cmdb.local", "username": "user", "token": "token"}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createHttpProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createHttpProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got.(*Http).cfg)
+				assert.Equal(t, "provider", got.GetId())
+				assert.Equal(t, httpKeyType, got.GetType())
+			}
+		})
+	}
+}
+
+func TestHttp_fetchRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name         string
+		cfg          configHttp
+		server       *httpTestServer
+		state        *httpCacheState
+		want         types.Records
+		wantModified bool
+		wantState    *httpCacheState
+		wantErr      assert.ErrorAssertionFunc
+	}{
+		{
+			name:         "SuccessYaml",
+			server:       &httpTestServer{contentType: "application/yaml", etag: `"v1"`, body: "[{name: foo.local, type: A, value: 127.0.0.1, ttl: 60}]"},
+			state:        &httpCacheState{},
+			want:         types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1", TTL: 60}}},
+			wantModified: true,
+			wantState:    &httpCacheState{etag: `"v1"`, lastModified: "Mon, 02 Jan 2006 15:04:05 GMT"},
+			wantErr:      assert.NoError,
+		},
+		{
+			name:         "SuccessJson",
+			server:       &httpTestServer{contentType: "application/json", body: `[{"name":"foo.local","type":"A","value":"127.0.0.1"},{"name":"foo.local","type":"A","value":"127.0.0.2"}]`},
+			state:        &httpCacheState{},
+			want:         types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}, {Name: "foo.local", Type: "A", Value: "127.0.0.2"}}},
+			wantModified: true,
+			wantState:    &httpCacheState{lastModified: "Mon, 02 Jan 2006 15:04:05 GMT"},
+			wantErr:      assert.NoError,
+		},
+		{
+			name:         "SuccessForceFormat",
+			cfg:          configHttp{Format: "yaml"},
+			server:       &httpTestServer{contentType: "application/json", body: `[{"name":"foo.local","type":"A","value":"127.0.0.1"}]`},
+			state:        &httpCacheState{},
+			want:         types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}},
+			wantModified: true,
+			wantState:    &httpCacheState{lastModified: "Mon, 02 Jan 2006 15:04:05 GMT"},
+			wantErr:      assert.NoError,
+		},
+		{
+			name:         "SuccessNotModified",
+			server:       &httpTestServer{contentType: "application/json", etag: `"v1"`, body: `[]`},
+			state:        &httpCacheState{etag: `"v1"`},
+			want:         types.Records{},
+			wantModified: false,
+			wantState:    &httpCacheState{etag: `"v1"`},
+			wantErr:      assert.NoError,
+		},
+		{
+			name:      "FailStatus",
+			server:    &httpTestServer{status: http.StatusInternalServerError},
+			state:     &httpCacheState{},
+			want:      types.Records{},
+			wantState: &httpCacheState{},
+			wantErr:   assert.Error,
+		},
+		{
+			name:      "FailDecodeJson",
+			server:    &httpTestServer{contentType: "application/json", body: `{`},
+			state:     &httpCacheState{},
+			want:      types.Records{},
+			wantState: &httpCacheState{},
+			wantErr:   assert.Error,
+		},
+		{
+			name:      "FailDecodeYaml",
+			server:    &httpTestServer{contentType: "text/plain", body: `wrong`},
+			state:     &httpCacheState{},
+			want:      types.Records{},
+			wantState: &httpCacheState{},
+			wantErr:   assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.server)
+			defer server.Close()
+			tt.cfg.URL = server.URL
+			h := Http{id: "provider", cfg: tt.cfg, client: server.Client(), logger: ctx.Logger}
+			got, modified, err := h.fetchRecords(tt.state)
+			assert.Equal(t, tt.wantState, tt.state)
+			if !tt.wantErr(t, err, "fetchRecords()") {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantModified, modified)
+		})
+	}
+}
+
+func TestHttp_fetchRecords_Auth(t *testing.T) {
+	ctx := context.TestContext(nil)
+	testServer := &httpTestServer{contentType: "application/json", body: `[]`}
+	server := httptest.NewServer(testServer)
+	defer server.Close()
+
+	h := Http{id: "provider", cfg: configHttp{URL: server.URL, Headers: map[string]string{"X-Env": "prod"}, Username: "user", Password: "secret"}, client: server.Client(), logger: ctx.Logger}
+	_, _, err := h.fetchRecords(&httpCacheState{lastModified: "Mon, 02 Jan 2006 15:04:05 GMT"})
+	assert.NoError(t, err)
+	username, password, ok := testServer.requests[0].BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "secret", password)
+	assert.Equal(t, "prod", testServer.requests[0].Header.Get("X-Env"))
+	assert.Equal(t, "Mon, 02 Jan 2006 15:04:05 GMT", testServer.requests[0].Header.Get("If-Modified-Since"))
+
+	h.cfg = configHttp{URL: server.URL, Token: "token"}
+	_, _, err = h.fetchRecords(&httpCacheState{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token", testServer.requests[1].Header.Get("Authorization"))
+}
+
+func TestHttp_Provide(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	testServer := &httpTestServer{contentType: "application/yaml", etag: `"v1"`, body: "[{name: foo.local, type: A, value: 127.0.0.1}]"}
+	server := httptest.NewServer(testServer)
+	defer server.Close()
+
+	h := Http{id: "provider", cfg: configHttp{URL: server.URL, Interval: 1}, client: server.Client(), logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 10)
+	go func() {
+		assert.NoError(t, h.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Equal(t, types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}, msg.Records)
+
+	testServer.update(func() {
+		testServer.status = http.StatusBadGateway
+	})
+	time.Sleep(1200 * time.Millisecond)
+	assert.Contains(t, buffer.String(), "keep last records")
+	assert.Len(t, configurationChan, 0)
+
+	testServer.update(func() {
+		testServer.status = 0
+		testServer.etag = `"v2"`
+		testServer.body = "[{name: foo.local, type: A, value: 127.0.0.2}]"
+	})
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.2"}}}, msg.Records)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after http update")
+	}
+	ctx.Cancel()
+}
@@ -0,0 +1,399 @@
+package provider
+
+import (
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"github.com/traefik/paerser/parser"
+	coreV1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"log/slog"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[kubernetesKeyType] = createKubernetesProvider
+}
+
+const (
+	kubernetesKeyType            = "kubernetes"
+	kubernetesAnnotationPrefix   = "godnsd.io/"
+	defaultKubernetesDomain      = "cluster.local"
+	defaultKubernetesPodLabel    = kubernetesAnnotationPrefix + "enable=true"
+	defaultKubernetesSyncTimeout = 60
+)
+
+var (
+	_                  types.Provider = &Kubernetes{}
+	kubernetesClientFn                = func(kubeconfig string) (kubernetes.Interface, error) {
+		restConfig, err := kubernetesRestConfig(kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		return kubernetes.NewForConfig(restConfig)
+	}
+)
+
+type configKubernetes struct {
+	Kubeconfig       string   `mapstructure:"kubeconfig"`
+	Domain           string   `mapstructure:"domain" validate:"required"`
+	Namespaces       []string `mapstructure:"namespaces"`
+	LabelSelector    string   `mapstructure:"label_selector"`
+	PodLabelSelector string   `mapstructure:"pod_label_selector"`
+	SyncTimeout      int64    `mapstructure:"sync_timeout" validate:"gt=0"`
+}
+
+type kubernetesListers struct {
+	services  func() ([]*coreV1.Service, error)
+	ingresses func() ([]*networkingV1.Ingress, error)
+	pods      func() ([]*coreV1.Pod, error)
+}
+
+type Kubernetes struct {
+	id     string
+	cfg    configKubernetes
+	client kubernetes.Interface
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (k Kubernetes) GetId() string {
+	return k.id
+}
+
+func (k Kubernetes) GetType() string {
+	return kubernetesKeyType
+}
+
+func (k Kubernetes) Provide(configurationChan chan<- types.Message) error {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	notify := make(chan bool, 1)
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(_ interface{}) { k.notify(notify) },
+		UpdateFunc: func(_, _ interface{}) { k.notify(notify) },
+		DeleteFunc: func(_ interface{}) { k.notify(notify) },
+	}
+
+	listers := []kubernetesListers{}
+	for _, namespace := range k.namespaces() {
+		factory := k.informerFactory(namespace, k.cfg.LabelSelector)
+		podsFactory := k.informerFactory(namespace, k.podLabelSelector())
+		servicesInformer := factory.Core().V1().Services()
+		ingressesInformer := factory.Networking().V1().Ingresses()
+		podsInformer := podsFactory.Core().V1().Pods()
+		for _, informer := range []cache.SharedIndexInformer{servicesInformer.Informer(), ingressesInformer.Informer(), podsInformer.Informer()} {
+			if _, err := informer.AddEventHandler(handler); err != nil {
+				return err
+			}
+		}
+		listers = append(listers, kubernetesListers{
+			services:  func() ([]*coreV1.Service, error) { return servicesInformer.Lister().List(labels.Everything()) },
+			ingresses: func() ([]*networkingV1.Ingress, error) { return ingressesInformer.Lister().List(labels.Everything()) },
+			pods:      func() ([]*coreV1.Pod, error) { return podsInformer.Lister().List(labels.Everything()) },
+		})
+
+		for _, informerFactory := range []informers.SharedInformerFactory{factory, podsFactory} {
+			informerFactory.Start(stopCh)
+			informersSynced, done := k.waitForCacheSync(informerFactory)
+			if done {
+				return nil
+			}
+			for informerType, synced := range informersSynced {
+				if !synced {
+					return fmt.Errorf("failed to sync kubernetes informer %s in namespace '%s' within %ds", informerType.String(), namespace, k.cfg.SyncTimeout)
+				}
+			}
+		}
+	}
+
+	select {
+	case <-notify:
+	default:
+	}
+	configurationChan <- types.Message{Provider: k, Records: k.fetchRecords(listers)}
+	for {
+		select {
+		case <-notify:
+			k.logger.Debug("kubernetes resources updated", "provider-type", k.GetType(), "provider-id", k.GetId())
+			configurationChan <- types.Message{Provider: k, Records: k.fetchRecords(listers)}
+		case <-k.done:
+			return nil
+		}
+	}
+}
+
+func (k Kubernetes) waitForCacheSync(informerFactory informers.SharedInformerFactory) (map[reflect.Type]bool, bool) {
+	syncCh := make(chan struct{})
+	syncedCh := make(chan struct{})
+	done := make(chan bool, 1)
+	timer := time.NewTimer(time.Duration(k.cfg.SyncTimeout) * time.Second)
+	defer timer.Stop()
+	go func() {
+		defer close(syncCh)
+		select {
+		case <-timer.C:
+		case <-k.done:
+			done <- true
+		case <-syncedCh:
+		}
+	}()
+	informersSynced := informerFactory.WaitForCacheSync(syncCh)
+	close(syncedCh)
+	<-syncCh
+	return informersSynced, len(done) > 0
+}
+
+func (k Kubernetes) notify(notify chan bool) {
+	select {
+	case notify <- true:
+	default:
+	}
+}
+
+func (k Kubernetes) informerFactory(namespace string, labelSelector string) informers.SharedInformerFactory {
+	return informers.NewSharedInformerFactoryWithOptions(
+		k.client,
+		0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metaV1.ListOptions) {
+			options.LabelSelector = labelSelector
+		}),
+	)
+}
+
+func (k Kubernetes) podLabelSelector() string {
+	selectors := []string{}
+	for _, selector := range []string{k.cfg.LabelSelector, k.cfg.PodLabelSelector} {
+		if selector != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+	return strings.Join(selectors, ",")
+}
+
+func (k Kubernetes) namespaces() []string {
+	if len(k.cfg.Namespaces) == 0 {
+		return []string{metaV1.NamespaceAll}
+	}
+	return k.cfg.Namespaces
+}
+
+func (k Kubernetes) fetchRecords(listers []kubernetesListers) types.Records {
+	records := types.Records{}
+	for _, lister := range listers {
+		services, err := lister.services()
+		if err != nil {
+			k.logger.Error(fmt.Sprintf("failed to list kubernetes services: %v", err), "provider-type", k.GetType(), "provider-id", k.GetId())
+		}
+		for _, service := range services {
+			k.appendRecords(records, k.formatServiceToRecords(service))
+		}
+
+		ingresses, err := lister.ingresses()
+		if err != nil {
+			k.logger.Error(fmt.Sprintf("failed to list kubernetes ingresses: %v", err), "provider-type", k.GetType(), "provider-id", k.GetId())
+		}
+		for _, ingress := range ingresses {
+			k.appendRecords(records, k.formatIngressToRecords(ingress))
+		}
+
+		pods, err := lister.pods()
+		if err != nil {
+			k.logger.Error(fmt.Sprintf("failed to list kubernetes pods: %v", err), "provider-type", k.GetType(), "provider-id", k.GetId())
+		}
+		for _, pod := range pods {
+			k.appendRecords(records, k.formatPodToRecords(pod))
+		}
+	}
+	return records
+}
+
+func (k Kubernetes) appendRecords(records types.Records, recordsToAdd []*types.Record) {
+	for _, record := range recordsToAdd {
+		key := types.FormatRecordKey(record.Name, record.Type)
+		if _, ok := records[key]; !ok {
+			records[key] = []*types.Record{}
+		}
+		records[key] = append(records[key], record)
+	}
+}
+
+func (k Kubernetes) formatServiceToRecords(service *coreV1.Service) []*types.Record {
+	records := []*types.Record{}
+	name := fmt.Sprintf("%s.%s.%s", service.Name, service.Namespace, strings.Trim(k.cfg.Domain, "."))
+
+	if service.Spec.Type == coreV1.ServiceTypeExternalName {
+		if service.Spec.ExternalName != "" {
+			records = append(records, &types.Record{Name: name, Type: "CNAME", Value: fmt.Sprintf("%s.", strings.TrimSuffix(service.Spec.ExternalName, "."))})
+		}
+		return records
+	}
+
+	ips := []string{}
+	for _, clusterIP := range append([]string{service.Spec.ClusterIP}, service.Spec.ClusterIPs...) {
+		if clusterIP != "" && clusterIP != coreV1.ClusterIPNone {
+			ips = append(ips, clusterIP)
+		}
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			ips = append(ips, ingress.IP)
+		}
+	}
+
+	return append(records, formatIpsToRecords(name, ips)...)
+}
+
+func (k Kubernetes) formatIngressToRecords(ingress *networkingV1.Ingress) []*types.Record {
+	records := []*types.Record{}
+
+	ips, hostnames := []string{}, []string{}
+	for _, lbIngress := range ingress.Status.LoadBalancer.Ingress {
+		if lbIngress.IP != "" {
+			ips = append(ips, lbIngress.IP)
+		} else if lbIngress.Hostname != "" {
+			hostnames = append(hostnames, lbIngress.Hostname)
+		}
+	}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host == "" {
+			continue
+		}
+		if len(ips) > 0 {
+			records = append(records, formatIpsToRecords(rule.Host, ips)...)
+		} else if len(hostnames) > 0 {
+			records = append(records, &types.Record{Name: rule.Host, Type: "CNAME", Value: fmt.Sprintf("%s.", strings.TrimSuffix(hostnames[0], "."))})
+		}
+	}
+	return records
+}
+
+func (k Kubernetes) formatPodToRecords(pod *coreV1.Pod) []*types.Record {
+	records := []*types.Record{}
+
+	annotations := map[string]string{}
+	for key, value := range pod.Annotations {
+		if strings.HasPrefix(key, kubernetesAnnotationPrefix) {
+			annotations[fmt.Sprintf("%s.%s", types.AppName, strings.TrimPrefix(key, kubernetesAnnotationPrefix))] = value
+		}
+	}
+	if len(annotations) == 0 {
+		return records
+	}
+
+	recordsPod := &ConfigContainer{}
+	err := parser.Decode(annotations, recordsPod, types.AppName, types.AppName)
+	if err != nil {
+		k.logger.Error(fmt.Sprintf("failed to decode annotations for kubernetes pod %s/%s", pod.Namespace, pod.Name), "provider-type", k.GetType(), "provider-id", k.GetId())
+		return records
+	}
+
+	for key, recordPod := range recordsPod.Records {
+		record := &types.Record{Name: recordPod.Name, Type: recordPod.Type, Value: recordPod.Value}
+		if recordPod.Value == "" && (recordPod.Type == "A" || recordPod.Type == "AAAA") {
+			record.Value = findPodIp(pod, recordPod.Type)
+			if record.Value == "" {
+				k.logger.Debug(fmt.Sprintf("no ip found for kubernetes pod %s/%s, annotation %s", pod.Namespace, pod.Name, key), "provider-type", k.GetType(), "provider-id", k.GetId())
+				continue
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func findPodIp(pod *coreV1.Pod, typeRecord string) string {
+	ips := []string{pod.Status.PodIP}
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+	for _, ip := range ips {
+		parsedIp := net.ParseIP(ip)
+		if parsedIp == nil {
+			continue
+		}
+		if (parsedIp.To4() != nil) == (typeRecord == "A") {
+			return ip
+		}
+	}
+	return ""
+}
+
+func formatIpsToRecords(name string, ips []string) []*types.Record {
+	records := []*types.Record{}
+	seen := map[string]bool{}
+	for _, ip := range ips {
+		parsedIp := net.ParseIP(ip)
+		if parsedIp == nil || seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		typeRecord := "AAAA"
+		if parsedIp.To4() != nil {
+			typeRecord = "A"
+		}
+		records = append(records, &types.Record{Name: name, Type: typeRecord, Value: ip})
+	}
+	return records
+}
+
+func kubernetesRestConfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig == "" {
+		if restConfig, err := rest.InClusterConfig(); err == nil {
+			return restConfig, nil
+		}
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+}
+
+func createKubernetesProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configKubernetes{Domain: defaultKubernetesDomain, PodLabelSelector: defaultKubernetesPodLabel, SyncTimeout: defaultKubernetesSyncTimeout}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, selector := range []string{instanceConfig.LabelSelector, instanceConfig.PodLabelSelector} {
+		if _, err = labels.Parse(selector); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := kubernetesClientFn(instanceConfig.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &Kubernetes{
+		id:     id,
+		cfg:    instanceConfig,
+		client: client,
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
@@ -0,0 +1,353 @@
+package provider
+
+import (
+	stdContext "context"
+	"errors"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8sTesting "k8s.io/client-go/testing"
+	"testing"
+	"time"
+)
+
+func Test_createKubernetesProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+	client := fake.NewSimpleClientset()
+
+	tests := []struct {
+		name     string
+		cfg      config.Provider
+		clientFn func(kubeconfig string) (kubernetes.Interface, error)
+		want     types.Provider
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{
+			name: "SuccessDefault",
+			cfg:  config.Provider{},
+			clientFn: func(kubeconfig string) (kubernetes.Interface, error) {
+				return client, nil
+			},
+			want:    &Kubernetes{id: "provider", cfg: configKubernetes{Domain: "cluster.local", PodLabelSelector: "godnsd.io/enable=true", SyncTimeout: 60}, client: client, logger: ctx.Logger, done: ctx.Done()},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "SuccessWithConfig",
+			cfg:  config.Provider{Config: map[string]interface{}{"kubeconfig": "/app/kubeconfig", "domain": "kube.local", "namespaces": []string{"default"}, "label_selector": "app=web", "pod_label_selector": "dns=true", "sync_timeout": 10}},
+			clientFn: func(kubeconfig string) (kubernetes.Interface, error) {
+				assert.Equal(t, "/app/kubeconfig", kubeconfig)
+				return client, nil
+			},
+			want:    &Kubernetes{id: "provider", cfg: configKubernetes{Kubeconfig: "/app/kubeconfig", Domain: "kube.local", Namespaces: []string{"default"}, LabelSelector: "app=web", PodLabelSelector: "dns=true", SyncTimeout: 10}, client: client, logger: ctx.Logger, done: ctx.Done()},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"domain": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"domain": ""}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailLabelSelector",
+			cfg:     config.Provider{Config: map[string]interface{}{"label_selector": "app in ("}},
+			wantErr: assert.Error,
+		},
+		{
+			name: "FailCreateClient",
+			cfg:  config.Provider{},
+			clientFn: func(kubeconfig string) (kubernetes.Interface, error) {
+				return nil, errors.New("fail")
+			},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubernetesClientFn = tt.clientFn
+			got, err := createKubernetesProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createKubernetesProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKubernetes_formatServiceToRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+	tests := []struct {
+		name    string
+		service *coreV1.Service
+		want    []*types.Record
+	}{
+		{
+			name: "SuccessClusterIP",
+			service: &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       coreV1.ServiceSpec{ClusterIP: "10.0.0.1", ClusterIPs: []string{"10.0.0.1", "fd00::1"}},
+			},
+			want: []*types.Record{
+				{Name: "web.default.kube.local", Type: "A", Value: "10.0.0.1"},
+				{Name: "web.default.kube.local", Type: "AAAA", Value: "fd00::1"},
+			},
+		},
+		{
+			name: "SuccessLoadBalancer",
+			service: &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       coreV1.ServiceSpec{Type: coreV1.ServiceTypeLoadBalancer, ClusterIP: "10.0.0.1"},
+				Status:     coreV1.ServiceStatus{LoadBalancer: coreV1.LoadBalancerStatus{Ingress: []coreV1.LoadBalancerIngress{{IP: "172.18.0.10"}, {Hostname: "lb.local"}}}},
+			},
+			want: []*types.Record{
+				{Name: "web.default.kube.local", Type: "A", Value: "10.0.0.1"},
+				{Name: "web.default.kube.local", Type: "A", Value: "172.18.0.10"},
+			},
+		},
+		{
+			name: "SuccessHeadless",
+			service: &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       coreV1.ServiceSpec{ClusterIP: coreV1.ClusterIPNone},
+			},
+			want: []*types.Record{},
+		},
+		{
+			name: "SuccessExternalName",
+			service: &coreV1.Service{
+				ObjectMeta: metaV1.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec:       coreV1.ServiceSpec{Type: coreV1.ServiceTypeExternalName, ExternalName: "db.example.com"},
+			},
+			want: []*types.Record{{Name: "db.default.kube.local", Type: "CNAME", Value: "db.example.com."}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := Kubernetes{id: "provider", cfg: configKubernetes{Domain: "kube.local."}, logger: ctx.Logger}
+			assert.Equal(t, tt.want, k.formatServiceToRecords(tt.service))
+		})
+	}
+}
+
+func TestKubernetes_formatIngressToRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+	rules := []networkingV1.IngressRule{{Host: "web.local"}, {Host: ""}, {Host: "*.web.local"}}
+	tests := []struct {
+		name    string
+		ingress *networkingV1.Ingress
+		want    []*types.Record
+	}{
+		{
+			name: "SuccessIP",
+			ingress: &networkingV1.Ingress{
+				Spec:   networkingV1.IngressSpec{Rules: rules},
+				Status: networkingV1.IngressStatus{LoadBalancer: networkingV1.IngressLoadBalancerStatus{Ingress: []networkingV1.IngressLoadBalancerIngress{{IP: "172.18.0.10"}}}},
+			},
+			want: []*types.Record{
+				{Name: "web.local", Type: "A", Value: "172.18.0.10"},
+				{Name: "*.web.local", Type: "A", Value: "172.18.0.10"},
+			},
+		},
+		{
+			name: "SuccessHostname",
+			ingress: &networkingV1.Ingress{
+				Spec:   networkingV1.IngressSpec{Rules: rules[:1]},
+				Status: networkingV1.IngressStatus{LoadBalancer: networkingV1.IngressLoadBalancerStatus{Ingress: []networkingV1.IngressLoadBalancerIngress{{Hostname: "lb.local"}}}},
+			},
+			want: []*types.Record{{Name: "web.local", Type: "CNAME", Value: "lb.local."}},
+		},
+		{
+			name:    "SuccessNoStatus",
+			ingress: &networkingV1.Ingress{Spec: networkingV1.IngressSpec{Rules: rules}},
+			want:    []*types.Record{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := Kubernetes{id: "provider", cfg: configKubernetes{Domain: "kube.local"}, logger: ctx.Logger}
+			assert.Equal(t, tt.want, k.formatIngressToRecords(tt.ingress))
+		})
+	}
+}
+
+func TestKubernetes_formatPodToRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+	status := coreV1.PodStatus{PodIP: "10.1.0.5", PodIPs: []coreV1.PodIP{{IP: "10.1.0.5"}, {IP: "fd01::5"}}}
+	tests := []struct {
+		name string
+		pod  *coreV1.Pod
+		want []*types.Record
+	}{
+		{
+			name: "SuccessNoAnnotation",
+			pod:  &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{"other": "value"}}, Status: status},
+			want: []*types.Record{},
+		},
+		{
+			name: "SuccessAnnotations",
+			pod: &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+				"godnsd.io/records.web.name":    "web.local",
+				"godnsd.io/records.web.type":    "A",
+				"godnsd.io/records.web6.name":   "web.local",
+				"godnsd.io/records.web6.type":   "AAAA",
+				"godnsd.io/records.alias.name":  "alias.local",
+				"godnsd.io/records.alias.type":  "CNAME",
+				"godnsd.io/records.alias.value": "web.local.",
+			}}, Status: status},
+			want: []*types.Record{
+				{Name: "web.local", Type: "A", Value: "10.1.0.5"},
+				{Name: "web.local", Type: "AAAA", Value: "fd01::5"},
+				{Name: "alias.local", Type: "CNAME", Value: "web.local."},
+			},
+		},
+		{
+			name: "SuccessPodWithoutIp",
+			pod: &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+				"godnsd.io/records.web.name": "web.local",
+				"godnsd.io/records.web.type": "A",
+			}}},
+			want: []*types.Record{},
+		},
+		{
+			name: "FailDecodeAnnotations",
+			pod: &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{
+				"godnsd.io/records.web.wrong": "web.local",
+			}}, Status: status},
+			want: []*types.Record{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := Kubernetes{id: "provider", cfg: configKubernetes{Domain: "kube.local"}, logger: ctx.Logger}
+			assert.ElementsMatch(t, tt.want, k.formatPodToRecords(tt.pod))
+		})
+	}
+}
+
+func TestKubernetes_Provide(t *testing.T) {
+	ctx := context.TestContext(nil)
+	client := fake.NewSimpleClientset(
+		&coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}},
+			Spec:       coreV1.ServiceSpec{ClusterIP: "10.0.0.1"},
+		},
+		&coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{Name: "other", Namespace: "default"},
+			Spec:       coreV1.ServiceSpec{ClusterIP: "10.0.0.2"},
+		},
+		&coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "kube-system", Labels: map[string]string{"app": "web"}},
+			Spec:       coreV1.ServiceSpec{ClusterIP: "10.0.0.3"},
+		},
+		&coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web", "godnsd.io/enable": "true"}, Annotations: map[string]string{
+				"godnsd.io/records.web.name": "pod.local",
+				"godnsd.io/records.web.type": "A",
+			}},
+			Status: coreV1.PodStatus{PodIP: "10.1.0.1"},
+		},
+		&coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: "other", Namespace: "default", Labels: map[string]string{"app": "web"}, Annotations: map[string]string{
+				"godnsd.io/records.web.name": "other.local",
+				"godnsd.io/records.web.type": "A",
+			}},
+			Status: coreV1.PodStatus{PodIP: "10.1.0.2"},
+		},
+	)
+	k := Kubernetes{
+		id:     "provider",
+		cfg:    configKubernetes{Domain: "kube.local", Namespaces: []string{"default"}, LabelSelector: "app=web", PodLabelSelector: "godnsd.io/enable=true", SyncTimeout: 10},
+		client: client,
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	configurationChan := make(chan types.Message, 40)
+	go func() {
+		assert.NoError(t, k.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Equal(t, types.Records{
+		"web.default.kube.local._A": {{Name: "web.default.kube.local", Type: "A", Value: "10.0.0.1"}},
+		"pod.local._A":              {{Name: "pod.local", Type: "A", Value: "10.1.0.1"}},
+	}, msg.Records)
+
+	_, err := client.NetworkingV1().Ingresses("default").Create(stdContext.Background(), &networkingV1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec:       networkingV1.IngressSpec{Rules: []networkingV1.IngressRule{{Host: "web.local"}}},
+		Status:     networkingV1.IngressStatus{LoadBalancer: networkingV1.IngressLoadBalancerStatus{Ingress: []networkingV1.IngressLoadBalancerIngress{{IP: "172.18.0.10"}}}},
+	}, metaV1.CreateOptions{})
+	assert.NoError(t, err)
+
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, types.Records{
+			"web.default.kube.local._A": {{Name: "web.default.kube.local", Type: "A", Value: "10.0.0.1"}},
+			"web.local._A":              {{Name: "web.local", Type: "A", Value: "172.18.0.10"}},
+			"pod.local._A":              {{Name: "pod.local", Type: "A", Value: "10.1.0.1"}},
+		}, msg.Records)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after ingress creation")
+	}
+	ctx.Cancel()
+}
+
+func TestKubernetes_Provide_FailSync(t *testing.T) {
+	tests := []struct {
+		name    string
+		cancel  bool
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "FailTimeout",
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, "in namespace 'default' within 1s", i...)
+			},
+		},
+		{
+			name:    "SuccessDone",
+			cancel:  true,
+			wantErr: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TestContext(nil)
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("list", "services", func(action k8sTesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("unreachable")
+			})
+			k := Kubernetes{
+				id:     "provider",
+				cfg:    configKubernetes{Domain: "kube.local", Namespaces: []string{"default"}, SyncTimeout: 1},
+				client: client,
+				logger: ctx.Logger,
+				done:   ctx.Done(),
+			}
+			if tt.cancel {
+				time.AfterFunc(100*time.Millisecond, ctx.Cancel)
+			}
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- k.Provide(make(chan types.Message, 1))
+			}()
+			select {
+			case err := <-errChan:
+				tt.wantErr(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("provider still waiting for informers sync")
+			}
+		})
+	}
+}
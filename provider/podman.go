@@ -0,0 +1,322 @@
+package provider
+
+import (
+	stdContext "context"
+	"encoding/json"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"github.com/traefik/paerser/parser"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[podmanKeyType] = createPodmanProvider
+}
+
+const (
+	podmanKeyType            = "podman"
+	podmanApiVersion         = "v4.0.0"
+	defaultPodmanNetworkName = "podman"
+)
+
+var (
+	_ types.Provider = &Podman{}
+
+	podmanRetryTimeout = 5 * time.Second
+
+	podmanEventActions = []string{"start", "died", "kill", "restart", "stop", "remove", "pause", "unpause"}
+)
+
+type configPodman struct {
+	Socket string `mapstructure:"socket" validate:"required"`
+}
+
+type podmanContainer struct {
+	Id     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+}
+
+type podmanPod struct {
+	Id      string            `json:"Id"`
+	Name    string            `json:"Name"`
+	Labels  map[string]string `json:"Labels"`
+	InfraId string            `json:"InfraId"`
+}
+
+type podmanInspect struct {
+	NetworkSettings struct {
+		IPAddress string `json:"IPAddress"`
+		Networks  map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type podmanEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+}
+
+type Podman struct {
+	id     string
+	cfg    configPodman
+	client *http.Client
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (p Podman) GetId() string {
+	return p.id
+}
+
+func (p Podman) GetType() string {
+	return podmanKeyType
+}
+
+func (p Podman) Provide(configurationChan chan<- types.Message) error {
+	records, err := p.fetchRecords()
+	if err != nil {
+		return err
+	}
+	configurationChan <- types.Message{Provider: p, Records: records}
+	return p.listen(configurationChan)
+}
+
+func (p Podman) listen(configurationChan chan<- types.Message) error {
+	ctx, cancel := stdContext.WithCancel(stdContext.Background())
+	defer cancel()
+	events, errs := p.watchEvents(ctx)
+	for {
+		select {
+		case errEvent := <-errs:
+			p.logger.Error(fmt.Sprintf("error when fetch podman events: %s", errEvent.Error()), "provider-type", p.GetType(), "provider-id", p.GetId())
+			select {
+			case <-time.After(podmanRetryTimeout):
+				events, errs = p.watchEvents(ctx)
+				p.resync(configurationChan)
+			case <-p.done:
+				return nil
+			}
+		case msg := <-events:
+			if slices.Contains([]string{"container", "pod"}, msg.Type) && slices.Contains(podmanEventActions, msg.Action) {
+				p.logger.Debug("event received", "provider-type", p.GetType(), "provider-id", p.GetId())
+				p.resync(configurationChan)
+			}
+		case <-p.done:
+			return nil
+		}
+	}
+}
+
+func (p Podman) resync(configurationChan chan<- types.Message) {
+	records, err := p.fetchRecords()
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("error when fetch podman records: %s", err.Error()), "provider-type", p.GetType(), "provider-id", p.GetId())
+		return
+	}
+	configurationChan <- types.Message{Provider: p, Records: records}
+}
+
+func (p Podman) watchEvents(ctx stdContext.Context) (<-chan podmanEvent, <-chan error) {
+	events, errs := make(chan podmanEvent), make(chan error, 1)
+	go func() {
+		query := url.Values{}
+		query.Set("stream", "true")
+		query.Set("filters", `{"type":["container","pod"]}`)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url("/events", query), nil)
+		if err != nil {
+			errs <- err
+			return
+		}
+		response, err := p.client.Do(req)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			errs <- fmt.Errorf("unexpected status code %d", response.StatusCode)
+			return
+		}
+
+		decoder := json.NewDecoder(response.Body)
+		for {
+			event := podmanEvent{}
+			if err = decoder.Decode(&event); err != nil {
+				if ctx.Err() == nil {
+					errs <- err
+				}
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, errs
+}
+
+func (p Podman) fetchRecords() (types.Records, error) {
+	records := types.Records{}
+	query := url.Values{}
+	query.Set("filters", fmt.Sprintf(`{"label":["%s.enable=true"]}`, types.AppName))
+
+	containers := []podmanContainer{}
+	if err := p.get("/containers/json", query, &containers); err != nil {
+		return records, err
+	}
+	for _, container := range containers {
+		name := container.Id
+		if len(container.Names) > 0 {
+			name = container.Names[0]
+		}
+		p.appendRecords(records, p.formatLabelsToRecords(name, container.Id, container.Labels))
+	}
+
+	pods := []podmanPod{}
+	if err := p.get("/pods/json", query, &pods); err != nil {
+		return records, err
+	}
+	for _, pod := range pods {
+		p.appendRecords(records, p.formatLabelsToRecords(pod.Name, pod.InfraId, pod.Labels))
+	}
+
+	return records, nil
+}
+
+func (p Podman) appendRecords(records types.Records, recordsToAdd []*types.Record) {
+	for _, record := range recordsToAdd {
+		key := types.FormatRecordKey(record.Name, record.Type)
+		if _, ok := records[key]; !ok {
+			records[key] = []*types.Record{}
+		}
+		records[key] = append(records[key], record)
+	}
+}
+
+func (p Podman) formatLabelsToRecords(name string, containerId string, labels map[string]string) []*types.Record {
+	records := []*types.Record{}
+
+	recordsContainer := &ConfigContainer{}
+	err := parser.Decode(labels, recordsContainer, types.AppName, types.AppName)
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("failed to decode labels for podman %s", name), "provider-type", p.GetType(), "provider-id", p.GetId())
+		return records
+	}
+
+	var inspect *podmanInspect
+	for key, recordContainer := range recordsContainer.Records {
+		record := &types.Record{Name: recordContainer.Name, Type: recordContainer.Type, Value: recordContainer.Value}
+		if recordContainer.Value == "" && recordContainer.Type == "A" {
+			if inspect == nil {
+				inspect = &podmanInspect{}
+				if containerId != "" {
+					err = p.get(fmt.Sprintf("/containers/%s/json", containerId), nil, inspect)
+					if err != nil {
+						p.logger.Error(fmt.Sprintf("failed to inspect podman container %s: %v", name, err), "provider-type", p.GetType(), "provider-id", p.GetId())
+					}
+				}
+			}
+			record.Value = p.findContainerIp(inspect, labels, recordContainer)
+			if record.Value == "" {
+				p.logger.Error(fmt.Sprintf("failed to find container ip for podman %s, label %s", name, key), "provider-type", p.GetType(), "provider-id", p.GetId())
+				continue
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func (p Podman) findContainerIp(inspect *podmanInspect, labels map[string]string, recordContainer *ConfigRecordContainer) string {
+	composeProjectName := labels[dockerComposeProjectLabel]
+	regexNetwork := regexp.MustCompile(fmt.Sprintf("^(%s|%s_%s)$", defaultPodmanNetworkName, composeProjectName, defaultComposeNetworkName))
+	if recordContainer.Network != "" && recordContainer.Network != defaultPodmanNetworkName {
+		regexNetwork = regexp.MustCompile(fmt.Sprintf("^(%s|%s_%s)$", recordContainer.Network, composeProjectName, recordContainer.Network))
+	}
+	for networkName, network := range inspect.NetworkSettings.Networks {
+		if regexNetwork.MatchString(networkName) {
+			return network.IPAddress
+		}
+	}
+	if recordContainer.Network == "" {
+		return inspect.NetworkSettings.IPAddress
+	}
+	return ""
+}
+
+func (p Podman) get(path string, query url.Values, value interface{}) error {
+	response, err := p.client.Get(p.url(path, query))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("request %s failed with status code %d", path, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(value)
+}
+
+func (p Podman) url(path string, query url.Values) string {
+	u := url.URL{Scheme: "http", Host: "podman", Path: fmt.Sprintf("/%s/libpod%s", podmanApiVersion, path)}
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+func defaultPodmanSocket() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "podman", "podman.sock")
+	}
+	return "/run/podman/podman.sock"
+}
+
+func createPodmanProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configPodman{Socket: defaultPodmanSocket()}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	socket := strings.TrimPrefix(instanceConfig.Socket, "unix://")
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx stdContext.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	instance := &Podman{
+		id:     id,
+		cfg:    instanceConfig,
+		client: client,
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
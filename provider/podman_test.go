@@ -0,0 +1,278 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func startPodmanServer(t *testing.T, handler http.Handler) string {
+	socket := filepath.Join(t.TempDir(), "podman.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return socket
+}
+
+func podmanTestHandler(failContainers bool) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v4.0.0/libpod/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		if failContainers {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"Id":"c1","Names":["web"],"Labels":{"godnsd.enable":"true","godnsd.records.foo.name":"foo.local","godnsd.records.foo.type":"A","godnsd.records.bar.name":"bar.local","godnsd.records.bar.type":"CNAME","godnsd.records.bar.value":"foo.local."}}]`))
+	})
+	mux.HandleFunc("/v4.0.0/libpod/containers/c1/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"NetworkSettings":{"IPAddress":"","Networks":{"podman":{"IPAddress":"10.88.0.2"}}}}`))
+	})
+	mux.HandleFunc("/v4.0.0/libpod/containers/infra/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"NetworkSettings":{"IPAddress":"10.88.0.3"}}`))
+	})
+	mux.HandleFunc("/v4.0.0/libpod/pods/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"Id":"p1","Name":"pod","InfraId":"infra","Labels":{"godnsd.enable":"true","godnsd.records.pod.name":"pod.local","godnsd.records.pod.type":"A"}}]`))
+	})
+	return mux
+}
+
+func Test_createPodmanProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	tests := []struct {
+		name       string
+		cfg        config.Provider
+		wantSocket string
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{
+			name:       "SuccessDefaultSocket",
+			cfg:        config.Provider{},
+			wantSocket: "/run/user/1000/podman/podman.sock",
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "SuccessCustomSocket",
+			cfg:        config.Provider{Config: map[string]interface{}{"socket": "unix:///run/podman/podman.sock"}},
+			wantSocket: "unix:///run/podman/podman.sock",
+			wantErr:    assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"socket": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"socket": ""}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createPodmanProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createPodmanProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				podman := got.(*Podman)
+				assert.Equal(t, "provider", podman.GetId())
+				assert.Equal(t, podmanKeyType, podman.GetType())
+				assert.Equal(t, tt.wantSocket, podman.cfg.Socket)
+				assert.NotNil(t, podman.client)
+			}
+		})
+	}
+}
+
+func Test_defaultPodmanSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	assert.Equal(t, "/run/podman/podman.sock", defaultPodmanSocket())
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	assert.Equal(t, "/run/user/1000/podman/podman.sock", defaultPodmanSocket())
+}
+
+func TestPodman_findContainerIp(t *testing.T) {
+	ctx := context.TestContext(nil)
+	inspect := &podmanInspect{}
+	inspect.NetworkSettings.IPAddress = "10.0.0.1"
+	inspect.NetworkSettings.Networks = map[string]struct {
+		IPAddress string `json:"IPAddress"`
+	}{"project_default": {IPAddress: "127.0.0.1"}, "project_other": {IPAddress: "127.0.0.2"}}
+
+	tests := []struct {
+		name            string
+		labels          map[string]string
+		recordContainer *ConfigRecordContainer
+		want            string
+	}{
+		{
+			name:            "SuccessDefaultCompose",
+			labels:          map[string]string{"com.docker.compose.project": "project"},
+			recordContainer: &ConfigRecordContainer{},
+			want:            "127.0.0.1",
+		},
+		{
+			name:            "SuccessSpecificNetwork",
+			labels:          map[string]string{"com.docker.compose.project": "project"},
+			recordContainer: &ConfigRecordContainer{Network: "other"},
+			want:            "127.0.0.2",
+		},
+		{
+			name:            "SuccessFallbackIPAddress",
+			labels:          map[string]string{},
+			recordContainer: &ConfigRecordContainer{},
+			want:            "10.0.0.1",
+		},
+		{
+			name:            "SuccessUnknownNetwork",
+			labels:          map[string]string{},
+			recordContainer: &ConfigRecordContainer{Network: "unknown"},
+			want:            "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Podman{id: "provider", logger: ctx.Logger}
+			assert.Equal(t, tt.want, p.findContainerIp(inspect, tt.labels, tt.recordContainer))
+		})
+	}
+}
+
+func TestPodman_fetchRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    types.Records
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Success",
+			handler: podmanTestHandler(false),
+			want: types.Records{
+				"foo.local._A":     {{Name: "foo.local", Type: "A", Value: "10.88.0.2"}},
+				"bar.local._CNAME": {{Name: "bar.local", Type: "CNAME", Value: "foo.local."}},
+				"pod.local._A":     {{Name: "pod.local", Type: "A", Value: "10.88.0.3"}},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailListContainers",
+			handler: podmanTestHandler(true),
+			want:    types.Records{},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TestContext(nil)
+			socket := startPodmanServer(t, tt.handler)
+			p, err := createPodmanProvider(ctx, "provider", config.Provider{Config: map[string]interface{}{"socket": socket}})
+			assert.NoError(t, err)
+			got, err := p.(*Podman).fetchRecords()
+			if !tt.wantErr(t, err, "fetchRecords()") {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPodman_formatLabelsToRecords_FailDecodeLabels(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	p := Podman{id: "provider", logger: ctx.Logger}
+	got := p.formatLabelsToRecords("web", "", map[string]string{"godnsd.records.foo.wrong": "A"})
+	assert.Equal(t, []*types.Record{}, got)
+	assert.Contains(t, buffer.String(), "failed to decode labels for podman web")
+}
+
+func TestPodman_formatLabelsToRecords_FailFindIp(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	p := Podman{id: "provider", logger: ctx.Logger}
+	got := p.formatLabelsToRecords("web", "", map[string]string{"godnsd.records.foo.name": "foo.local", "godnsd.records.foo.type": "A"})
+	assert.Equal(t, []*types.Record{}, got)
+	assert.Contains(t, buffer.String(), "failed to find container ip for podman web")
+}
+
+func TestPodman_Provide(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	podmanRetryTimeout = 10 * time.Millisecond
+	events := make(chan string)
+	mux := podmanTestHandler(false)
+	mux.HandleFunc("/v4.0.0/libpod/events", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				_, _ = w.Write([]byte(event))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	socket := startPodmanServer(t, mux)
+	p, err := createPodmanProvider(ctx, "provider", config.Provider{Config: map[string]interface{}{"socket": socket}})
+	assert.NoError(t, err)
+
+	configurationChan := make(chan types.Message, 40)
+	go func() {
+		assert.NoError(t, p.Provide(configurationChan))
+	}()
+	want := types.Records{
+		"foo.local._A":     {{Name: "foo.local", Type: "A", Value: "10.88.0.2"}},
+		"bar.local._CNAME": {{Name: "bar.local", Type: "CNAME", Value: "foo.local."}},
+		"pod.local._A":     {{Name: "pod.local", Type: "A", Value: "10.88.0.3"}},
+	}
+	msg := <-configurationChan
+	assert.Equal(t, want, msg.Records)
+
+	events <- `{"Type":"container","Action":"start"}` + "\n"
+	msg = <-configurationChan
+	assert.Equal(t, want, msg.Records)
+
+	events <- `{"Type":"image","Action":"pull"}` + "\n"
+	events <- "wrong\n"
+	msg = <-configurationChan
+	assert.Equal(t, want, msg.Records)
+	time.Sleep(100 * time.Millisecond)
+	assert.Contains(t, buffer.String(), "error when fetch podman events")
+	assert.Len(t, configurationChan, 0)
+
+	events <- `{"Type":"pod","Action":"stop"}` + "\n"
+	msg = <-configurationChan
+	assert.Equal(t, want, msg.Records)
+	ctx.Cancel()
+	close(events)
+}
+
+func TestPodman_Provide_Fail(t *testing.T) {
+	ctx := context.TestContext(nil)
+	socket := startPodmanServer(t, podmanTestHandler(true))
+	p, err := createPodmanProvider(ctx, "provider", config.Provider{Config: map[string]interface{}{"socket": socket}})
+	assert.NoError(t, err)
+	err = p.Provide(make(chan types.Message, 1))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed with status code 500")
+}
@@ -0,0 +1,216 @@
+package provider
+
+import (
+	stdContext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"slices"
+)
+
+func init() {
+	FactoryProviderMapping[redisKeyType] = createRedisProvider
+}
+
+const (
+	redisKeyType       = "redis"
+	defaultRedisKey    = "godnsd:records"
+	defaultRedisAction = "update"
+)
+
+var (
+	_ types.StoreProvider = &Redis{}
+)
+
+type configRedis struct {
+	Address  string `mapstructure:"address" validate:"required,hostname_port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db" validate:"gte=0"`
+	Key      string `mapstructure:"key" validate:"required"`
+	Channel  string `mapstructure:"channel" validate:"required"`
+}
+
+type Redis struct {
+	id     string
+	cfg    configRedis
+	client *redis.Client
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (r Redis) GetId() string {
+	return r.id
+}
+
+func (r Redis) GetType() string {
+	return redisKeyType
+}
+
+func (r Redis) Provide(configurationChan chan<- types.Message) error {
+	ctx, cancel := stdContext.WithCancel(stdContext.Background())
+	defer cancel()
+
+	pubSub := r.client.Subscribe(ctx, r.cfg.Channel, fmt.Sprintf("__keyspace@%d__:%s", r.cfg.DB, r.cfg.Key))
+	defer pubSub.Close()
+	if _, err := pubSub.Receive(ctx); err != nil {
+		return err
+	}
+
+	records, err := r.fetchRecords(ctx)
+	if err != nil {
+		return err
+	}
+	configurationChan <- types.Message{Provider: r, Records: records}
+
+	messages := pubSub.ChannelWithSubscriptions()
+	for {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				return errors.New("redis subscription closed")
+			}
+			if subscription, isSubscription := message.(*redis.Subscription); isSubscription {
+				if subscription.Kind != "subscribe" || subscription.Count != 1 {
+					continue
+				}
+				r.logger.Debug("redis subscription restored", "provider-type", r.GetType(), "provider-id", r.GetId())
+			} else {
+				r.logger.Debug("redis records updated", "provider-type", r.GetType(), "provider-id", r.GetId())
+			}
+			records, err = r.fetchRecords(ctx)
+			if err != nil {
+				r.logger.Error(fmt.Sprintf("error when fetch redis records: %s", err.Error()), "provider-type", r.GetType(), "provider-id", r.GetId())
+				continue
+			}
+			configurationChan <- types.Message{Provider: r, Records: records}
+		case <-r.done:
+			return nil
+		}
+	}
+}
+
+func (r Redis) AddRecord(record *types.Record) error {
+	return r.UpdateRecords([]*types.Record{record}, []*types.Record{})
+}
+
+func (r Redis) DeleteRecord(record *types.Record) error {
+	return r.UpdateRecords([]*types.Record{}, []*types.Record{record})
+}
+
+func (r Redis) GetRecords() (types.Records, error) {
+	return r.fetchRecords(stdContext.Background())
+}
+
+func (r Redis) UpdateRecords(added []*types.Record, deleted []*types.Record) error {
+	ctx := stdContext.Background()
+	fields := []string{}
+	for _, record := range append(slices.Clone(deleted), added...) {
+		if field := types.FormatRecordKey(record.Name, record.Type); !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+		fieldsRecords := map[string][]*types.Record{}
+		for _, field := range fields {
+			records := []*types.Record{}
+			value, err := tx.HGet(ctx, r.cfg.Key, field).Bytes()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			if err == nil {
+				if err = json.Unmarshal(value, &records); err != nil {
+					return err
+				}
+			}
+			fieldsRecords[field] = records
+		}
+
+		for _, record := range deleted {
+			field := types.FormatRecordKey(record.Name, record.Type)
+			fieldsRecords[field] = slices.DeleteFunc(fieldsRecords[field], func(item *types.Record) bool { return item.Value == record.Value })
+		}
+		for _, record := range added {
+			field := types.FormatRecordKey(record.Name, record.Type)
+			if !slices.ContainsFunc(fieldsRecords[field], func(item *types.Record) bool { return item.Value == record.Value }) {
+				fieldsRecords[field] = append(fieldsRecords[field], record)
+			}
+		}
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, field := range fields {
+				if len(fieldsRecords[field]) == 0 {
+					pipe.HDel(ctx, r.cfg.Key, field)
+					continue
+				}
+				data, errMarshal := json.Marshal(fieldsRecords[field])
+				if errMarshal != nil {
+					return errMarshal
+				}
+				pipe.HSet(ctx, r.cfg.Key, field, data)
+			}
+			return nil
+		})
+		return err
+	}, r.cfg.Key)
+	if err != nil {
+		return err
+	}
+	return r.client.Publish(ctx, r.cfg.Channel, defaultRedisAction).Err()
+}
+
+func (r Redis) fetchRecords(ctx stdContext.Context) (types.Records, error) {
+	records := types.Records{}
+	values, err := r.client.HGetAll(ctx, r.cfg.Key).Result()
+	if err != nil {
+		return records, err
+	}
+
+	for field, value := range values {
+		fieldRecords := []*types.Record{}
+		if err = json.Unmarshal([]byte(value), &fieldRecords); err != nil {
+			r.logger.Error(fmt.Sprintf("failed to decode redis field %s: %s", field, err.Error()), "provider-type", r.GetType(), "provider-id", r.GetId())
+			continue
+		}
+		for _, record := range fieldRecords {
+			key := types.FormatRecordKey(record.Name, record.Type)
+			records[key] = append(records[key], record)
+		}
+	}
+	return records, nil
+}
+
+func createRedisProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configRedis{Address: "127.0.0.1:6379", Key: defaultRedisKey, Channel: defaultRedisKey}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &Redis{
+		id:  id,
+		cfg: instanceConfig,
+		client: redis.NewClient(&redis.Options{
+			Addr:     instanceConfig.Address,
+			Username: instanceConfig.Username,
+			Password: instanceConfig.Password,
+			DB:       instanceConfig.DB,
+		}),
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
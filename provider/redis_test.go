@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"bytes"
+	stdContext "context"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newRedisTestProvider(t *testing.T, ctx *context.Context) (*miniredis.Miniredis, Redis) {
+	server := miniredis.RunT(t)
+	r := Redis{
+		id:     "provider",
+		cfg:    configRedis{Address: server.Addr(), Key: defaultRedisKey, Channel: defaultRedisKey},
+		client: redis.NewClient(&redis.Options{Addr: server.Addr()}),
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return server, r
+}
+
+func Test_createRedisProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name    string
+		cfg     config.Provider
+		want    configRedis
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessDefault",
+			cfg:     config.Provider{},
+			want:    configRedis{Address: "127.0.0.1:6379", Key: "godnsd:records", Channel: "godnsd:records"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessWithConfig",
+			cfg:     config.Provider{Config: map[string]interface{}{"address": "redis:6379", "username": "user", "password": "secret", "db": 2, "key": "dns", "channel": "dns-events"}},
+			want:    configRedis{Address: "redis:6379", Username: "user", Password: "secret", DB: 2, Key: "dns", Channel: "dns-events"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"address": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"address": "wrong", "db": -1}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createRedisProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createRedisProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got.(*Redis).cfg)
+				assert.Equal(t, "provider", got.GetId())
+				assert.Equal(t, redisKeyType, got.GetType())
+			}
+		})
+	}
+}
+
+func TestRedis_AddRecord_DeleteRecord(t *testing.T) {
+	ctx := context.TestContext(nil)
+	server, r := newRedisTestProvider(t, ctx)
+
+	assert.NoError(t, r.AddRecord(&types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.1"}))
+	assert.NoError(t, r.AddRecord(&types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.2"}))
+	assert.NoError(t, r.AddRecord(&types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.2"}))
+	assert.Equal(t, `[{"name":"foo.local","type":"A","value":"127.0.0.1"},{"name":"foo.local","type":"A","value":"127.0.0.2"}]`, server.HGet(defaultRedisKey, "foo.local._A"))
+
+	assert.NoError(t, r.DeleteRecord(&types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.1"}))
+	assert.Equal(t, `[{"name":"foo.local","type":"A","value":"127.0.0.2"}]`, server.HGet(defaultRedisKey, "foo.local._A"))
+
+	assert.NoError(t, r.DeleteRecord(&types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.2"}))
+	assert.False(t, server.Exists(defaultRedisKey))
+
+	server.HSet(defaultRedisKey, "foo.local._A", "{")
+	assert.Error(t, r.AddRecord(&types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.1"}))
+
+	server.Close()
+	assert.Error(t, r.DeleteRecord(&types.Record{Name: "foo.local", Type: "A", Value: "127.0.0.1"}))
+}
+
+func TestRedis_UpdateRecords(t *testing.T) {
+	ctx := context.TestContext(nil)
+	server, r := newRedisTestProvider(t, ctx)
+	server.HSet(defaultRedisKey, "foo.local._A", `[{"name":"foo.local","type":"A","value":"127.0.0.1"}]`)
+	server.HSet(defaultRedisKey, "foo.local._TXT", `[{"name":"foo.local","type":"TXT","value":"\"hello\""}]`)
+
+	assert.NoError(t, r.UpdateRecords(
+		[]*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.2"}, {Name: "bar.local", Type: "A", Value: "127.0.0.3"}},
+		[]*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.1"}, {Name: "foo.local", Type: "TXT", Value: "\"hello\""}},
+	))
+	got, err := r.GetRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, types.Records{
+		"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.2"}},
+		"bar.local._A": {{Name: "bar.local", Type: "A", Value: "127.0.0.3"}},
+	}, got)
+
+	server.HSet(defaultRedisKey, "bar.local._A", "{")
+	assert.Error(t, r.UpdateRecords([]*types.Record{{Name: "foo.local", Type: "A", Value: "127.0.0.4"}}, []*types.Record{{Name: "bar.local", Type: "A", Value: "127.0.0.3"}}))
+	assert.Equal(t, `[{"name":"foo.local","type":"A","value":"127.0.0.2"}]`, server.HGet(defaultRedisKey, "foo.local._A"))
+}
+
+func TestRedis_fetchRecords(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	server, r := newRedisTestProvider(t, ctx)
+	server.HSet(defaultRedisKey, "foo.local._A", `[{"name":"foo.local","type":"A","value":"127.0.0.1"}]`)
+	server.HSet(defaultRedisKey, "bar.local._CNAME", `[{"name":"bar.local","type":"CNAME","value":"foo.local."}]`)
+	server.HSet(defaultRedisKey, "wrong.local._A", `{`)
+
+	got, err := r.fetchRecords(stdContext.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.Records{
+		"foo.local._A":     {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
+		"bar.local._CNAME": {{Name: "bar.local", Type: "CNAME", Value: "foo.local."}},
+	}, got)
+	assert.Contains(t, buffer.String(), "failed to decode redis field wrong.local._A")
+
+	server.Close()
+	_, err = r.fetchRecords(stdContext.Background())
+	assert.Error(t, err)
+}
+
+func TestRedis_Provide(t *testing.T) {
+	ctx := context.TestContext(nil)
+	server, r := newRedisTestProvider(t, ctx)
+	server.HSet(defaultRedisKey, "foo.local._A", `[{"name":"foo.local","type":"A","value":"127.0.0.1"}]`)
+
+	configurationChan := make(chan types.Message, 10)
+	go func() {
+		assert.NoError(t, r.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Equal(t, types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}}}, msg.Records)
+
+	other := r
+	other.client = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	assert.NoError(t, other.AddRecord(&types.Record{Name: "bar.local", Type: "A", Value: "127.0.0.2"}))
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, types.Records{
+			"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
+			"bar.local._A": {{Name: "bar.local", Type: "A", Value: "127.0.0.2"}},
+		}, msg.Records)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after redis update")
+	}
+
+	server.Close()
+	server.HSet(defaultRedisKey, "baz.local._A", `[{"name":"baz.local","type":"A","value":"127.0.0.3"}]`)
+	assert.NoError(t, server.Restart())
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, types.Records{
+			"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
+			"bar.local._A": {{Name: "bar.local", Type: "A", Value: "127.0.0.2"}},
+			"baz.local._A": {{Name: "baz.local", Type: "A", Value: "127.0.0.3"}},
+		}, msg.Records)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after redis resubscribe")
+	}
+	assert.Len(t, configurationChan, 0)
+	ctx.Cancel()
+}
+
+func TestRedis_Provide_Fail(t *testing.T) {
+	ctx := context.TestContext(nil)
+	server, r := newRedisTestProvider(t, ctx)
+	server.Close()
+
+	err := r.Provide(make(chan types.Message, 1))
+	assert.Error(t, err)
+}
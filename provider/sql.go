@@ -0,0 +1,199 @@
+package provider
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+	"github.com/mitchellh/mapstructure"
+	"log/slog"
+	_ "modernc.org/sqlite"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[sqlKeyType] = createSqlProvider
+}
+
+const (
+	sqlKeyType = "sql"
+)
+
+var (
+	_ types.Provider = &Sql{}
+
+	sqlRequiredColumns = []string{"name", "type", "value"}
+
+	sqlListenerFn = func(dsn string) sqlListener {
+		return pq.NewListener(dsn, time.Second, time.Minute, nil)
+	}
+)
+
+type sqlListener interface {
+	Listen(channel string) error
+	NotificationChannel() <-chan *pq.Notification
+	Close() error
+}
+
+type configSql struct {
+	Driver   string `mapstructure:"driver" validate:"required,oneof=postgres sqlite"`
+	DSN      string `mapstructure:"dsn" validate:"required"`
+	Query    string `mapstructure:"query" validate:"required"`
+	Interval int64  `mapstructure:"interval" validate:"gte=0"`
+	Listen   string `mapstructure:"listen" validate:"excluded_unless=Driver postgres"`
+}
+
+type Sql struct {
+	id     string
+	cfg    configSql
+	db     *sql.DB
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (s Sql) GetId() string {
+	return s.id
+}
+
+func (s Sql) GetType() string {
+	return sqlKeyType
+}
+
+func (s Sql) Provide(configurationChan chan<- types.Message) error {
+	records, err := s.fetchRecords()
+	if err != nil {
+		return err
+	}
+	configurationChan <- types.Message{Provider: s, Records: records}
+
+	var tick <-chan time.Time
+	if s.cfg.Interval > 0 {
+		ticker := time.NewTicker(time.Duration(s.cfg.Interval) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var notifications <-chan *pq.Notification
+	if s.cfg.Listen != "" {
+		listener := sqlListenerFn(s.cfg.DSN)
+		defer listener.Close()
+		if err = listener.Listen(s.cfg.Listen); err != nil {
+			return err
+		}
+		notifications = listener.NotificationChannel()
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-notifications:
+			s.logger.Debug(fmt.Sprintf("notification received on channel %s", s.cfg.Listen), "provider-type", s.GetType(), "provider-id", s.GetId())
+		case <-s.done:
+			return nil
+		}
+
+		records, err = s.fetchRecords()
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("error when fetch sql records: %s", err.Error()), "provider-type", s.GetType(), "provider-id", s.GetId())
+			continue
+		}
+		configurationChan <- types.Message{Provider: s, Records: records}
+	}
+}
+
+func (s Sql) fetchRecords() (types.Records, error) {
+	records := types.Records{}
+	rows, err := s.db.Query(s.cfg.Query)
+	if err != nil {
+		return records, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return records, err
+	}
+	for i, column := range columns {
+		columns[i] = strings.ToLower(column)
+	}
+	for _, column := range sqlRequiredColumns {
+		if !slices.Contains(columns, column) {
+			return records, fmt.Errorf("column %s is missing in query result", column)
+		}
+	}
+
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return records, err
+		}
+
+		record, ttl := &types.Record{}, ""
+		for i, column := range columns {
+			switch column {
+			case "name":
+				record.Name = values[i].String
+			case "type":
+				record.Type = strings.ToUpper(values[i].String)
+			case "value":
+				record.Value = values[i].String
+			case "ttl":
+				ttl = values[i].String
+			}
+		}
+		if record.Name == "" || record.Type == "" || record.Value == "" {
+			s.logger.Error(fmt.Sprintf("record not valid: %v", record), "provider-type", s.GetType(), "provider-id", s.GetId())
+			continue
+		}
+		if ttl != "" {
+			value, errParse := strconv.ParseUint(ttl, 10, 32)
+			if errParse != nil {
+				s.logger.Error(fmt.Sprintf("invalid ttl '%s' for record %s: %v", ttl, record.Name, errParse), "provider-type", s.GetType(), "provider-id", s.GetId())
+				continue
+			}
+			record.TTL = uint32(value)
+		}
+
+		key := types.FormatRecordKey(record.Name, record.Type)
+		records[key] = append(records[key], record)
+	}
+	return records, rows.Err()
+}
+
+func createSqlProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configSql{Interval: 60}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(instanceConfig.Driver, instanceConfig.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &Sql{
+		id:     id,
+		cfg:    instanceConfig,
+		db:     db,
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
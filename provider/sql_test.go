@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type sqlTestListener struct {
+	channel       string
+	notifications chan *pq.Notification
+	err           error
+}
+
+func (l *sqlTestListener) Listen(channel string) error {
+	l.channel = channel
+	return l.err
+}
+
+func (l *sqlTestListener) NotificationChannel() <-chan *pq.Notification {
+	return l.notifications
+}
+
+func (l *sqlTestListener) Close() error {
+	return nil
+}
+
+func newSqlTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "dns.db"))
+	assert.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE records (fqdn TEXT, kind TEXT, content TEXT, ttl INTEGER)")
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO records VALUES ('foo.local', 'a', '127.0.0.1', 60), ('bar.local', 'CNAME', 'foo.local.', NULL)")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func Test_createSqlProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name    string
+		cfg     config.Provider
+		want    configSql
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessDefault",
+			cfg:     config.Provider{Config: map[string]interface{}{"driver": "sqlite", "dsn": "/app/dns.db", "query": "SELECT name, type, value FROM records"}},
+			want:    configSql{Driver: "sqlite", DSN: "/app/dns.db", Query: "SELECT name, type, value FROM records", Interval: 60},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessWithConfig",
+			cfg:     config.Provider{Config: map[string]interface{}{"driver": "postgres", "dsn": "postgres://localhost/ipam", "query": "SELECT name, type, value, ttl FROM records", "interval": 0, "listen": "records"}},
+			want:    configSql{Driver: "postgres", DSN: "postgres://localhost/ipam", Query: "SELECT name, type, value, ttl FROM records", Interval: 0, Listen: "records"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"dsn": []string{"wrong"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"driver": "mysql"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidateListen",
+			cfg:     config.Provider{Config: map[string]interface{}{"driver": "sqlite", "dsn": "/app/dns.db", "query": "SELECT 1", "listen": "records"}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createSqlProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createSqlProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got.(*Sql).cfg)
+				assert.Equal(t, "provider", got.GetId())
+				assert.Equal(t, sqlKeyType, got.GetType())
+			}
+		})
+	}
+}
+
+func TestSql_fetchRecords(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	db := newSqlTestDB(t)
+	_, _ = db.Exec("INSERT INTO records VALUES ('', 'A', '127.0.0.1', NULL)")
+
+	tests := []struct {
+		name    string
+		query   string
+		want    types.Records
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "Success",
+			query: "SELECT fqdn AS name, kind AS type, content AS value, ttl FROM records",
+			want: types.Records{
+				"foo.local._A":     {{Name: "foo.local", Type: "A", Value: "127.0.0.1", TTL: 60}},
+				"bar.local._CNAME": {{Name: "bar.local", Type: "CNAME", Value: "foo.local."}},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:  "SuccessWithoutTTL",
+			query: "SELECT fqdn AS NAME, kind AS TYPE, content AS VALUE FROM records WHERE fqdn = 'foo.local'",
+			want: types.Records{
+				"foo.local._A": {{Name: "foo.local", Type: "A", Value: "127.0.0.1"}},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailMissingColumn",
+			query:   "SELECT fqdn AS name, kind AS type FROM records",
+			want:    types.Records{},
+			wantErr: assert.Error,
+		},
+		{
+			name:  "SuccessSkipInvalidTTL",
+			query: "SELECT fqdn AS name, kind AS type, content AS value, CASE WHEN fqdn = 'foo.local' THEN 'wrong' ELSE ttl END AS ttl FROM records",
+			want: types.Records{
+				"bar.local._CNAME": {{Name: "bar.local", Type: "CNAME", Value: "foo.local."}},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailQuery",
+			query:   "SELECT * FROM wrong",
+			want:    types.Records{},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Sql{id: "provider", cfg: configSql{Query: tt.query}, db: db, logger: ctx.Logger}
+			got, err := s.fetchRecords()
+			if !tt.wantErr(t, err, fmt.Sprintf("fetchRecords() with query %s", tt.query)) {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.Contains(t, buffer.String(), "record not valid")
+	assert.Contains(t, buffer.String(), "invalid ttl 'wrong' for record foo.local")
+}
+
+func TestSql_Provide(t *testing.T) {
+	ctx := context.TestContext(nil)
+	db := newSqlTestDB(t)
+	listener := &sqlTestListener{notifications: make(chan *pq.Notification)}
+	sqlListenerFn = func(dsn string) sqlListener {
+		return listener
+	}
+
+	s := Sql{id: "provider", cfg: configSql{Query: "SELECT fqdn AS name, kind AS type, content AS value FROM records", Listen: "records"}, db: db, logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 10)
+	go func() {
+		assert.NoError(t, s.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Len(t, msg.Records, 2)
+
+	_, _ = db.Exec("INSERT INTO records VALUES ('baz.local', 'A', '127.0.0.2', NULL)")
+	listener.notifications <- &pq.Notification{Channel: "records"}
+	select {
+	case msg = <-configurationChan:
+		assert.Len(t, msg.Records, 3)
+		assert.Equal(t, "records", listener.channel)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after notification")
+	}
+	ctx.Cancel()
+}
+
+func TestSql_Provide_Interval(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	db := newSqlTestDB(t)
+
+	s := Sql{id: "provider", cfg: configSql{Query: "SELECT fqdn AS name, kind AS type, content AS value FROM records", Interval: 1}, db: db, logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 10)
+	go func() {
+		assert.NoError(t, s.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Len(t, msg.Records, 2)
+
+	_, _ = db.Exec("DROP TABLE records")
+	time.Sleep(1200 * time.Millisecond)
+	assert.Contains(t, buffer.String(), "error when fetch sql records")
+	ctx.Cancel()
+}
+
+func TestSql_Provide_Fail(t *testing.T) {
+	ctx := context.TestContext(nil)
+	db := newSqlTestDB(t)
+
+	s := Sql{id: "provider", cfg: configSql{Query: "SELECT * FROM wrong"}, db: db, logger: ctx.Logger, done: ctx.Done()}
+	assert.Error(t, s.Provide(make(chan types.Message, 1)))
+
+	sqlListenerFn = func(dsn string) sqlListener {
+		return &sqlTestListener{err: errors.New("fail")}
+	}
+	s.cfg = configSql{Query: "SELECT fqdn AS name, kind AS type, content AS value FROM records", Listen: "records"}
+	assert.Error(t, s.Provide(make(chan types.Message, 1)))
+}
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/miekg/dns"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/afero"
+	"io/fs"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+func init() {
+	FactoryProviderMapping[zoneFileKeyType] = createZoneFileProvider
+}
+
+const (
+	zoneFileKeyType = "zonefile"
+)
+
+var (
+	_ types.Provider = &ZoneFile{}
+)
+
+type configZoneFile struct {
+	Zones    []configZone `mapstructure:"zones" validate:"required,min=1,dive"`
+	Watch    bool         `mapstructure:"watch"`
+	Interval int64        `mapstructure:"interval" validate:"required_if=Watch true,gte=0"`
+}
+
+type configZone struct {
+	Path   string `mapstructure:"path" validate:"required"`
+	Origin string `mapstructure:"origin"`
+}
+
+type zoneFileIncludeFS struct {
+	fs    fs.FS
+	files map[string]time.Time
+}
+
+func (z zoneFileIncludeFS) Open(name string) (fs.File, error) {
+	file, err := z.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, errStat := file.Stat(); errStat == nil {
+		z.files[path.Join("/", name)] = info.ModTime()
+	}
+	return file, nil
+}
+
+type ZoneFile struct {
+	id     string
+	fs     afero.Fs
+	cfg    configZoneFile
+	logger *slog.Logger
+	done   chan bool
+}
+
+func (z ZoneFile) GetId() string {
+	return z.id
+}
+
+func (z ZoneFile) GetType() string {
+	return zoneFileKeyType
+}
+
+func (z ZoneFile) Provide(configurationChan chan<- types.Message) error {
+	records, files, err := z.readZones()
+	if err != nil {
+		return err
+	}
+	configurationChan <- types.Message{Provider: z, Records: records}
+
+	if !z.cfg.Watch {
+		return nil
+	}
+
+	ticker := time.NewTicker(time.Duration(z.cfg.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !z.isModified(files) {
+				continue
+			}
+
+			z.logger.Debug("zone files updated", "provider-type", z.GetType(), "provider-id", z.GetId())
+			records, files, err = z.readZones()
+			if err != nil {
+				z.logger.Error(fmt.Sprintf("error when read zone files: %s", err.Error()), "provider-type", z.GetType(), "provider-id", z.GetId())
+				continue
+			}
+			configurationChan <- types.Message{Provider: z, Records: records}
+		case <-z.done:
+			return nil
+		}
+	}
+}
+
+func (z ZoneFile) isModified(files map[string]time.Time) bool {
+	for filename, modTime := range files {
+		info, err := z.fs.Stat(filename)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z ZoneFile) readZones() (types.Records, map[string]time.Time, error) {
+	records := types.Records{}
+	files := map[string]time.Time{}
+	for _, zone := range z.cfg.Zones {
+		if err := z.readZone(zone, records, files); err != nil {
+			return types.Records{}, files, err
+		}
+	}
+	return records, files, nil
+}
+
+func (z ZoneFile) readZone(zone configZone, records types.Records, files map[string]time.Time) error {
+	zonePath, err := filepath.Abs(zone.Path)
+	if err != nil {
+		return err
+	}
+	file, err := z.fs.Open(zonePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if info, errStat := file.Stat(); errStat == nil {
+		files[zonePath] = info.ModTime()
+	}
+
+	origin := "."
+	if zone.Origin != "" {
+		origin = dns.Fqdn(zone.Origin)
+	}
+	zoneParser := dns.NewZoneParser(file, origin, zonePath)
+	zoneParser.SetIncludeAllowed(true)
+	zoneParser.SetIncludeFS(zoneFileIncludeFS{fs: afero.NewIOFS(afero.NewBasePathFs(z.fs, "/")), files: files})
+
+	for rr, ok := zoneParser.Next(); ok; rr, ok = zoneParser.Next() {
+		record := types.ConvertRRToRecord(rr)
+		key := types.FormatRecordKey(record.Name, record.Type)
+		records[key] = append(records[key], record)
+	}
+	return zoneParser.Err()
+}
+
+func createZoneFileProvider(ctx *context.Context, id string, cfg config.Provider) (types.Provider, error) {
+	instanceConfig := configZoneFile{Interval: 5}
+	err := mapstructure.Decode(cfg.Config, &instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	err = validate.Struct(instanceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &ZoneFile{
+		id:     id,
+		fs:     ctx.FS,
+		cfg:    instanceConfig,
+		logger: ctx.Logger,
+		done:   ctx.Done(),
+	}
+	return instance, nil
+}
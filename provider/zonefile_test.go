@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/alexandreh2ag/go-dns-discover/context"
+	"github.com/alexandreh2ag/go-dns-discover/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const zoneFileTestContent = `$ORIGIN example.com.
+$TTL 300
+@       IN SOA  ns1 admin (1 7200 3600 1209600 300)
+        IN NS   ns1
+        IN MX   10 mail
+ns1     IN A    10.0.0.1
+WWW 60  IN A    10.0.0.2
+        IN AAAA fd00::2
+mail    IN CNAME www
+$INCLUDE hosts.inc
+`
+
+func Test_createZoneFileProvider(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	tests := []struct {
+		name    string
+		cfg     config.Provider
+		want    configZoneFile
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessDefault",
+			cfg:     config.Provider{Config: map[string]interface{}{"zones": []map[string]interface{}{{"path": "/etc/bind/db.example.com"}}}},
+			want:    configZoneFile{Zones: []configZone{{Path: "/etc/bind/db.example.com"}}, Interval: 5},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessWithConfig",
+			cfg:     config.Provider{Config: map[string]interface{}{"zones": []map[string]interface{}{{"path": "/etc/bind/db.example.com", "origin": "example.com"}}, "watch": true, "interval": 1}},
+			want:    configZoneFile{Zones: []configZone{{Path: "/etc/bind/db.example.com", Origin: "example.com"}}, Watch: true, Interval: 1},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailDecodeCfg",
+			cfg:     config.Provider{Config: map[string]interface{}{"zones": "wrong"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidate",
+			cfg:     config.Provider{Config: map[string]interface{}{"zones": []map[string]interface{}{{"origin": "example.com"}}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "FailValidateEmpty",
+			cfg:     config.Provider{},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createZoneFileProvider(ctx, "provider", tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("createZoneFileProvider(ctx, 'provider', %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got.(*ZoneFile).cfg)
+				assert.Equal(t, "provider", got.GetId())
+				assert.Equal(t, zoneFileKeyType, got.GetType())
+			}
+		})
+	}
+}
+
+func TestZoneFile_readZones(t *testing.T) {
+	wd, _ := os.Getwd()
+	tests := []struct {
+		name      string
+		zones     []configZone
+		mockFn    func(fs afero.Fs)
+		want      types.Records
+		wantFiles []string
+		wantErr   assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "Success",
+			zones: []configZone{{Path: "/etc/bind/db.example.com"}},
+			mockFn: func(fs afero.Fs) {
+				_ = afero.WriteFile(fs, "/etc/bind/db.example.com", []byte(zoneFileTestContent), 0644)
+				_ = afero.WriteFile(fs, "/etc/bind/hosts.inc", []byte("foo IN TXT \"hello world\"\n"), 0644)
+			},
+			want: types.Records{
+				"example.com._SOA":        {{Name: "example.com", Type: "SOA", Value: "ns1.example.com. admin.example.com. 1 7200 3600 1209600 300", TTL: 300}},
+				"example.com._NS":         {{Name: "example.com", Type: "NS", Value: "ns1.example.com.", TTL: 300}},
+				"example.com._MX":         {{Name: "example.com", Type: "MX", Value: "10 mail.example.com.", TTL: 300}},
+				"ns1.example.com._A":      {{Name: "ns1.example.com", Type: "A", Value: "10.0.0.1", TTL: 300}},
+				"www.example.com._A":      {{Name: "www.example.com", Type: "A", Value: "10.0.0.2", TTL: 60}},
+				"www.example.com._AAAA":   {{Name: "www.example.com", Type: "AAAA", Value: "fd00::2", TTL: 300}},
+				"mail.example.com._CNAME": {{Name: "mail.example.com", Type: "CNAME", Value: "www.example.com.", TTL: 300}},
+				"foo.example.com._TXT":    {{Name: "foo.example.com", Type: "TXT", Value: `"hello world"`, TTL: 300}},
+			},
+			wantFiles: []string{"/etc/bind/db.example.com", "/etc/bind/hosts.inc"},
+			wantErr:   assert.NoError,
+		},
+		{
+			name:  "SuccessRelativeInclude",
+			zones: []configZone{{Path: "zones/db.local", Origin: "local"}},
+			mockFn: func(fs afero.Fs) {
+				_ = afero.WriteFile(fs, filepath.Join(wd, "zones/db.local"), []byte("$INCLUDE inc/hosts.inc\n"), 0644)
+				_ = afero.WriteFile(fs, filepath.Join(wd, "zones/inc/hosts.inc"), []byte("foo 60 IN A 10.0.0.4\n"), 0644)
+			},
+			want:      types.Records{"foo.local._A": {{Name: "foo.local", Type: "A", Value: "10.0.0.4", TTL: 60}}},
+			wantFiles: []string{filepath.Join(wd, "zones/db.local"), filepath.Join(wd, "zones/inc/hosts.inc")},
+			wantErr:   assert.NoError,
+		},
+		{
+			name:  "SuccessWithOrigin",
+			zones: []configZone{{Path: "/etc/bind/db.other", Origin: "other.local"}},
+			mockFn: func(fs afero.Fs) {
+				_ = afero.WriteFile(fs, "/etc/bind/db.other", []byte("foo 60 IN A 10.0.0.3\n"), 0644)
+			},
+			want:      types.Records{"foo.other.local._A": {{Name: "foo.other.local", Type: "A", Value: "10.0.0.3", TTL: 60}}},
+			wantFiles: []string{"/etc/bind/db.other"},
+			wantErr:   assert.NoError,
+		},
+		{
+			name:      "FailOpenFile",
+			zones:     []configZone{{Path: "/etc/bind/db.example.com"}},
+			mockFn:    func(fs afero.Fs) {},
+			want:      types.Records{},
+			wantFiles: []string{},
+			wantErr:   assert.Error,
+		},
+		{
+			name:  "FailSyntax",
+			zones: []configZone{{Path: "/etc/bind/db.example.com", Origin: "example.com"}},
+			mockFn: func(fs afero.Fs) {
+				_ = afero.WriteFile(fs, "/etc/bind/db.example.com", []byte("foo IN A 10.0.0.1\nbar IN A wrong\n"), 0644)
+			},
+			want:      types.Records{},
+			wantFiles: []string{"/etc/bind/db.example.com"},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, "/etc/bind/db.example.com: dns: bad A A: \"wrong\" at line: 2", i...)
+			},
+		},
+		{
+			name:  "FailInclude",
+			zones: []configZone{{Path: "/etc/bind/db.example.com", Origin: "example.com"}},
+			mockFn: func(fs afero.Fs) {
+				_ = afero.WriteFile(fs, "/etc/bind/db.example.com", []byte("$INCLUDE missing.inc\n"), 0644)
+			},
+			want:      types.Records{},
+			wantFiles: []string{"/etc/bind/db.example.com"},
+			wantErr:   assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			tt.mockFn(fs)
+			z := ZoneFile{id: "provider", fs: fs, cfg: configZoneFile{Zones: tt.zones}}
+			got, files, err := z.readZones()
+			if !tt.wantErr(t, err, "readZones()") {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			gotFiles := []string{}
+			for filename := range files {
+				gotFiles = append(gotFiles, filename)
+			}
+			assert.ElementsMatch(t, tt.wantFiles, gotFiles)
+			assert.False(t, z.isModified(files))
+		})
+	}
+}
+
+func TestZoneFile_Provide(t *testing.T) {
+	ctx := context.TestContext(nil)
+	_ = afero.WriteFile(ctx.FS, "/etc/bind/db.example.com", []byte("foo IN A 10.0.0.1\n"), 0644)
+
+	z := ZoneFile{id: "provider", fs: ctx.FS, cfg: configZoneFile{Zones: []configZone{{Path: "/etc/bind/db.example.com", Origin: "example.com"}}}, logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 1)
+	assert.NoError(t, z.Provide(configurationChan))
+	msg := <-configurationChan
+	assert.Equal(t, types.Records{"foo.example.com._A": {{Name: "foo.example.com", Type: "A", Value: "10.0.0.1"}}}, msg.Records)
+}
+
+func TestZoneFile_Provide_Watch(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := context.TestContext(buffer)
+	_ = afero.WriteFile(ctx.FS, "/etc/bind/db.example.com", []byte("$INCLUDE /etc/bind/hosts.inc\n"), 0644)
+	_ = afero.WriteFile(ctx.FS, "/etc/bind/hosts.inc", []byte("foo IN A 10.0.0.1\n"), 0644)
+
+	z := ZoneFile{id: "provider", fs: ctx.FS, cfg: configZoneFile{Zones: []configZone{{Path: "/etc/bind/db.example.com", Origin: "example.com"}}, Watch: true, Interval: 1}, logger: ctx.Logger, done: ctx.Done()}
+	configurationChan := make(chan types.Message, 10)
+	go func() {
+		assert.NoError(t, z.Provide(configurationChan))
+	}()
+
+	msg := <-configurationChan
+	assert.Len(t, msg.Records, 1)
+
+	_ = afero.WriteFile(ctx.FS, "/etc/bind/hosts.inc", []byte("foo IN A 10.0.0.2\n"), 0644)
+	_ = ctx.FS.Chtimes("/etc/bind/hosts.inc", time.Now(), time.Now().Add(time.Minute))
+	select {
+	case msg = <-configurationChan:
+		assert.Equal(t, types.Records{"foo.example.com._A": {{Name: "foo.example.com", Type: "A", Value: "10.0.0.2"}}}, msg.Records)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received after zone file update")
+	}
+
+	_ = afero.WriteFile(ctx.FS, "/etc/bind/hosts.inc", []byte("foo IN A wrong\n"), 0644)
+	time.Sleep(1200 * time.Millisecond)
+	assert.Contains(t, buffer.String(), "error when read zone files")
+	ctx.Cancel()
+}
+
+func TestZoneFile_Provide_Fail(t *testing.T) {
+	ctx := context.TestContext(nil)
+
+	z := ZoneFile{id: "provider", fs: ctx.FS, cfg: configZoneFile{Zones: []configZone{{Path: "/etc/bind/db.example.com"}}}, logger: ctx.Logger, done: ctx.Done()}
+	assert.Error(t, z.Provide(make(chan types.Message, 1)))
+}
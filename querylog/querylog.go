@@ -0,0 +1,107 @@
+package querylog
+
+import (
+	"context"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"math/rand"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+type Entry struct {
+	ClientIP string
+	Protocol string
+	Qname    string
+	Qtype    string
+	Rcode    string
+	Source   string
+	Latency  time.Duration
+}
+
+type Logger struct {
+	enabled    atomic.Bool
+	sampleRate float64
+	logger     *slog.Logger
+	writer     io.Writer
+}
+
+func NewLogger(cfg config.QueryLogConfig) (*Logger, error) {
+	writer, err := createWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.LevelKey {
+			return slog.Attr{}
+		}
+		return a
+	}}
+	var handler slog.Handler = slog.NewJSONHandler(writer, opts)
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(writer, opts)
+	}
+
+	l := &Logger{sampleRate: cfg.SampleRate, logger: slog.New(handler), writer: writer}
+	l.enabled.Store(cfg.Enable)
+	return l, nil
+}
+
+func createWriter(cfg config.QueryLogConfig) (io.Writer, error) {
+	switch cfg.Output {
+	case "file":
+		return &lumberjack.Logger{
+			Filename:   cfg.Path,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+			Compress:   cfg.Compress,
+		}, nil
+	case "syslog":
+		writer, err := syslog.Dial(cfg.SyslogNetwork, cfg.SyslogAddress, syslog.LOG_INFO|syslog.LOG_DAEMON, "godnsd")
+		if err != nil {
+			return nil, fmt.Errorf("query log: %w", err)
+		}
+		return writer, nil
+	}
+	return os.Stdout, nil
+}
+
+func (l *Logger) Enabled() bool {
+	return l.enabled.Load()
+}
+
+func (l *Logger) SetEnabled(enabled bool) {
+	l.enabled.Store(enabled)
+}
+
+func (l *Logger) Log(entry Entry) {
+	if !l.Enabled() || (l.sampleRate < 1 && rand.Float64() >= l.sampleRate) {
+		return
+	}
+	l.logger.LogAttrs(
+		context.Background(),
+		slog.LevelInfo,
+		"query",
+		slog.String("client_ip", entry.ClientIP),
+		slog.String("protocol", entry.Protocol),
+		slog.String("qname", entry.Qname),
+		slog.String("qtype", entry.Qtype),
+		slog.String("rcode", entry.Rcode),
+		slog.String("source", entry.Source),
+		slog.Float64("latency_ms", float64(entry.Latency.Microseconds())/1000),
+	)
+}
+
+func (l *Logger) Close() error {
+	if closer, ok := l.writer.(io.Closer); ok && l.writer != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package querylog
+
+import (
+	"encoding/json"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEntry = Entry{ClientIP: "10.0.0.1", Protocol: "udp", Qname: "foo.local.", Qtype: "A", Rcode: "NOERROR", Source: "fs", Latency: 1500 * time.Microsecond}
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.QueryLogConfig
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessStdout",
+			cfg:     config.QueryLogConfig{Enable: true, Output: "stdout", Format: "json", SampleRate: 1},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessFile",
+			cfg:     config.QueryLogConfig{Output: "file", Format: "text", Path: filepath.Join(t.TempDir(), "query.log"), MaxSize: 1},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailSyslog",
+			cfg:     config.QueryLogConfig{Output: "syslog", SyslogNetwork: "unix", SyslogAddress: filepath.Join(t.TempDir(), "missing.sock")},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLogger(tt.cfg)
+			if !tt.wantErr(t, err) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.cfg.Enable, got.Enabled())
+				assert.NoError(t, got.Close())
+			}
+		})
+	}
+}
+
+func TestLogger_Log(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.QueryLogConfig
+		checkFn func(t *testing.T, content string)
+	}{
+		{
+			name: "SuccessJson",
+			cfg:  config.QueryLogConfig{Enable: true, Format: "json", SampleRate: 1},
+			checkFn: func(t *testing.T, content string) {
+				entry := map[string]interface{}{}
+				assert.NoError(t, json.Unmarshal([]byte(content), &entry))
+				assert.Equal(t, "query", entry["msg"])
+				assert.NotContains(t, entry, "level")
+				assert.Equal(t, "10.0.0.1", entry["client_ip"])
+				assert.Equal(t, "udp", entry["protocol"])
+				assert.Equal(t, "foo.local.", entry["qname"])
+				assert.Equal(t, "A", entry["qtype"])
+				assert.Equal(t, "NOERROR", entry["rcode"])
+				assert.Equal(t, "fs", entry["source"])
+				assert.Equal(t, 1.5, entry["latency_ms"])
+			},
+		},
+		{
+			name: "SuccessText",
+			cfg:  config.QueryLogConfig{Enable: true, Format: "text", SampleRate: 1},
+			checkFn: func(t *testing.T, content string) {
+				assert.Contains(t, content, "msg=query client_ip=10.0.0.1 protocol=udp qname=foo.local. qtype=A rcode=NOERROR source=fs latency_ms=1.5\n")
+			},
+		},
+		{
+			name: "SuccessDisabled",
+			cfg:  config.QueryLogConfig{Format: "json", SampleRate: 1},
+			checkFn: func(t *testing.T, content string) {
+				assert.Empty(t, content)
+			},
+		},
+		{
+			name: "SuccessSampledOut",
+			cfg:  config.QueryLogConfig{Enable: true, Format: "json", SampleRate: 0},
+			checkFn: func(t *testing.T, content string) {
+				assert.Empty(t, content)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Output = "file"
+			tt.cfg.Path = filepath.Join(t.TempDir(), "query.log")
+			l, err := NewLogger(tt.cfg)
+			assert.NoError(t, err)
+			l.Log(testEntry)
+			assert.NoError(t, l.Close())
+			content, _ := os.ReadFile(tt.cfg.Path)
+			tt.checkFn(t, string(content))
+		})
+	}
+}
+
+func TestLogger_SetEnabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log")
+	l, err := NewLogger(config.QueryLogConfig{Output: "file", Format: "json", Path: path, SampleRate: 1})
+	assert.NoError(t, err)
+	l.Log(testEntry)
+	l.SetEnabled(true)
+	assert.True(t, l.Enabled())
+	l.Log(testEntry)
+	l.SetEnabled(false)
+	l.Log(testEntry)
+	assert.NoError(t, l.Close())
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, 1, strings.Count(string(content), "\n"))
+}
+
+func TestLogger_Syslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	l, err := NewLogger(config.QueryLogConfig{Enable: true, Output: "syslog", Format: "text", SampleRate: 1, SyslogNetwork: "udp", SyslogAddress: conn.LocalAddr().String()})
+	assert.NoError(t, err)
+	l.Log(testEntry)
+	assert.NoError(t, l.Close())
+
+	buffer := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buffer)
+	assert.NoError(t, err)
+	assert.Contains(t, string(buffer[:n]), "godnsd")
+	assert.Contains(t, string(buffer[:n]), "qname=foo.local.")
+}
@@ -0,0 +1,118 @@
+package tsig
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/miekg/dns"
+	"github.com/spf13/afero"
+	"hash"
+	"os"
+	"strings"
+)
+
+var (
+	_ dns.TsigProvider = Keys{}
+
+	algorithms = map[string]string{
+		"hmac-sha1":   dns.HmacSHA1,
+		"hmac-sha224": dns.HmacSHA224,
+		"hmac-sha256": dns.HmacSHA256,
+		"hmac-sha384": dns.HmacSHA384,
+		"hmac-sha512": dns.HmacSHA512,
+	}
+
+	hashes = map[string]func() hash.Hash{
+		dns.HmacSHA1:   sha1.New,
+		dns.HmacSHA224: sha256.New224,
+		dns.HmacSHA256: sha256.New,
+		dns.HmacSHA384: sha512.New384,
+		dns.HmacSHA512: sha512.New,
+	}
+)
+
+type Key struct {
+	Algorithm string
+	Secret    []byte
+}
+
+type Keys map[string]Key
+
+func (k Keys) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	key, ok := k[strings.ToLower(t.Hdr.Name)]
+	if !ok {
+		return nil, dns.ErrSecret
+	}
+	if !strings.EqualFold(key.Algorithm, t.Algorithm) {
+		return nil, dns.ErrKeyAlg
+	}
+	h := hmac.New(hashes[key.Algorithm], key.Secret)
+	h.Write(msg)
+	return h.Sum(nil), nil
+}
+
+func (k Keys) Verify(msg []byte, t *dns.TSIG) error {
+	expected, err := k.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, mac) {
+		return dns.ErrSig
+	}
+	return nil
+}
+
+func KeyName(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
+
+func LoadKeys(fs afero.Fs, cfg []config.TsigConfig) (Keys, error) {
+	keys := Keys{}
+	for _, keyCfg := range cfg {
+		secret := keyCfg.Secret
+		switch {
+		case keyCfg.SecretFile != "":
+			content, err := afero.ReadFile(fs, keyCfg.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("tsig key %s: %w", keyCfg.Name, err)
+			}
+			secret = strings.TrimSpace(string(content))
+		case keyCfg.SecretEnv != "":
+			value, ok := os.LookupEnv(keyCfg.SecretEnv)
+			if !ok {
+				return nil, fmt.Errorf("tsig key %s: environment variable %s is not defined", keyCfg.Name, keyCfg.SecretEnv)
+			}
+			secret = strings.TrimSpace(value)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil || len(decoded) == 0 {
+			return nil, fmt.Errorf("tsig key %s: secret is not valid base64", keyCfg.Name)
+		}
+
+		algorithm := "hmac-sha256"
+		if keyCfg.Algorithm != "" {
+			algorithm = keyCfg.Algorithm
+		}
+		keys[KeyName(keyCfg.Name)] = Key{Algorithm: algorithms[algorithm], Secret: decoded}
+	}
+	return keys, nil
+}
+
+func LoadKey(fs afero.Fs, cfg []config.TsigConfig, name string) (Keys, error) {
+	for _, keyCfg := range cfg {
+		if KeyName(keyCfg.Name) == KeyName(name) {
+			return LoadKeys(fs, []config.TsigConfig{keyCfg})
+		}
+	}
+	return nil, fmt.Errorf("tsig key %s is not defined", name)
+}
@@ -0,0 +1,118 @@
+package tsig
+
+import (
+	"fmt"
+	"github.com/alexandreh2ag/go-dns-discover/config"
+	"github.com/miekg/dns"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLoadKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     []config.TsigConfig
+		mockFn  func(t *testing.T, fs afero.Fs)
+		want    Keys
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SuccessEmpty",
+			want:    Keys{},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "SuccessSecret",
+			cfg:     []config.TsigConfig{{Name: "Transfer", Secret: "c2VjcmV0"}},
+			want:    Keys{"transfer.": {Algorithm: dns.HmacSHA256, Secret: []byte("secret")}},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "SuccessSecretFile",
+			cfg:  []config.TsigConfig{{Name: "transfer.", Algorithm: "hmac-sha512", SecretFile: "/etc/godnsd/transfer.key"}},
+			mockFn: func(t *testing.T, fs afero.Fs) {
+				_ = afero.WriteFile(fs, "/etc/godnsd/transfer.key", []byte("c2VjcmV0\n"), 0600)
+			},
+			want:    Keys{"transfer.": {Algorithm: dns.HmacSHA512, Secret: []byte("secret")}},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "SuccessSecretEnv",
+			cfg:  []config.TsigConfig{{Name: "update", Algorithm: "hmac-sha1", SecretEnv: "GODNSD_TEST_TSIG_SECRET"}},
+			mockFn: func(t *testing.T, fs afero.Fs) {
+				t.Setenv("GODNSD_TEST_TSIG_SECRET", "c2VjcmV0")
+			},
+			want:    Keys{"update.": {Algorithm: dns.HmacSHA1, Secret: []byte("secret")}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "FailSecretFile",
+			cfg:     []config.TsigConfig{{Name: "transfer", SecretFile: "/etc/godnsd/transfer.key"}},
+			wantErr: assert.Error,
+		},
+		{
+			name: "FailSecretEnv",
+			cfg:  []config.TsigConfig{{Name: "transfer", SecretEnv: "GODNSD_TEST_TSIG_MISSING"}},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, "tsig key transfer: environment variable GODNSD_TEST_TSIG_MISSING is not defined", i...)
+			},
+		},
+		{
+			name: "FailSecretBase64",
+			cfg:  []config.TsigConfig{{Name: "transfer", Secret: "wrong!"}},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, "tsig key transfer: secret is not valid base64", i...)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			if tt.mockFn != nil {
+				tt.mockFn(t, fs)
+			}
+			got, err := LoadKeys(fs, tt.cfg)
+			if !tt.wantErr(t, err, fmt.Sprintf("LoadKeys(fs, %v)", tt.cfg)) {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestKeys_GenerateVerify(t *testing.T) {
+	keys := Keys{"transfer.": {Algorithm: dns.HmacSHA256, Secret: []byte("secret")}}
+	sign := func(name string, algorithm string) []byte {
+		msg := &dns.Msg{}
+		msg.SetQuestion("local.", dns.TypeSOA)
+		msg.SetTsig(name, algorithm, 300, time.Now().Unix())
+		signed, _, err := dns.TsigGenerateWithProvider(msg, Keys{name: {Algorithm: algorithm, Secret: []byte("secret")}}, "", false)
+		assert.NoError(t, err)
+		return signed
+	}
+
+	assert.NoError(t, dns.TsigVerifyWithProvider(sign("transfer.", dns.HmacSHA256), keys, "", false))
+	assert.ErrorIs(t, dns.TsigVerifyWithProvider(sign("transfer.", dns.HmacSHA512), keys, "", false), dns.ErrKeyAlg)
+	assert.ErrorIs(t, dns.TsigVerifyWithProvider(sign("other.", dns.HmacSHA256), keys, "", false), dns.ErrSecret)
+
+	otherKeys := Keys{"transfer.": {Algorithm: dns.HmacSHA256, Secret: []byte("other")}}
+	assert.ErrorIs(t, dns.TsigVerifyWithProvider(sign("transfer.", dns.HmacSHA256), otherKeys, "", false), dns.ErrSig)
+}
+
+func TestLoadKey(t *testing.T) {
+	cfg := []config.TsigConfig{{Name: "transfer", Secret: "c2VjcmV0"}, {Name: "update", SecretFile: "/etc/godnsd/update.key"}}
+
+	got, err := LoadKey(afero.NewMemMapFs(), cfg, "Transfer.")
+	assert.NoError(t, err)
+	assert.Equal(t, Keys{"transfer.": {Algorithm: dns.HmacSHA256, Secret: []byte("secret")}}, got)
+
+	_, err = LoadKey(afero.NewMemMapFs(), cfg, "update")
+	assert.ErrorContains(t, err, "tsig key update: open /etc/godnsd/update.key")
+
+	_, err = LoadKey(afero.NewMemMapFs(), cfg, "unknown")
+	assert.ErrorContains(t, err, "tsig key unknown is not defined")
+}
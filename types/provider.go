@@ -1,8 +1,25 @@
 package types
 
+import (
+	"net"
+)
+
 type Providers map[string]Provider
 type Provider interface {
 	GetId() string
 	GetType() string
 	Provide(configurationChan chan<- Message) error
 }
+
+type StoreProvider interface {
+	Provider
+	AddRecord(record *Record) error
+	DeleteRecord(record *Record) error
+	GetRecords() (Records, error)
+	UpdateRecords(added []*Record, deleted []*Record) error
+}
+
+type NotifyProvider interface {
+	Provider
+	Notify(zone string, remoteAddr net.Addr, keyName string) bool
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/miekg/dns"
 	"gopkg.in/yaml.v3"
+	"strings"
 )
 
 type Records map[string][]*Record
@@ -28,12 +29,30 @@ type Record struct {
 	Name  string `yaml:"name" json:"name"`
 	Type  string `yaml:"type" json:"type"`
 	Value string `yaml:"value" json:"value"`
+	TTL   uint32 `yaml:"ttl,omitempty" json:"ttl,omitempty"`
 }
 
 func FormatRecordKey(name string, typeRecord string) string {
 	return fmt.Sprintf("%s_%s", dns.Fqdn(name), typeRecord)
 }
 
+func ConvertRRToRecord(rr dns.RR) *Record {
+	header := rr.Header()
+	return &Record{
+		Name:  strings.TrimSuffix(strings.ToLower(header.Name), "."),
+		Type:  dns.TypeToString[header.Rrtype],
+		Value: strings.TrimPrefix(rr.String(), header.String()),
+		TTL:   header.Ttl,
+	}
+}
+
+func ConvertRecordToRR(record *Record) (dns.RR, error) {
+	if record.TTL > 0 {
+		return dns.NewRR(fmt.Sprintf("%s %d %s %s", record.Name, record.TTL, record.Type, record.Value))
+	}
+	return dns.NewRR(fmt.Sprintf("%s %s %s", record.Name, record.Type, record.Value))
+}
+
 func ConvertTypeDNSUintToStr(typeRecord uint16) string {
 	switch typeRecord {
 	case dns.TypeA:
@@ -48,7 +67,14 @@ func ConvertTypeDNSUintToStr(typeRecord uint16) string {
 		return "SOA"
 	case dns.TypeNS:
 		return "NS"
+	case dns.TypeSRV:
+		return "SRV"
+	case dns.TypePTR:
+		return "PTR"
 	default:
+		if typeStr, ok := dns.TypeToString[typeRecord]; ok {
+			return typeStr
+		}
 		return "UNKNOWN"
 	}
 }
@@ -80,6 +80,21 @@ func Test_ConvertTypeDNSUintToStr(t *testing.T) {
 			Type: dns.TypeNS,
 			want: "NS",
 		},
+		{
+			name: "Type SRV",
+			Type: dns.TypeSRV,
+			want: "SRV",
+		},
+		{
+			name: "Type PTR",
+			Type: dns.TypePTR,
+			want: "PTR",
+		},
+		{
+			name: "Type MX",
+			Type: dns.TypeMX,
+			want: "MX",
+		},
 		{
 			name: "Type Unknown",
 			Type: 10000,
@@ -92,3 +107,23 @@ func Test_ConvertTypeDNSUintToStr(t *testing.T) {
 		})
 	}
 }
+
+func Test_ConvertRRToRecord(t *testing.T) {
+	rrA, _ := dns.NewRR("Foo.Local. 60 IN A 127.0.0.1")
+	rrMX, _ := dns.NewRR("foo.local. 3600 IN MX 10 mail.foo.local.")
+	assert.Equal(t, &Record{Name: "foo.local", Type: "A", Value: "127.0.0.1", TTL: 60}, ConvertRRToRecord(rrA))
+	assert.Equal(t, &Record{Name: "foo.local", Type: "MX", Value: "10 mail.foo.local.", TTL: 3600}, ConvertRRToRecord(rrMX))
+}
+
+func Test_ConvertRecordToRR(t *testing.T) {
+	rr, err := ConvertRecordToRR(&Record{Name: "foo.local", Type: "A", Value: "127.0.0.1", TTL: 60})
+	assert.NoError(t, err)
+	assert.Equal(t, "foo.local.\t60\tIN\tA\t127.0.0.1", rr.String())
+
+	rr, err = ConvertRecordToRR(&Record{Name: "foo.local", Type: "MX", Value: "10 mail.foo.local."})
+	assert.NoError(t, err)
+	assert.Equal(t, "foo.local.\t3600\tIN\tMX\t10 mail.foo.local.", rr.String())
+
+	_, err = ConvertRecordToRR(&Record{Name: "foo.local", Type: "A", Value: "wrong"})
+	assert.Error(t, err)
+}